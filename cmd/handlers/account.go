@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/darolpz/students/cmd/handlers/middleware"
+	"github.com/darolpz/students/internal/auth"
+	"github.com/darolpz/students/internal/model"
+	"github.com/darolpz/students/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+var ErrWrongPassword = errors.New("current password is wrong")
+
+// Me godoc
+// @Summary      Current user
+// @Description  returns the account of the current user
+// @Tags         account
+// @Produce      json
+// @Success      200 {object} model.User
+// @Failure      401 {string} string
+// @Failure      500 {string} string
+// @Router       /auth/me [get]
+// @Security Authorization
+func Me(usersRepo repository.IUsersRepository) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		user, ok := currentUser(c, usersRepo)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"user": user.Public(),
+		})
+	}
+}
+
+// UpdateMe godoc
+// @Summary      Update current user
+// @Description  change the profile of the current user, whose fields are validated as when registering
+// @Tags         account
+// @Param        profile body model.ProfileUpdate true "ProfileUpdate"
+// @Accept       json
+// @Produce      json
+// @Success      200 {object} model.User
+// @Failure      400 {string} string
+// @Failure      401 {string} string
+// @Failure      422 {object} map[string][]validation.FieldError
+// @Failure      500 {string} string
+// @Router       /auth/me [patch]
+// @Security Authorization
+func UpdateMe(usersRepo repository.IUsersRepository) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		claims, _ := middleware.Claims(c)
+		userID, err := claims.UserID()
+		if err != nil {
+			c.String(http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		var profile model.ProfileUpdate
+		// Bind the JSON request body to the profile struct.
+		if err := c.BindJSON(&profile); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		if !valid(c, profile) {
+			return
+		}
+
+		user, err := usersRepo.UpdateUser(userID, model.UserUpdate{Name: profile.Name})
+		if err != nil {
+			if errors.Is(err, repository.ErrUserNotFound) {
+				c.String(http.StatusUnauthorized, err.Error())
+				return
+			}
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"user": user.Public(),
+		})
+	}
+}
+
+// ChangePassword godoc
+// @Summary      Change password
+// @Description  change the password of the current user. Every other session is logged out and a new token pair is returned for this one.
+// @Tags         account
+// @Param        password body model.ChangePassword true "ChangePassword"
+// @Accept       json
+// @Produce      json
+// @Success      200 {object} model.TokenPair
+// @Failure      400 {string} string
+// @Failure      401 {string} string
+// @Failure      403 {string} string
+// @Failure      422 {object} map[string][]validation.FieldError
+// @Failure      429 {string} string
+// @Failure      500 {string} string
+// @Router       /auth/me/password [post]
+// @Security Authorization
+func ChangePassword(
+	usersRepo repository.IUsersRepository,
+	tokensRepo repository.IRefreshTokensRepository,
+	sessionsRepo repository.ISessionsRepository,
+	revokedRepo repository.IRevokedTokensRepository,
+	throttlesRepo repository.ILoginThrottlesRepository,
+	authService auth.IAuthService,
+	config AuthConfig) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var change model.ChangePassword
+		// Bind the JSON request body to the change struct.
+		if err := c.BindJSON(&change); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+
+		user, ok := currentUser(c, usersRepo)
+		if !ok {
+			return
+		}
+
+		// Guessing the current password is throttled like a login
+		retryAfter, err := loginRetryAfter(throttlesRepo, user.Email, c.ClientIP())
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		if retryAfter > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			c.String(http.StatusTooManyRequests, ErrTooManyAttempts.Error())
+			return
+		}
+		if !authService.CheckPasswordHash(change.CurrentPassword, user.Password) {
+			if err := recordLoginFailure(throttlesRepo, user.Email, c.ClientIP()); err != nil {
+				c.String(http.StatusInternalServerError, err.Error())
+				return
+			}
+			c.String(http.StatusForbidden, ErrWrongPassword.Error())
+			return
+		}
+
+		// Check the password against the policy
+		if !checked(c, withPassword(nil, config.PasswordPolicy, "new_password", change.NewPassword, user.Email)) {
+			return
+		}
+
+		// Hash and persist the new password
+		password, err := authService.GenerateHashPassword(change.NewPassword)
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		if err := usersRepo.UpdateUserPassword(user.ID, password); err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		// Log out every session, then start a new one for the caller
+		if err := revokeUserTokens(user.ID, tokensRepo, revokedRepo); err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		tokens, err := startSession(c, user, sessionsRepo, tokensRepo, authService)
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		c.JSON(http.StatusOK, tokens)
+	}
+}
+
+// currentUser retrieves the user the access token was issued to. It answers
+// the request itself and returns false when that fails.
+func currentUser(c *gin.Context, usersRepo repository.IUsersRepository) (model.User, bool) {
+	claims, _ := middleware.Claims(c)
+	userID, err := claims.UserID()
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		return model.User{}, false
+	}
+
+	user, err := usersRepo.FindUserByID(userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			c.String(http.StatusUnauthorized, err.Error())
+			return model.User{}, false
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return model.User{}, false
+	}
+	return user, true
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/darolpz/students/internal/auth"
+	"github.com/darolpz/students/internal/model"
+	"github.com/darolpz/students/internal/validation"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMe(t *testing.T) {
+	user := &model.User{ID: 1, Name: "John", Email: "john.doe@gmail.com", Password: "hash", Role: "user"}
+
+	tests := []struct {
+		name           string
+		userID         int
+		expectedStatus int
+	}{
+		{
+			name:           "should_return_current_user",
+			userID:         1,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "should_return_unauthorized_user_not_found",
+			userID:         2,
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeUsersRepository{user: user}
+			w := serve(asUser(tt.userID, Me(repo)), http.MethodGet, "/auth/me", "/auth/me", "")
+			require.Equal(t, tt.expectedStatus, w.Code)
+			require.NotContains(t, w.Body.String(), "hash")
+		})
+	}
+}
+
+func TestUpdateMe(t *testing.T) {
+	tests := []struct {
+		name            string
+		userID          int
+		body            string
+		expectedStatus  int
+		expectedErrors  validation.Errors
+		expectedUpdates int
+	}{
+		{
+			name:            "should_update_name",
+			userID:          1,
+			body:            `{"name":"Johnny"}`,
+			expectedStatus:  http.StatusOK,
+			expectedUpdates: 1,
+		},
+		{
+			name:           "should_reject_blank_name",
+			userID:         1,
+			body:           `{"name":"  "}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedErrors: validation.Errors{{Field: "name", Rule: "notblank", Message: "is required"}},
+		},
+		{
+			name:           "should_reject_long_name",
+			userID:         1,
+			body:           `{"name":"` + strings.Repeat("x", 21) + `"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedErrors: validation.Errors{{Field: "name", Rule: "max", Param: "20", Message: "must be at most 20 characters"}},
+		},
+		{
+			name:           "should_return_unauthorized_user_not_found",
+			userID:         2,
+			body:           `{"name":"Johnny"}`,
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeUsersRepository{user: &model.User{ID: 1, Name: "John", Email: "john.doe@gmail.com", Role: "user"}}
+			w := serve(asUser(tt.userID, UpdateMe(repo)), http.MethodPatch, "/auth/me", "/auth/me", tt.body)
+			require.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedErrors != nil {
+				var body struct {
+					Errors validation.Errors `json:"errors"`
+				}
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+				require.Equal(t, tt.expectedErrors, body.Errors)
+			}
+			require.Len(t, repo.updates, tt.expectedUpdates)
+		})
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	authService := newAuthService(t)
+	hash, err := authService.GenerateHashPassword("current password")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name             string
+		body             string
+		throttles        []model.LoginThrottle
+		expectedStatus   int
+		expectedFailures int
+		expectedChanged  bool
+	}{
+		{
+			name:            "should_change_password",
+			body:            `{"current_password":"current password","new_password":"new password"}`,
+			expectedStatus:  http.StatusOK,
+			expectedChanged: true,
+		},
+		{
+			name:             "should_return_forbidden_wrong_password",
+			body:             `{"current_password":"wrong password","new_password":"new password"}`,
+			expectedStatus:   http.StatusForbidden,
+			expectedFailures: 2,
+		},
+		{
+			name:           "should_reject_weak_password",
+			body:           `{"current_password":"current password","new_password":"short"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "should_return_too_many_requests_locked_out",
+			body: `{"current_password":"current password","new_password":"new password"}`,
+			throttles: []model.LoginThrottle{
+				{ID: auth.AccountThrottleID("john.doe@gmail.com"), Failures: auth.AccountLockout.MaxAttempts, LastFailureAt: time.Now()},
+			},
+			expectedStatus: http.StatusTooManyRequests,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usersRepo := &fakeUsersRepository{user: &model.User{ID: 1, Name: "John", Email: "john.doe@gmail.com", Password: hash, Role: "user"}}
+			tokensRepo := &fakeRefreshTokensRepository{}
+			sessionsRepo := &fakeSessionsRepository{}
+			revokedRepo := &fakeRevokedTokensRepository{}
+			throttlesRepo := &fakeLoginThrottlesRepository{throttles: tt.throttles}
+			handler := ChangePassword(usersRepo, tokensRepo, sessionsRepo, revokedRepo, throttlesRepo, authService,
+				AuthConfig{PasswordPolicy: auth.DefaultPasswordPolicy})
+
+			w := serve(asUser(1, handler), http.MethodPost, "/auth/me/password", "/auth/me/password", tt.body)
+			require.Equal(t, tt.expectedStatus, w.Code)
+			require.Len(t, throttlesRepo.failures, tt.expectedFailures)
+			if tt.expectedChanged {
+				require.Len(t, usersRepo.passwords, 1)
+				require.True(t, authService.CheckPasswordHash("new password", usersRepo.passwords[0]))
+				// Every other session is logged out and a new one started
+				require.Len(t, revokedRepo.revoked, 1)
+				require.Len(t, sessionsRepo.created, 1)
+			} else {
+				require.Empty(t, usersRepo.passwords)
+			}
+		})
+	}
+}
@@ -0,0 +1,393 @@
+package handlers
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/darolpz/students/cmd/handlers/middleware"
+	"github.com/darolpz/students/internal/auth"
+	"github.com/darolpz/students/internal/model"
+	"github.com/darolpz/students/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrInvalidUserID    = errors.New("invalid user id")
+	ErrInvalidIP        = errors.New("invalid ip address")
+	ErrInvalidRole      = errors.New("invalid role")
+	ErrSelfModification = errors.New("admins cannot disable, delete or change the role of their own account")
+)
+
+// UnlockUser godoc
+// @Summary      Unlock user
+// @Description  clear the failed login attempts of an account
+// @Tags         admin
+// @Param        user_id  path string  true  "user_id"  1
+// @Success      200 {string} string
+// @Failure      400 {string} string
+// @Failure      404 {string} string
+// @Failure      500 {string} string
+// @Router       /admin/users/{user_id}/unlock [post]
+// @Security Authorization
+func UnlockUser(usersRepo repository.IUsersRepository, throttlesRepo repository.ILoginThrottlesRepository) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		// Get query params
+		userID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.String(http.StatusBadRequest, ErrInvalidUserID.Error())
+			return
+		}
+
+		// Retrieve user from repository
+		user, err := usersRepo.FindUserByID(userID)
+		if err != nil {
+			if errors.Is(err, repository.ErrUserNotFound) {
+				c.String(http.StatusNotFound, err.Error())
+				return
+			}
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if err := throttlesRepo.DeleteLoginThrottle(auth.AccountThrottleID(user.Email)); err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		c.String(http.StatusOK, "user unlocked")
+	}
+}
+
+// UnlockIP godoc
+// @Summary      Unlock ip
+// @Description  clear the failed login attempts of a client address
+// @Tags         admin
+// @Param        ip  path string  true  "ip"
+// @Success      200 {string} string
+// @Failure      400 {string} string
+// @Failure      500 {string} string
+// @Router       /admin/lockouts/ips/{ip} [delete]
+// @Security Authorization
+func UnlockIP(throttlesRepo repository.ILoginThrottlesRepository) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		// Get query params
+		ip := net.ParseIP(c.Param("ip"))
+		if ip == nil {
+			c.String(http.StatusBadRequest, ErrInvalidIP.Error())
+			return
+		}
+
+		if err := throttlesRepo.DeleteLoginThrottle(auth.IPThrottleID(ip.String())); err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		c.String(http.StatusOK, "ip unlocked")
+	}
+}
+
+// ListUsers godoc
+// @Summary      List users
+// @Description  returns a page of users, filtered by role, email or name prefix, status and verification
+// @Tags         admin
+// @Param        offset    query  int     false  "list offset"  0
+// @Param        limit     query  int     false  "list limit, at most 100"  10
+// @Param        role      query  string  false  "role"
+// @Param        email     query  string  false  "email prefix"
+// @Param        name      query  string  false  "name prefix"
+// @Param        disabled  query  bool    false  "only disabled or only enabled users"
+// @Param        verified  query  bool    false  "only verified or only unverified users"
+// @Produce      json
+// @Success      200 {object} map[string]interface{}
+// @Failure      400 {string} string
+// @Failure      403 {object} map[string]string
+// @Failure      500 {string} string
+// @Router       /admin/users [get]
+// @Security Authorization
+func ListUsers(usersRepo repository.IUsersRepository) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		// Get query params
+		query := model.UserQuery{Limit: defaultListLimit}
+		if err := c.ShouldBindQuery(&query); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		if query.Offset < 0 || query.Limit < 1 || query.Limit > maxListLimit {
+			c.String(http.StatusBadRequest, ErrInvalidPagination.Error())
+			return
+		}
+
+		users, total, err := usersRepo.ListUsers(query)
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		for i := range users {
+			users[i] = users[i].Public()
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"users":  users,
+			"total":  total,
+			"offset": query.Offset,
+			"limit":  query.Limit,
+		})
+	}
+}
+
+// GetUser godoc
+// @Summary      Get user
+// @Description  returns a user
+// @Tags         admin
+// @Param        user_id  path string  true  "user_id"  1
+// @Produce      json
+// @Success      200 {object} model.User
+// @Failure      400 {string} string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {string} string
+// @Failure      500 {string} string
+// @Router       /admin/users/{user_id} [get]
+// @Security Authorization
+func GetUser(usersRepo repository.IUsersRepository) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		// Get query params
+		userID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.String(http.StatusBadRequest, ErrInvalidUserID.Error())
+			return
+		}
+
+		user, err := usersRepo.FindUserByID(userID)
+		if err != nil {
+			if errors.Is(err, repository.ErrUserNotFound) {
+				c.String(http.StatusNotFound, err.Error())
+				return
+			}
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"user": user.Public(),
+		})
+	}
+}
+
+// UpdateUser godoc
+// @Summary      Update user
+// @Description  change the name or the role of a user, whose fields are validated as when registering. A role change logs the user out everywhere.
+// @Tags         admin
+// @Param        user_id  path string  true  "user_id"  1
+// @Param        update body model.UserUpdate true "UserUpdate"
+// @Accept       json
+// @Produce      json
+// @Success      200 {object} model.User
+// @Failure      400 {string} string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {string} string
+// @Failure      409 {string} string
+// @Failure      422 {object} map[string][]validation.FieldError
+// @Failure      500 {string} string
+// @Router       /admin/users/{user_id} [patch]
+// @Security Authorization
+func UpdateUser(
+	usersRepo repository.IUsersRepository,
+	tokensRepo repository.IRefreshTokensRepository,
+	revokedRepo repository.IRevokedTokensRepository) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		// Get query params
+		userID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.String(http.StatusBadRequest, ErrInvalidUserID.Error())
+			return
+		}
+
+		var update model.UserUpdate
+		// Bind the JSON request body to the update struct.
+		if err := c.BindJSON(&update); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		if !valid(c, update) {
+			return
+		}
+
+		previous, err := usersRepo.FindUserByID(userID)
+		if err != nil {
+			if errors.Is(err, repository.ErrUserNotFound) {
+				c.String(http.StatusNotFound, err.Error())
+				return
+			}
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		roleChanged := update.Role != nil && *update.Role != previous.Role
+		if roleChanged && isCaller(c, userID) {
+			c.String(http.StatusConflict, ErrSelfModification.Error())
+			return
+		}
+
+		user, err := usersRepo.UpdateUser(userID, update)
+		if err != nil {
+			if errors.Is(err, repository.ErrUserNotFound) {
+				c.String(http.StatusNotFound, err.Error())
+				return
+			}
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		// Tokens carry the role, so they have to be issued again
+		if roleChanged {
+			if err := revokeUserTokens(userID, tokensRepo, revokedRepo); err != nil {
+				c.String(http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"user": user.Public(),
+		})
+	}
+}
+
+// DisableUser godoc
+// @Summary      Disable user
+// @Description  block a user from logging in and log it out everywhere
+// @Tags         admin
+// @Param        user_id  path string  true  "user_id"  1
+// @Success      200 {string} string
+// @Failure      400 {string} string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {string} string
+// @Failure      409 {string} string
+// @Failure      500 {string} string
+// @Router       /admin/users/{user_id}/disable [post]
+// @Security Authorization
+func DisableUser(
+	usersRepo repository.IUsersRepository,
+	tokensRepo repository.IRefreshTokensRepository,
+	revokedRepo repository.IRevokedTokensRepository) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		// Get query params
+		userID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.String(http.StatusBadRequest, ErrInvalidUserID.Error())
+			return
+		}
+		if isCaller(c, userID) {
+			c.String(http.StatusConflict, ErrSelfModification.Error())
+			return
+		}
+
+		if err := usersRepo.SetUserDisabled(userID, true); err != nil {
+			if errors.Is(err, repository.ErrUserNotFound) {
+				c.String(http.StatusNotFound, err.Error())
+				return
+			}
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if err := revokeUserTokens(userID, tokensRepo, revokedRepo); err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		c.String(http.StatusOK, "user disabled")
+	}
+}
+
+// EnableUser godoc
+// @Summary      Enable user
+// @Description  allow a disabled user to log in again
+// @Tags         admin
+// @Param        user_id  path string  true  "user_id"  1
+// @Success      200 {string} string
+// @Failure      400 {string} string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {string} string
+// @Failure      500 {string} string
+// @Router       /admin/users/{user_id}/enable [post]
+// @Security Authorization
+func EnableUser(usersRepo repository.IUsersRepository) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		// Get query params
+		userID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.String(http.StatusBadRequest, ErrInvalidUserID.Error())
+			return
+		}
+
+		if err := usersRepo.SetUserDisabled(userID, false); err != nil {
+			if errors.Is(err, repository.ErrUserNotFound) {
+				c.String(http.StatusNotFound, err.Error())
+				return
+			}
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		c.String(http.StatusOK, "user enabled")
+	}
+}
+
+// DeleteUser godoc
+// @Summary      Delete user
+// @Description  delete a user with its tokens, second factor and linked identities
+// @Tags         admin
+// @Param        user_id  path string  true  "user_id"  1
+// @Success      200 {string} string
+// @Failure      400 {string} string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {string} string
+// @Failure      409 {string} string
+// @Failure      500 {string} string
+// @Router       /admin/users/{user_id} [delete]
+// @Security Authorization
+func DeleteUser(
+	usersRepo repository.IUsersRepository,
+	tokensRepo repository.IRefreshTokensRepository,
+	revokedRepo repository.IRevokedTokensRepository) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		// Get query params
+		userID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.String(http.StatusBadRequest, ErrInvalidUserID.Error())
+			return
+		}
+		if isCaller(c, userID) {
+			c.String(http.StatusConflict, ErrSelfModification.Error())
+			return
+		}
+
+		if err := usersRepo.DeleteUser(userID); err != nil {
+			if errors.Is(err, repository.ErrUserNotFound) {
+				c.String(http.StatusNotFound, err.Error())
+				return
+			}
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		// Access tokens already handed out must stop working too
+		if err := revokeUserTokens(userID, tokensRepo, revokedRepo); err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		c.String(http.StatusOK, "user deleted")
+	}
+}
+
+// isCaller reports whether userID is the authenticated caller.
+func isCaller(c *gin.Context, userID int) bool {
+	claims, ok := middleware.Claims(c)
+	if !ok {
+		return false
+	}
+	id, err := claims.UserID()
+	return err == nil && id == userID
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/darolpz/students/internal/model"
+	"github.com/darolpz/students/internal/validation"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateUser(t *testing.T) {
+	tests := []struct {
+		name            string
+		callerID        int
+		path            string
+		body            string
+		expectedStatus  int
+		expectedErrors  validation.Errors
+		expectedUpdates int
+		expectedRevoked bool
+	}{
+		{
+			name:            "should_update_name",
+			callerID:        2,
+			path:            "/admin/users/1",
+			body:            `{"name":"Johnny"}`,
+			expectedStatus:  http.StatusOK,
+			expectedUpdates: 1,
+		},
+		{
+			name:            "should_update_role_and_log_user_out",
+			callerID:        2,
+			path:            "/admin/users/1",
+			body:            `{"role":"admin"}`,
+			expectedStatus:  http.StatusOK,
+			expectedUpdates: 1,
+			expectedRevoked: true,
+		},
+		{
+			name:           "should_return_bad_request_invalid_id",
+			callerID:       2,
+			path:           "/admin/users/1=1",
+			body:           `{"name":"Johnny"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "should_return_not_found",
+			callerID:       2,
+			path:           "/admin/users/3",
+			body:           `{"name":"Johnny"}`,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "should_return_conflict_own_role",
+			callerID:       1,
+			path:           "/admin/users/1",
+			body:           `{"role":"admin"}`,
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "should_reject_blank_name",
+			callerID:       2,
+			path:           "/admin/users/1",
+			body:           `{"name":" "}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedErrors: validation.Errors{{Field: "name", Rule: "notblank", Message: "is required"}},
+		},
+		{
+			name:           "should_reject_long_name_and_unknown_role",
+			callerID:       2,
+			path:           "/admin/users/1",
+			body:           `{"name":"` + strings.Repeat("x", 21) + `","role":"root"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedErrors: validation.Errors{
+				{Field: "name", Rule: "max", Param: "20", Message: "must be at most 20 characters"},
+				{Field: "role", Rule: "oneof", Param: "admin user", Message: "must be one of admin, user"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usersRepo := &fakeUsersRepository{user: &model.User{ID: 1, Name: "John", Email: "john.doe@gmail.com", Role: "user"}}
+			tokensRepo := &fakeRefreshTokensRepository{}
+			revokedRepo := &fakeRevokedTokensRepository{}
+			handler := asUser(tt.callerID, UpdateUser(usersRepo, tokensRepo, revokedRepo))
+
+			w := serve(handler, http.MethodPatch, "/admin/users/:id", tt.path, tt.body)
+			require.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedErrors != nil {
+				var body struct {
+					Errors validation.Errors `json:"errors"`
+				}
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+				require.Equal(t, tt.expectedErrors, body.Errors)
+			}
+			require.Len(t, usersRepo.updates, tt.expectedUpdates)
+			require.Equal(t, tt.expectedRevoked, len(tokensRepo.revokedUsers) == 1)
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/darolpz/students/cmd/handlers/middleware"
+	"github.com/darolpz/students/internal/auth"
+	"github.com/darolpz/students/internal/model"
+	"github.com/darolpz/students/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrInvalidAPIKeyID   = errors.New("invalid api key id")
+	ErrAPIKeyNameMissing = errors.New("api key name is required")
+	ErrAPIKeyNoScopes    = errors.New("api key needs at least one scope")
+	ErrAPIKeyExpiresAt   = errors.New("api key expiry must be in the future")
+)
+
+// CreateAPIKey godoc
+// @Summary      Create api key
+// @Description  create an api key with the given scopes. The key is only returned once.
+// @Tags         admin
+// @Param        key body model.NewAPIKey true "NewAPIKey"
+// @Accept       json
+// @Produce      json
+// @Success      201 {object} model.CreatedAPIKey
+// @Failure      400 {string} string
+// @Failure      401 {string} string
+// @Failure      403 {object} map[string]string
+// @Failure      500 {string} string
+// @Router       /admin/api-keys [post]
+// @Security Authorization
+func CreateAPIKey(apiKeysRepo repository.IAPIKeysRepository, authService auth.IAuthService) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		claims, _ := middleware.Claims(c)
+		userID, err := claims.UserID()
+		if err != nil {
+			c.String(http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		var newKey model.NewAPIKey
+		// Bind the JSON request body to the newKey struct.
+		if err := c.BindJSON(&newKey); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+
+		name := strings.TrimSpace(newKey.Name)
+		if name == "" {
+			c.String(http.StatusBadRequest, ErrAPIKeyNameMissing.Error())
+			return
+		}
+		if len(newKey.Scopes) == 0 {
+			c.String(http.StatusBadRequest, ErrAPIKeyNoScopes.Error())
+			return
+		}
+		scopes, err := auth.FormatScopes(newKey.Scopes)
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		if newKey.ExpiresAt != nil && !newKey.ExpiresAt.After(time.Now()) {
+			c.String(http.StatusBadRequest, ErrAPIKeyExpiresAt.Error())
+			return
+		}
+
+		// Generate the key. Only its hash is stored.
+		token, _, err := authService.GenerateOpaqueToken()
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		key := auth.APIKeyPrefix + token
+
+		stored, err := apiKeysRepo.CreateAPIKey(model.APIKey{
+			Name:      name,
+			Prefix:    key[:auth.APIKeyDisplayLength],
+			KeyHash:   authService.HashToken(key),
+			Scopes:    scopes,
+			CreatedBy: userID,
+			ExpiresAt: newKey.ExpiresAt,
+		})
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		c.JSON(http.StatusCreated, model.CreatedAPIKey{APIKey: stored, Key: key})
+	}
+}
+
+// ListAPIKeys godoc
+// @Summary      List api keys
+// @Description  list every api key, including revoked and expired ones
+// @Tags         admin
+// @Produce      json
+// @Success      200 {array} model.APIKey
+// @Failure      401 {string} string
+// @Failure      403 {object} map[string]string
+// @Failure      500 {string} string
+// @Router       /admin/api-keys [get]
+// @Security Authorization
+func ListAPIKeys(apiKeysRepo repository.IAPIKeysRepository) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		keys, err := apiKeysRepo.ListAPIKeys()
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		c.JSON(http.StatusOK, keys)
+	}
+}
+
+// RevokeAPIKey godoc
+// @Summary      Revoke api key
+// @Description  revoke an api key, which is rejected from then on
+// @Tags         admin
+// @Param        key_id  path string  true  "key_id"  1
+// @Success      200 {string} string
+// @Failure      400 {string} string
+// @Failure      401 {string} string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {string} string
+// @Failure      500 {string} string
+// @Router       /admin/api-keys/{key_id} [delete]
+// @Security Authorization
+func RevokeAPIKey(apiKeysRepo repository.IAPIKeysRepository) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		// Get query params
+		keyID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.String(http.StatusBadRequest, ErrInvalidAPIKeyID.Error())
+			return
+		}
+
+		if err := apiKeysRepo.RevokeAPIKey(keyID); err != nil {
+			if errors.Is(err, repository.ErrAPIKeyNotFound) {
+				c.String(http.StatusNotFound, err.Error())
+				return
+			}
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		c.String(http.StatusOK, "api key revoked")
+	}
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/darolpz/students/internal/export"
+	"github.com/darolpz/students/internal/model"
+	"github.com/darolpz/students/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+var ErrExportPagination = errors.New("exports are not paginated")
+
+// ExportStudents godoc
+// @Summary      Export students
+// @Description  downloads every student matching the same filters and sort as the listing, streamed as CSV, JSON objects one per line, or an Excel workbook
+// @Tags         students
+// @Param        format    query     string  false  "csv, ndjson or xlsx"  "csv"
+// @Param        name      query     string  false  "first or last name prefix"
+// @Param        email     query     string  false  "email"
+// @Param        min_age   query     int     false  "minimum age"
+// @Param        max_age   query     int     false  "maximum age"
+// @Param        sort      query     string  false  "comma separated fields among id, first_name, last_name, age and email, prefixed with - for descending order"  "last_name,-age"
+// @Produce      text/csv
+// @Produce      application/x-ndjson
+// @Produce      application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Success      200 {file} file
+// @Header       200 {string} Content-Disposition "attachment; filename=students-20060102-150405.csv"
+// @Failure      400 {string} string
+// @Failure      500 {string} string
+// @Failure      403 {object} map[string]string
+// @Router       /students/export [get]
+// @Security Authorization
+func ExportStudents(studentsRepo repository.IStudentsRepository) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		// Get query params
+		var query model.StudentQuery
+		if err := c.ShouldBindQuery(&query); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		if query.Offset != 0 || query.Limit != 0 || query.After != "" || query.Before != "" {
+			c.String(http.StatusBadRequest, ErrExportPagination.Error())
+			return
+		}
+		// Errors must be caught before the download starts
+		if _, err := query.SortFields(); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		format, ok := export.Formats[c.DefaultQuery("format", "csv")]
+		if !ok {
+			c.String(http.StatusBadRequest, export.ErrUnsupportedFormat.Error())
+			return
+		}
+
+		filename := "students-" + time.Now().UTC().Format("20060102-150405") + "." + format.Extension
+		c.Header("Content-Type", format.ContentType)
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+		c.Status(http.StatusOK)
+
+		writer, err := export.NewWriter(c.Writer, format.Name)
+		if err == nil {
+			err = studentsRepo.EachStudent(query, writer.Write)
+		}
+		if err == nil {
+			err = writer.Close()
+		}
+		// The status is already sent, a failed export is left truncated
+		if err != nil {
+			log.Printf("could not export students: %s", err)
+		}
+	}
+}
@@ -1,14 +1,27 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/darolpz/students/cmd/handlers/middleware"
 	"github.com/darolpz/students/internal/auth"
+	"github.com/darolpz/students/internal/mail"
+	"github.com/darolpz/students/internal/oidc"
 	"github.com/darolpz/students/internal/repository"
+	"github.com/darolpz/students/internal/search"
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	// defaultListLimit is the page size of listings that don't ask for one.
+	defaultListLimit = 10
+	// maxListLimit is the largest page a listing returns.
+	maxListLimit = 100
+)
+
+var ErrInvalidPagination = errors.New("offset must not be negative and limit must be between 1 and 100")
+
 // Ping godoc
 // @Summary      Health check
 // @Description  returns pong
@@ -23,26 +36,201 @@ func CreateHealthEndpoints(app *gin.Engine) {
 	})
 }
 
+// JWKS godoc
+// @Summary      JSON Web Key Set
+// @Description  returns the public keys access tokens can be verified with
+// @Tags         auth
+// @Produce      json
+// @Success      200 {object} auth.JWKS
+// @Router       /.well-known/jwks.json [get]
+func CreateWellKnownEndpoints(app *gin.Engine, authService auth.IAuthService) {
+	app.GET("/.well-known/jwks.json", func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, authService.JWKS())
+	})
+}
+
+// studentsPermissions declares the permission each students route requires.
+var studentsPermissions = middleware.RoutePermissions{
+	"GET /students/:id":                  auth.PermissionReadStudents,
+	"GET /students/list":                 auth.PermissionReadStudents,
+	"GET /students/search":               auth.PermissionReadStudents,
+	"GET /students/export":               auth.PermissionReadStudents,
+	"GET /students/trash":                auth.PermissionTrashStudents,
+	"POST /students/":                    auth.PermissionWriteStudents,
+	"POST /students/import":              auth.PermissionWriteStudents,
+	"PATCH /students/:id":                auth.PermissionWriteStudents,
+	"PUT /students/:id":                  auth.PermissionWriteStudents,
+	"DELETE /students/:id":               auth.PermissionWriteStudents,
+	"POST /students/:id/restore":         auth.PermissionTrashStudents,
+	"GET /students/:id/history":          auth.PermissionReadStudents,
+	"GET /students/:id/history/:version": auth.PermissionReadStudents,
+	"POST /students/:id/revert/:version": auth.PermissionWriteStudents,
+}
+
+// StudentsConfig holds the settings of the students endpoints.
+type StudentsConfig struct {
+	// RequireIfMatch rejects updates, reverts and deletions of students that
+	// do not carry the ETag of the student in an If-Match header, so that
+	// nobody overwrites changes they have not seen. Restores are exempt, as
+	// deleted students cannot be read for their ETag, and so are imports,
+	// which write students by email in bulk.
+	RequireIfMatch bool
+}
+
 func CreateStudentsEndpoints(
 	app *gin.Engine,
 	studentsRepo repository.IStudentsRepository,
-	authService auth.IAuthService) {
+	revokedRepo repository.IRevokedTokensRepository,
+	apiKeysRepo repository.IAPIKeysRepository,
+	searcher search.ISearcher,
+	authService auth.IAuthService,
+	config StudentsConfig) {
 	students := app.Group("students")
-	students.Use(middleware.AuthMiddleware(authService))
+	students.Use(middleware.AuthMiddleware(authService, revokedRepo, apiKeysRepo), middleware.Authorize(studentsPermissions))
 	students.GET("/:id", FindStudent(studentsRepo))
 
 	students.GET("/list", ListStudents(studentsRepo))
 
+	students.GET("/search", SearchStudents(searcher))
+
+	students.GET("/export", ExportStudents(studentsRepo))
+
 	students.POST("/", CreateStudent(studentsRepo))
 
-	students.PATCH("/:id", UpdateStudent(studentsRepo))
+	students.POST("/import", ImportStudents(studentsRepo))
+
+	students.PATCH("/:id", UpdateStudent(studentsRepo, config))
+
+	students.PUT("/:id", ReplaceStudent(studentsRepo, config))
+
+	students.DELETE("/:id", DeleteStudent(studentsRepo, config))
+
+	students.GET("/trash", ListDeletedStudents(studentsRepo))
 
-	students.DELETE("/:id", DeleteStudent(studentsRepo))
+	students.POST("/:id/restore", RestoreStudent(studentsRepo))
+
+	students.GET("/:id/history", ListStudentHistory(studentsRepo))
+
+	students.GET("/:id/history/:version", GetStudentVersion(studentsRepo))
+
+	students.POST("/:id/revert/:version", RevertStudent(studentsRepo, config))
+}
+
+// AuthConfig holds the settings of the auth endpoints.
+type AuthConfig struct {
+	// PublicURL is the base URL the API is reachable at, used to build the
+	// links sent by email.
+	PublicURL string
+	// PasswordResetURL is the page of the front-end where users choose a new
+	// password. The reset token is appended as the token query parameter.
+	PasswordResetURL string
+	// MFAIssuer names the service in authenticator apps.
+	MFAIssuer string
+	// MFARequiredRoles are the roles that cannot get an access token without
+	// a second factor.
+	MFARequiredRoles []string
+	// PasswordPolicy is enforced whenever a password is chosen.
+	PasswordPolicy auth.PasswordPolicy
+	// InvitationURL is the registration page of the front-end. The
+	// invitation token is appended as the invite query parameter.
+	InvitationURL string
+	// OpenSignup lets anyone register without an invitation.
+	OpenSignup bool
+}
+
+// requiresMFA reports whether users with role must use a second factor.
+func (config AuthConfig) requiresMFA(role string) bool {
+	for _, required := range config.MFARequiredRoles {
+		if required == role {
+			return true
+		}
+	}
+	return false
 }
 
-func CreateAuthEndpoints(app *gin.Engine, usersRepo repository.IUsersRepository, authService auth.IAuthService) {
+func CreateAuthEndpoints(
+	app *gin.Engine,
+	usersRepo repository.IUsersRepository,
+	tokensRepo repository.IRefreshTokensRepository,
+	sessionsRepo repository.ISessionsRepository,
+	revokedRepo repository.IRevokedTokensRepository,
+	resetsRepo repository.IPasswordResetsRepository,
+	throttlesRepo repository.ILoginThrottlesRepository,
+	mfaRepo repository.IMFARepository,
+	invitationsRepo repository.IInvitationsRepository,
+	authService auth.IAuthService,
+	mailer mail.IMailer,
+	config AuthConfig) {
 	auth := app.Group("auth")
+	authenticated := middleware.AuthMiddleware(authService, revokedRepo, nil)
+	enrolling := middleware.AllowMFAEnrollment(authService, revokedRepo)
+
+	auth.POST("/login", Login(usersRepo, tokensRepo, sessionsRepo, throttlesRepo, mfaRepo, authService, config))
+	auth.POST("/refresh", Refresh(usersRepo, tokensRepo, sessionsRepo, revokedRepo, authService))
+	auth.POST("/register", Register(usersRepo, invitationsRepo, authService, mailer, config))
+	auth.GET("/verify", VerifyEmail(usersRepo, authService))
+	auth.POST("/verify/resend", ResendVerification(usersRepo, authService, mailer, config))
+	auth.POST("/logout", authenticated, Logout(tokensRepo, revokedRepo, authService))
+	auth.POST("/logout/all", authenticated, LogoutAll(tokensRepo, revokedRepo))
+	auth.GET("/me", authenticated, Me(usersRepo))
+	auth.PATCH("/me", authenticated, UpdateMe(usersRepo))
+	auth.GET("/sessions", authenticated, ListSessions(sessionsRepo))
+	auth.DELETE("/sessions/:id", authenticated, RevokeSession(sessionsRepo, tokensRepo, revokedRepo))
+	auth.POST("/me/password", authenticated, ChangePassword(usersRepo, tokensRepo, sessionsRepo, revokedRepo, throttlesRepo, authService, config))
+	auth.POST("/password/forgot", ForgotPassword(usersRepo, resetsRepo, authService, mailer, config))
+	auth.POST("/password/reset", ResetPassword(usersRepo, resetsRepo, tokensRepo, revokedRepo, authService, config))
+	auth.POST("/mfa/verify", VerifyMFA(usersRepo, mfaRepo, tokensRepo, sessionsRepo, throttlesRepo, authService))
+	auth.POST("/mfa/enroll", enrolling, EnrollMFA(usersRepo, mfaRepo, config))
+	auth.POST("/mfa/activate", enrolling, ActivateMFA(usersRepo, mfaRepo, tokensRepo, sessionsRepo, authService))
+	auth.POST("/mfa/disable", authenticated, DisableMFA(mfaRepo, config))
+}
+
+func CreateOIDCEndpoints(
+	app *gin.Engine,
+	provider oidc.IProvider,
+	usersRepo repository.IUsersRepository,
+	identitiesRepo repository.IUserIdentitiesRepository,
+	tokensRepo repository.IRefreshTokensRepository,
+	sessionsRepo repository.ISessionsRepository,
+	mfaRepo repository.IMFARepository,
+	authService auth.IAuthService,
+	config AuthConfig,
+	oidcConfig OIDCConfig) {
+	oidc := app.Group("auth/oidc")
+
+	oidc.GET("/login", OIDCLogin(provider, authService, config))
+	oidc.GET("/callback", OIDCCallback(provider, usersRepo, identitiesRepo, tokensRepo, sessionsRepo, mfaRepo, authService, config, oidcConfig))
+}
+
+func CreateAdminEndpoints(
+	app *gin.Engine,
+	usersRepo repository.IUsersRepository,
+	tokensRepo repository.IRefreshTokensRepository,
+	throttlesRepo repository.ILoginThrottlesRepository,
+	revokedRepo repository.IRevokedTokensRepository,
+	mfaRepo repository.IMFARepository,
+	apiKeysRepo repository.IAPIKeysRepository,
+	invitationsRepo repository.IInvitationsRepository,
+	authService auth.IAuthService,
+	mailer mail.IMailer,
+	config AuthConfig) {
+	admin := app.Group("admin")
+	admin.Use(middleware.AuthMiddleware(authService, revokedRepo, nil), middleware.RequireRole(auth.RoleAdmin))
 
-	auth.POST("/login", Login(usersRepo, authService))
-	auth.POST("/register", Register(usersRepo, authService))
+	admin.GET("/users", ListUsers(usersRepo))
+	admin.GET("/users/:id", GetUser(usersRepo))
+	admin.PATCH("/users/:id", UpdateUser(usersRepo, tokensRepo, revokedRepo))
+	admin.DELETE("/users/:id", DeleteUser(usersRepo, tokensRepo, revokedRepo))
+	admin.POST("/users/:id/disable", DisableUser(usersRepo, tokensRepo, revokedRepo))
+	admin.POST("/users/:id/enable", EnableUser(usersRepo))
+	admin.POST("/users/:id/unlock", UnlockUser(usersRepo, throttlesRepo))
+	admin.DELETE("/users/:id/mfa", ResetUserMFA(mfaRepo))
+	admin.DELETE("/lockouts/ips/:ip", UnlockIP(throttlesRepo))
+	admin.POST("/api-keys", CreateAPIKey(apiKeysRepo, authService))
+	admin.GET("/api-keys", ListAPIKeys(apiKeysRepo))
+	admin.DELETE("/api-keys/:id", RevokeAPIKey(apiKeysRepo))
+	admin.POST("/invitations", CreateInvitation(usersRepo, invitationsRepo, authService, mailer, config))
+	admin.GET("/invitations", ListInvitations(invitationsRepo))
+	admin.DELETE("/invitations/:id", DeleteInvitation(invitationsRepo))
 }
@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/darolpz/students/cmd/handlers/middleware"
+	"github.com/darolpz/students/internal/auth"
+	"github.com/darolpz/students/internal/mail"
+	"github.com/darolpz/students/internal/model"
+	"github.com/darolpz/students/internal/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fakeStudentsRepository stands in for the students repository. It holds a
+// single student, answers every write with err, and counts the writes.
+type fakeStudentsRepository struct {
+	repository.IStudentsRepository
+	student  *model.Student
+	versions []model.StudentVersion
+	err      error
+	writes   int
+}
+
+func (f *fakeStudentsRepository) FindStudent(id string) (model.Student, error) {
+	if f.student == nil || strconv.Itoa(f.student.ID) != id {
+		return model.Student{}, repository.ErrStudentNotFound
+	}
+	return *f.student, nil
+}
+
+func (f *fakeStudentsRepository) ListStudentVersions(id int) ([]model.StudentVersion, error) {
+	return f.versions, nil
+}
+
+func (f *fakeStudentsRepository) FindStudentVersion(id int, version int) (model.StudentVersion, error) {
+	for _, v := range f.versions {
+		if v.Version == version {
+			return v, nil
+		}
+	}
+	return model.StudentVersion{}, repository.ErrStudentVersionNotFound
+}
+
+func (f *fakeStudentsRepository) CreateStudent(student model.Student, changedBy *int) (model.Student, error) {
+	f.writes++
+	student.ID, student.Version = 1, 1
+	return student, f.err
+}
+
+func (f *fakeStudentsRepository) UpdateStudent(id string, student model.Student, version int, changedBy *int) (model.Student, error) {
+	f.writes++
+	student.ID, student.Version = f.student.ID, f.student.Version+1
+	return student, f.err
+}
+
+func (f *fakeStudentsRepository) RestoreStudent(id int, restoredBy *int) (model.Student, error) {
+	f.writes++
+	student := *f.student
+	student.Version++
+	return student, f.err
+}
+
+func (f *fakeStudentsRepository) RevertStudent(id int, version int, current int, changedBy *int) (model.Student, error) {
+	f.writes++
+	student := *f.student
+	student.Version++
+	return student, f.err
+}
+
+// fakeUsersRepository stands in for the users repository. It holds a single
+// user, counts the users created and records the updates and passwords set.
+type fakeUsersRepository struct {
+	repository.IUsersRepository
+	user      *model.User
+	created   int
+	updates   []model.UserUpdate
+	passwords []string
+}
+
+func (f *fakeUsersRepository) FindUserByID(id int) (model.User, error) {
+	if f.user == nil || f.user.ID != id {
+		return model.User{}, repository.ErrUserNotFound
+	}
+	return *f.user, nil
+}
+
+func (f *fakeUsersRepository) FindUserByEmail(email string) (model.User, error) {
+	if f.user == nil || f.user.Email != email {
+		return model.User{}, repository.ErrUserNotFound
+	}
+	return *f.user, nil
+}
+
+func (f *fakeUsersRepository) CreateUser(user model.User) (model.User, error) {
+	f.created++
+	return user, nil
+}
+
+func (f *fakeUsersRepository) UpdateUser(id int, update model.UserUpdate) (model.User, error) {
+	user, err := f.FindUserByID(id)
+	if err != nil {
+		return user, err
+	}
+	f.updates = append(f.updates, update)
+	if update.Name != nil {
+		user.Name = *update.Name
+	}
+	if update.Role != nil {
+		user.Role = *update.Role
+	}
+	return user, nil
+}
+
+func (f *fakeUsersRepository) UpdateUserPassword(id int, password string) error {
+	f.passwords = append(f.passwords, password)
+	return nil
+}
+
+// fakeRefreshTokensRepository stands in for the refresh tokens repository.
+// It holds a single token, rotating it fails with rotateErr, and it records
+// the tokens created and the families revoked.
+type fakeRefreshTokensRepository struct {
+	repository.IRefreshTokensRepository
+	token           model.RefreshToken
+	rotateErr       error
+	created         []model.RefreshToken
+	revokedFamilies []string
+	revokedUsers    []int
+}
+
+func (f *fakeRefreshTokensRepository) CreateRefreshToken(token model.RefreshToken) (model.RefreshToken, error) {
+	f.created = append(f.created, token)
+	return token, nil
+}
+
+func (f *fakeRefreshTokensRepository) FindRefreshToken(hash string) (model.RefreshToken, error) {
+	if f.token.TokenHash != hash {
+		return model.RefreshToken{}, repository.ErrRefreshTokenNotFound
+	}
+	return f.token, nil
+}
+
+func (f *fakeRefreshTokensRepository) RotateRefreshToken(id int) error {
+	return f.rotateErr
+}
+
+func (f *fakeRefreshTokensRepository) RevokeRefreshTokenFamily(familyID string) error {
+	f.revokedFamilies = append(f.revokedFamilies, familyID)
+	return nil
+}
+
+func (f *fakeRefreshTokensRepository) RevokeUserRefreshTokens(userID int) error {
+	f.revokedUsers = append(f.revokedUsers, userID)
+	return nil
+}
+
+// fakeSessionsRepository stands in for the sessions repository. It holds
+// sessions and records the sessions created.
+type fakeSessionsRepository struct {
+	repository.ISessionsRepository
+	sessions []model.Session
+	created  []model.Session
+}
+
+func (f *fakeSessionsRepository) FindSession(id string) (model.Session, error) {
+	for _, session := range f.sessions {
+		if session.ID == id {
+			return session, nil
+		}
+	}
+	return model.Session{}, repository.ErrSessionNotFound
+}
+
+func (f *fakeSessionsRepository) ListSessions(userID int) ([]model.Session, error) {
+	sessions := []model.Session{}
+	for _, session := range f.sessions {
+		if session.UserID == userID {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions, nil
+}
+
+func (f *fakeSessionsRepository) CreateSession(session model.Session) (model.Session, error) {
+	f.created = append(f.created, session)
+	return session, nil
+}
+
+func (f *fakeSessionsRepository) TouchSession(id string) error {
+	return nil
+}
+
+// fakeRevokedTokensRepository stands in for the revoked tokens repository,
+// recording the entries created. Only entries revoking a token or a session
+// are checked.
+type fakeRevokedTokensRepository struct {
+	repository.IRevokedTokensRepository
+	revoked []model.RevokedToken
+}
+
+func (f *fakeRevokedTokensRepository) CreateRevokedToken(token model.RevokedToken) (model.RevokedToken, error) {
+	f.revoked = append(f.revoked, token)
+	return token, nil
+}
+
+func (f *fakeRevokedTokensRepository) IsTokenRevoked(jti, sessionID string, userID int, issuedAt time.Time) (bool, error) {
+	for _, token := range f.revoked {
+		if (token.JTI != "" && token.JTI == jti) || (token.SessionID != "" && token.SessionID == sessionID) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// fakeInvitationsRepository stands in for the invitations repository. It
+// holds invitations and records the users registered through them.
+type fakeInvitationsRepository struct {
+	repository.IInvitationsRepository
+	invitations []model.Invitation
+	redeemed    []model.User
+}
+
+func (f *fakeInvitationsRepository) CreateInvitation(invitation model.Invitation) (model.Invitation, error) {
+	invitation.ID = len(f.invitations) + 1
+	f.invitations = append(f.invitations, invitation)
+	return invitation, nil
+}
+
+func (f *fakeInvitationsRepository) ListInvitations() ([]model.Invitation, error) {
+	return f.invitations, nil
+}
+
+func (f *fakeInvitationsRepository) FindInvitation(hash string) (model.Invitation, error) {
+	for _, invitation := range f.invitations {
+		if invitation.TokenHash == hash {
+			return invitation, nil
+		}
+	}
+	return model.Invitation{}, repository.ErrInvitationNotFound
+}
+
+func (f *fakeInvitationsRepository) RedeemInvitation(id int, user model.User) (model.User, error) {
+	f.redeemed = append(f.redeemed, user)
+	return user, nil
+}
+
+func (f *fakeInvitationsRepository) DeleteInvitation(id int) error {
+	for i, invitation := range f.invitations {
+		if invitation.ID == id && invitation.UsedAt == nil {
+			f.invitations = append(f.invitations[:i], f.invitations[i+1:]...)
+			return nil
+		}
+	}
+	return repository.ErrInvitationNotFound
+}
+
+// nopMailer drops every message.
+type nopMailer struct{}
+
+func (nopMailer) Send(msg mail.Message) error {
+	return nil
+}
+
+// fakeLoginThrottlesRepository stands in for the login throttles
+// repository. It holds throttles and records the failures counted.
+type fakeLoginThrottlesRepository struct {
+	repository.ILoginThrottlesRepository
+	throttles []model.LoginThrottle
+	failures  []string
+}
+
+func (f *fakeLoginThrottlesRepository) FindLoginThrottles(ids []string) ([]model.LoginThrottle, error) {
+	return f.throttles, nil
+}
+
+func (f *fakeLoginThrottlesRepository) RecordLoginFailure(id string, resetBefore time.Time) error {
+	f.failures = append(f.failures, id)
+	return nil
+}
+
+// asUser runs handler as AuthMiddleware would for an access token of the
+// user with userID.
+func asUser(userID int, handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(middleware.ClaimsKey, &auth.JWTClaim{
+			Role:             "user",
+			RegisteredClaims: jwt.RegisteredClaims{Subject: strconv.Itoa(userID)},
+		})
+		handler(c)
+	}
+}
+
+// bearer returns the Authorization header of an access token of user issued
+// to session.
+func bearer(t *testing.T, authService auth.IAuthService, user model.User, session string) string {
+	token, err := authService.GenerateJWT(user, session)
+	require.NoError(t, err)
+	return "Bearer " + token
+}
+
+// newAuthService returns an auth service signing with a fresh key and
+// hashing passwords at the cheapest bcrypt cost.
+func newAuthService(t *testing.T) auth.IAuthService {
+	keys, err := auth.GenerateKeySet()
+	require.NoError(t, err)
+	hasher, err := auth.NewBcryptHasher(bcrypt.MinCost)
+	require.NoError(t, err)
+	return auth.NewAuthService(keys, hasher)
+}
+
+// serve sends a request for path to handler, routed as method route, and
+// returns the response. headers are pairs of names and values.
+func serve(handler gin.HandlerFunc, method, route, path, body string, headers ...string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	app := gin.New()
+	app.Handle(method, route, handler)
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	return w
+}
+
+// johnDoe returns a student as the repository holds it.
+func johnDoe() *model.Student {
+	return &model.Student{ID: 1, FirstName: "John", LastName: "Doe", Age: 33, Email: "john.doe@gmail.com", Version: 3}
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/darolpz/students/internal/model"
+	"github.com/darolpz/students/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+var ErrInvalidVersion = errors.New("invalid version")
+
+// ListStudentHistory godoc
+// @Summary      List student history
+// @Description  returns every version of a student, oldest first, with who made each change, when, and the fields it changed. Deleted students must be restored first
+// @Tags         students
+// @Param        student_id  path string  true  "student_id"  1
+// @Produce      json
+// @Success      200 {object} map[string]interface{}
+// @Failure      400 {string} string
+// @Failure      404 {string} string
+// @Failure      500 {string} string
+// @Failure      403 {object} map[string]string
+// @Router       /students/{student_id}/history [get]
+// @Security Authorization
+func ListStudentHistory(studentsRepo repository.IStudentsRepository) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		// Get query params
+		studentID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.String(http.StatusBadRequest, ErrInvalidStudentID.Error())
+			return
+		}
+		if _, ok := findStudent(c, studentsRepo, strconv.Itoa(studentID)); !ok {
+			return
+		}
+
+		versions, err := studentsRepo.ListStudentVersions(studentID)
+		if err != nil {
+			log.Printf("could not list history of student %d: %s", studentID, err)
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		// An empty history is an empty list rather than null
+		if versions == nil {
+			versions = []model.StudentVersion{}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"history": versions,
+		})
+	}
+}
+
+// GetStudentVersion godoc
+// @Summary      Get student version
+// @Description  returns a version of a student: the change that produced it and the student as it was left. Deleted students must be restored first
+// @Tags         students
+// @Param        student_id  path string  true  "student_id"  1
+// @Param        version     path int     true  "version"     1
+// @Produce      json
+// @Success      200 {object} model.StudentVersion
+// @Failure      400 {string} string
+// @Failure      404 {string} string
+// @Failure      500 {string} string
+// @Failure      403 {object} map[string]string
+// @Router       /students/{student_id}/history/{version} [get]
+// @Security Authorization
+func GetStudentVersion(studentsRepo repository.IStudentsRepository) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		// Get query params
+		studentID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.String(http.StatusBadRequest, ErrInvalidStudentID.Error())
+			return
+		}
+		version, err := strconv.Atoi(c.Param("version"))
+		if err != nil || version < 1 {
+			c.String(http.StatusBadRequest, ErrInvalidVersion.Error())
+			return
+		}
+		if _, ok := findStudent(c, studentsRepo, strconv.Itoa(studentID)); !ok {
+			return
+		}
+
+		v, err := studentsRepo.FindStudentVersion(studentID, version)
+		if err != nil {
+			if errors.Is(err, repository.ErrStudentVersionNotFound) {
+				c.String(http.StatusNotFound, err.Error())
+				return
+			}
+			log.Printf("could not find version %d of student %d: %s", version, studentID, err)
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"version": v,
+		})
+	}
+}
+
+// RevertStudent godoc
+// @Summary      Revert student
+// @Description  sets the fields of a student back to the values of one of its versions, recorded as a new version. It fails when another student has taken the email of that version since. With an If-Match header the student is only reverted if it still has that ETag, which can be required by configuration
+// @Tags         students
+// @Param        student_id  path string  true  "student_id"  1
+// @Param        version     path int     true  "version"     1
+// @Param        If-Match    header string  false  "ETag of the student as it was read"
+// @Produce      json
+// @Success      200 {object} model.Student
+// @Header       200 {string} ETag "new version of the student"
+// @Failure      400 {string} string
+// @Failure      404 {string} string
+// @Failure      409 {string} string
+// @Failure      412 {string} string
+// @Failure      428 {string} string
+// @Failure      500 {string} string
+// @Failure      403 {object} map[string]string
+// @Router       /students/{student_id}/revert/{version} [post]
+// @Security Authorization
+func RevertStudent(studentsRepo repository.IStudentsRepository, config StudentsConfig) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		// Get query params
+		studentID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.String(http.StatusBadRequest, ErrInvalidStudentID.Error())
+			return
+		}
+		version, err := strconv.Atoi(c.Param("version"))
+		if err != nil || version < 1 {
+			c.String(http.StatusBadRequest, ErrInvalidVersion.Error())
+			return
+		}
+		current, ok := ifMatch(c, studentsRepo, strconv.Itoa(studentID), config.RequireIfMatch)
+		if !ok {
+			return
+		}
+
+		student, err := studentsRepo.RevertStudent(studentID, version, current, callerID(c))
+		if err != nil {
+			if errors.Is(err, repository.ErrStudentNotFound) || errors.Is(err, repository.ErrStudentVersionNotFound) {
+				c.String(http.StatusNotFound, err.Error())
+				return
+			}
+			if errors.Is(err, repository.ErrStudentModified) {
+				c.String(http.StatusPreconditionFailed, ErrPreconditionFailed.Error())
+				return
+			}
+			if errors.Is(err, repository.ErrDuplicateEmail) {
+				c.String(http.StatusConflict, err.Error())
+				return
+			}
+			log.Printf("could not revert student %d to version %d: %s", studentID, version, err)
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		c.Header("ETag", studentETag(student))
+		c.JSON(http.StatusOK, gin.H{
+			"student": student,
+		})
+	}
+}
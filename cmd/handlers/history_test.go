@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/darolpz/students/internal/model"
+	"github.com/darolpz/students/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListStudentHistory(t *testing.T) {
+	tests := []struct {
+		name           string
+		repo           *fakeStudentsRepository
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "should_list_history",
+			repo: &fakeStudentsRepository{student: johnDoe(), versions: []model.StudentVersion{
+				{StudentID: 1, Version: 1, Action: model.StudentCreated, FirstName: "John", LastName: "Doe", Age: 33, Email: "john.doe@gmail.com"},
+			}},
+			path:           "/students/1/history",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"version":1`,
+		},
+		{
+			name:           "should_return_empty_history",
+			repo:           &fakeStudentsRepository{student: johnDoe()},
+			path:           "/students/1/history",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"history":[]}`,
+		},
+		{
+			name:           "should_return_bad_request_invalid_id",
+			repo:           &fakeStudentsRepository{student: johnDoe()},
+			path:           "/students/1=1/history",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   ErrInvalidStudentID.Error(),
+		},
+		{
+			name:           "should_return_not_found",
+			repo:           &fakeStudentsRepository{student: johnDoe()},
+			path:           "/students/2/history",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   repository.ErrStudentNotFound.Error(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(ListStudentHistory(tt.repo), http.MethodGet, "/students/:id/history", tt.path, "")
+			require.Equal(t, tt.expectedStatus, w.Code)
+			require.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+func TestGetStudentVersion(t *testing.T) {
+	versions := []model.StudentVersion{
+		{StudentID: 1, Version: 1, Action: model.StudentCreated, FirstName: "John", LastName: "Doe", Age: 33, Email: "john.doe@gmail.com"},
+	}
+
+	tests := []struct {
+		name           string
+		student        *model.Student
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "should_get_version",
+			student:        johnDoe(),
+			path:           "/students/1/history/1",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"version":1`,
+		},
+		{
+			name:           "should_return_bad_request_invalid_version",
+			student:        johnDoe(),
+			path:           "/students/1/history/first",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   ErrInvalidVersion.Error(),
+		},
+		{
+			name:           "should_return_not_found_version",
+			student:        johnDoe(),
+			path:           "/students/1/history/2",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   repository.ErrStudentVersionNotFound.Error(),
+		},
+		{
+			name:           "should_return_not_found_deleted_student",
+			path:           "/students/1/history/1",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   repository.ErrStudentNotFound.Error(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeStudentsRepository{student: tt.student, versions: versions}
+			w := serve(GetStudentVersion(repo), http.MethodGet, "/students/:id/history/:version", tt.path, "")
+			require.Equal(t, tt.expectedStatus, w.Code)
+			require.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+func TestRevertStudent(t *testing.T) {
+	tests := []struct {
+		name           string
+		config         StudentsConfig
+		headers        []string
+		err            error
+		expectedStatus int
+		expectedETag   string
+		expectedWrites int
+	}{
+		{
+			name:           "should_revert_student",
+			expectedStatus: http.StatusOK,
+			expectedETag:   `"4"`,
+			expectedWrites: 1,
+		},
+		{
+			name:           "should_revert_student_matching_etag",
+			config:         StudentsConfig{RequireIfMatch: true},
+			headers:        []string{"If-Match", `"3"`},
+			expectedStatus: http.StatusOK,
+			expectedETag:   `"4"`,
+			expectedWrites: 1,
+		},
+		{
+			name:           "should_return_precondition_failed_stale_etag",
+			headers:        []string{"If-Match", `"2"`},
+			expectedStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:           "should_return_precondition_required",
+			config:         StudentsConfig{RequireIfMatch: true},
+			expectedStatus: http.StatusPreconditionRequired,
+		},
+		{
+			name:           "should_return_precondition_failed_student_modified",
+			err:            repository.ErrStudentModified,
+			expectedStatus: http.StatusPreconditionFailed,
+			expectedWrites: 1,
+		},
+		{
+			name:           "should_return_conflict_email_taken",
+			err:            repository.ErrDuplicateEmail,
+			expectedStatus: http.StatusConflict,
+			expectedWrites: 1,
+		},
+		{
+			name:           "should_return_not_found_version",
+			err:            repository.ErrStudentVersionNotFound,
+			expectedStatus: http.StatusNotFound,
+			expectedWrites: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeStudentsRepository{student: johnDoe(), err: tt.err}
+			w := serve(RevertStudent(repo, tt.config), http.MethodPost, "/students/:id/revert/:version", "/students/1/revert/1", "", tt.headers...)
+			require.Equal(t, tt.expectedStatus, w.Code)
+			require.Equal(t, tt.expectedETag, w.Header().Get("ETag"))
+			require.Equal(t, tt.expectedWrites, repo.writes)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/darolpz/students/internal/importer"
+	"github.com/darolpz/students/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxImportSize is the largest import body accepted, in bytes.
+	maxImportSize = 10 << 20
+)
+
+// ImportStudents godoc
+// @Summary      Import students
+// @Description  creates or updates students in bulk from a CSV file with a header row, or from JSON objects one per line. Headers name the field of their column, first_name, last_name, age and email, unless map renames them, as in map[Given Name]=first_name. Students whose email is taken replace the student that has it, unless it is deleted, which fails the row until the student is restored. Invalid rows are skipped and reported by line; an atomic import writes nothing unless every row is valid, and a dry run only reports what the import would do. Imports need no If-Match header even when writes to students require one.
+// @Tags         students
+// @Accept       text/csv
+// @Accept       application/x-ndjson
+// @Produce      json
+// @Param        students  body   string  true   "CSV or NDJSON students"
+// @Param        dry_run   query  bool    false  "only report what the import would do"
+// @Param        atomic    query  bool    false  "write every row or none of them"
+// @Param        map       query  string  false  "header mapping, as map[header]=field"
+// @Success      200 {object} map[string]importer.Report
+// @Failure      400 {string} string
+// @Failure      413 {string} string
+// @Failure      415 {string} string
+// @Failure      422 {object} map[string]importer.Report
+// @Failure      500 {object} map[string]importer.Report
+// @Failure      403 {object} map[string]string
+// @Router       /students/import [post]
+// @Security Authorization
+func ImportStudents(studentsRepo repository.IStudentsRepository) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		// Get query params
+		var options importer.Options
+		if err := c.ShouldBindQuery(&options); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+
+		body := http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+		rows, err := importer.Read(body, c.ContentType(), c.QueryMap("map"))
+		if err != nil {
+			switch {
+			case errors.Is(err, importer.ErrUnsupportedFormat):
+				c.String(http.StatusUnsupportedMediaType, err.Error())
+			case errors.Is(err, importer.ErrTooManyRows):
+				c.String(http.StatusRequestEntityTooLarge, err.Error())
+			default:
+				c.String(http.StatusBadRequest, err.Error())
+			}
+			return
+		}
+
+		report, err := importer.Import(studentsRepo, rows, options, callerID(c))
+		if err != nil {
+			log.Printf("could not import students: %s", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"import": report,
+			})
+			return
+		}
+
+		// An atomic import is refused as a whole when any row is invalid
+		status := http.StatusOK
+		if options.Atomic && report.Failed > 0 {
+			status = http.StatusUnprocessableEntity
+		}
+		c.JSON(status, gin.H{
+			"import": report,
+		})
+	}
+}
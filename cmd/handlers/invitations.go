@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/darolpz/students/cmd/handlers/middleware"
+	"github.com/darolpz/students/internal/auth"
+	"github.com/darolpz/students/internal/mail"
+	"github.com/darolpz/students/internal/model"
+	"github.com/darolpz/students/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrInvalidInvitationID  = errors.New("invalid invitation id")
+	ErrInvitationEmail      = errors.New("invitation email is required")
+	ErrInvitationExpiresAt  = errors.New("invitation expiry must be in the future")
+	ErrEmailTaken           = errors.New("email address already registered")
+	ErrInvalidInvitation    = errors.New("invalid or expired invitation")
+	ErrInvitationMismatch   = errors.New("email address does not match the invitation")
+	ErrSignupClosed         = errors.New("registration requires an invitation")
+	ErrInvitationNotPending = errors.New("invitation not found or already used")
+)
+
+// CreateInvitation godoc
+// @Summary      Create invitation
+// @Description  invite an email address to register with the given role. The invitation is emailed and its token is only returned once.
+// @Tags         admin
+// @Param        invitation body model.NewInvitation true "NewInvitation"
+// @Accept       json
+// @Produce      json
+// @Success      201 {object} model.CreatedInvitation
+// @Failure      400 {string} string
+// @Failure      401 {string} string
+// @Failure      403 {object} map[string]string
+// @Failure      409 {string} string
+// @Failure      500 {string} string
+// @Router       /admin/invitations [post]
+// @Security Authorization
+func CreateInvitation(
+	usersRepo repository.IUsersRepository,
+	invitationsRepo repository.IInvitationsRepository,
+	authService auth.IAuthService,
+	mailer mail.IMailer,
+	config AuthConfig) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		claims, _ := middleware.Claims(c)
+		userID, err := claims.UserID()
+		if err != nil {
+			c.String(http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		var newInvitation model.NewInvitation
+		// Bind the JSON request body to the newInvitation struct.
+		if err := c.BindJSON(&newInvitation); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+
+		email := strings.TrimSpace(newInvitation.Email)
+		if email == "" {
+			c.String(http.StatusBadRequest, ErrInvitationEmail.Error())
+			return
+		}
+		role := newInvitation.Role
+		if role == "" {
+			role = auth.RoleUser
+		}
+		if !auth.IsRole(role) {
+			c.String(http.StatusBadRequest, ErrInvalidRole.Error())
+			return
+		}
+		expiresAt := time.Now().Add(auth.InvitationDuration)
+		if newInvitation.ExpiresAt != nil {
+			if !newInvitation.ExpiresAt.After(time.Now()) {
+				c.String(http.StatusBadRequest, ErrInvitationExpiresAt.Error())
+				return
+			}
+			expiresAt = *newInvitation.ExpiresAt
+		}
+
+		// Nobody can be invited twice
+		_, err = usersRepo.FindUserByEmail(email)
+		if err == nil {
+			c.String(http.StatusConflict, ErrEmailTaken.Error())
+			return
+		}
+		if !errors.Is(err, repository.ErrUserNotFound) {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		// Generate the token. Only its hash is stored.
+		token, hash, err := authService.GenerateOpaqueToken()
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		invitation, err := invitationsRepo.CreateInvitation(model.Invitation{
+			Email:     email,
+			Role:      role,
+			TokenHash: hash,
+			CreatedBy: userID,
+			ExpiresAt: expiresAt,
+		})
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		sendInBackground(mailer, mail.Message{
+			To:      invitation.Email,
+			Subject: "You are invited",
+			Body: fmt.Sprintf("Hi,\n\nYou have been invited to create an account. Use the link below before %s.\n\n%s?invite=%s\n",
+				invitation.ExpiresAt.Format(time.RFC1123), config.InvitationURL, url.QueryEscape(token)),
+		})
+
+		c.JSON(http.StatusCreated, model.CreatedInvitation{Invitation: invitation, Token: token})
+	}
+}
+
+// ListInvitations godoc
+// @Summary      List invitations
+// @Description  list every invitation, including used and expired ones
+// @Tags         admin
+// @Produce      json
+// @Success      200 {array} model.Invitation
+// @Failure      401 {string} string
+// @Failure      403 {object} map[string]string
+// @Failure      500 {string} string
+// @Router       /admin/invitations [get]
+// @Security Authorization
+func ListInvitations(invitationsRepo repository.IInvitationsRepository) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		invitations, err := invitationsRepo.ListInvitations()
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		c.JSON(http.StatusOK, invitations)
+	}
+}
+
+// DeleteInvitation godoc
+// @Summary      Delete invitation
+// @Description  withdraw an invitation that was not used yet
+// @Tags         admin
+// @Param        invitation_id  path string  true  "invitation_id"  1
+// @Success      200 {string} string
+// @Failure      400 {string} string
+// @Failure      401 {string} string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {string} string
+// @Failure      500 {string} string
+// @Router       /admin/invitations/{invitation_id} [delete]
+// @Security Authorization
+func DeleteInvitation(invitationsRepo repository.IInvitationsRepository) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		// Get query params
+		invitationID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.String(http.StatusBadRequest, ErrInvalidInvitationID.Error())
+			return
+		}
+
+		if err := invitationsRepo.DeleteInvitation(invitationID); err != nil {
+			if errors.Is(err, repository.ErrInvitationNotFound) {
+				c.String(http.StatusNotFound, ErrInvitationNotPending.Error())
+				return
+			}
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		c.String(http.StatusOK, "invitation deleted")
+	}
+}
+
+// findInvitation retrieves the invitation token belongs to, as long as it can
+// still be redeemed.
+func findInvitation(invitationsRepo repository.IInvitationsRepository, authService auth.IAuthService, token string) (model.Invitation, error) {
+	invitation, err := invitationsRepo.FindInvitation(authService.HashToken(token))
+	if err != nil {
+		if errors.Is(err, repository.ErrInvitationNotFound) {
+			return invitation, ErrInvalidInvitation
+		}
+		return invitation, err
+	}
+	if invitation.UsedAt != nil || time.Now().After(invitation.ExpiresAt) {
+		return invitation, ErrInvalidInvitation
+	}
+	return invitation, nil
+}
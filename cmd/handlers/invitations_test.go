@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/darolpz/students/internal/auth"
+	"github.com/darolpz/students/internal/model"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvitationEndpoints(t *testing.T) {
+	authService := newAuthService(t)
+	admin := model.User{ID: 1, Name: "Admin", Email: "admin@school.edu", Role: auth.RoleAdmin}
+	user := model.User{ID: 2, Name: "John", Email: "john.doe@gmail.com", Role: auth.RoleUser}
+	adminToken := bearer(t, authService, admin, "admin-session")
+	userToken := bearer(t, authService, user, "user-session")
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		token          string
+		expectedStatus int
+		expectedCount  int
+	}{
+		{
+			name:           "should_create_invitation",
+			method:         http.MethodPost,
+			path:           "/admin/invitations",
+			body:           `{"email":"jane@gmail.com","role":"admin"}`,
+			token:          adminToken,
+			expectedStatus: http.StatusCreated,
+			expectedCount:  3,
+		},
+		{
+			name:           "should_return_unauthorized_create_without_token",
+			method:         http.MethodPost,
+			path:           "/admin/invitations",
+			body:           `{"email":"jane@gmail.com"}`,
+			expectedStatus: http.StatusUnauthorized,
+			expectedCount:  2,
+		},
+		{
+			name:           "should_return_forbidden_create_as_user",
+			method:         http.MethodPost,
+			path:           "/admin/invitations",
+			body:           `{"email":"jane@gmail.com"}`,
+			token:          userToken,
+			expectedStatus: http.StatusForbidden,
+			expectedCount:  2,
+		},
+		{
+			name:           "should_return_conflict_email_taken",
+			method:         http.MethodPost,
+			path:           "/admin/invitations",
+			body:           `{"email":"admin@school.edu"}`,
+			token:          adminToken,
+			expectedStatus: http.StatusConflict,
+			expectedCount:  2,
+		},
+		{
+			name:           "should_return_bad_request_invalid_role",
+			method:         http.MethodPost,
+			path:           "/admin/invitations",
+			body:           `{"email":"jane@gmail.com","role":"root"}`,
+			token:          adminToken,
+			expectedStatus: http.StatusBadRequest,
+			expectedCount:  2,
+		},
+		{
+			name:           "should_list_invitations",
+			method:         http.MethodGet,
+			path:           "/admin/invitations",
+			token:          adminToken,
+			expectedStatus: http.StatusOK,
+			expectedCount:  2,
+		},
+		{
+			name:           "should_return_forbidden_list_as_user",
+			method:         http.MethodGet,
+			path:           "/admin/invitations",
+			token:          userToken,
+			expectedStatus: http.StatusForbidden,
+			expectedCount:  2,
+		},
+		{
+			name:           "should_delete_pending_invitation",
+			method:         http.MethodDelete,
+			path:           "/admin/invitations/1",
+			token:          adminToken,
+			expectedStatus: http.StatusOK,
+			expectedCount:  1,
+		},
+		{
+			name:           "should_return_not_found_used_invitation",
+			method:         http.MethodDelete,
+			path:           "/admin/invitations/2",
+			token:          adminToken,
+			expectedStatus: http.StatusNotFound,
+			expectedCount:  2,
+		},
+		{
+			name:           "should_return_not_found_missing_invitation",
+			method:         http.MethodDelete,
+			path:           "/admin/invitations/3",
+			token:          adminToken,
+			expectedStatus: http.StatusNotFound,
+			expectedCount:  2,
+		},
+		{
+			name:           "should_return_bad_request_invalid_id",
+			method:         http.MethodDelete,
+			path:           "/admin/invitations/1=1",
+			token:          adminToken,
+			expectedStatus: http.StatusBadRequest,
+			expectedCount:  2,
+		},
+		{
+			name:           "should_return_unauthorized_delete_without_token",
+			method:         http.MethodDelete,
+			path:           "/admin/invitations/1",
+			expectedStatus: http.StatusUnauthorized,
+			expectedCount:  2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usedAt := time.Now()
+			invitationsRepo := &fakeInvitationsRepository{invitations: []model.Invitation{
+				{ID: 1, Email: "jane@gmail.com", Role: auth.RoleUser, TokenHash: authService.HashToken("pending"), ExpiresAt: time.Now().Add(time.Hour)},
+				{ID: 2, Email: "jim@gmail.com", Role: auth.RoleUser, TokenHash: authService.HashToken("used"), ExpiresAt: time.Now().Add(time.Hour), UsedAt: &usedAt},
+			}}
+			gin.SetMode(gin.TestMode)
+			app := gin.New()
+			CreateAdminEndpoints(app, &fakeUsersRepository{user: &admin}, &fakeRefreshTokensRepository{}, nil,
+				&fakeRevokedTokensRepository{}, nil, nil, invitationsRepo, authService, nopMailer{}, AuthConfig{})
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", tt.token)
+			}
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, req)
+			require.Equal(t, tt.expectedStatus, w.Code)
+			require.Len(t, invitationsRepo.invitations, tt.expectedCount)
+		})
+	}
+}
+
+func TestRegister_Invitation(t *testing.T) {
+	authService := newAuthService(t)
+
+	tests := []struct {
+		name           string
+		path           string
+		body           string
+		expiresAt      time.Time
+		used           bool
+		expectedStatus int
+		expectedRole   string
+	}{
+		{
+			name:           "should_register_with_invited_role",
+			path:           "/auth/register?invite=token",
+			body:           `{"name":"Jane","password":"correct horse battery"}`,
+			expiresAt:      time.Now().Add(time.Hour),
+			expectedStatus: http.StatusOK,
+			expectedRole:   auth.RoleAdmin,
+		},
+		{
+			name:           "should_return_forbidden_signup_closed",
+			path:           "/auth/register",
+			body:           `{"name":"Jane","email":"jane@gmail.com","password":"correct horse battery"}`,
+			expiresAt:      time.Now().Add(time.Hour),
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "should_return_bad_request_unknown_invitation",
+			path:           "/auth/register?invite=unknown",
+			body:           `{"name":"Jane","password":"correct horse battery"}`,
+			expiresAt:      time.Now().Add(time.Hour),
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "should_return_bad_request_expired_invitation",
+			path:           "/auth/register?invite=token",
+			body:           `{"name":"Jane","password":"correct horse battery"}`,
+			expiresAt:      time.Now().Add(-time.Hour),
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "should_return_bad_request_used_invitation",
+			path:           "/auth/register?invite=token",
+			body:           `{"name":"Jane","password":"correct horse battery"}`,
+			expiresAt:      time.Now().Add(time.Hour),
+			used:           true,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "should_return_bad_request_other_email",
+			path:           "/auth/register?invite=token",
+			body:           `{"name":"Jane","email":"jim@gmail.com","password":"correct horse battery"}`,
+			expiresAt:      time.Now().Add(time.Hour),
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invitation := model.Invitation{ID: 1, Email: "jane@gmail.com", Role: auth.RoleAdmin, TokenHash: authService.HashToken("token"), ExpiresAt: tt.expiresAt}
+			if tt.used {
+				usedAt := time.Now()
+				invitation.UsedAt = &usedAt
+			}
+			usersRepo := &fakeUsersRepository{}
+			invitationsRepo := &fakeInvitationsRepository{invitations: []model.Invitation{invitation}}
+			handler := Register(usersRepo, invitationsRepo, authService, nopMailer{}, AuthConfig{PasswordPolicy: auth.DefaultPasswordPolicy})
+
+			w := serve(handler, http.MethodPost, "/auth/register", tt.path, tt.body)
+			require.Equal(t, tt.expectedStatus, w.Code)
+			// Nobody registers without the invitation while signup is closed
+			require.Zero(t, usersRepo.created)
+			if tt.expectedRole == "" {
+				require.Empty(t, invitationsRepo.redeemed)
+				return
+			}
+			var body struct {
+				User model.User `json:"user"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			require.Equal(t, tt.expectedRole, body.User.Role)
+			require.Equal(t, "jane@gmail.com", body.User.Email)
+			require.NotNil(t, body.User.VerifiedAt)
+			require.Len(t, invitationsRepo.redeemed, 1)
+		})
+	}
+}
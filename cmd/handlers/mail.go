@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	"log"
+
+	"github.com/darolpz/students/internal/mail"
+)
+
+// sendInBackground delivers msg without making the request wait for it, so
+// that response times do not reveal whether an account exists.
+func sendInBackground(mailer mail.IMailer, msg mail.Message) {
+	go func() {
+		if err := mailer.Send(msg); err != nil {
+			log.Printf("could not send %q: %s", msg.Subject, err)
+		}
+	}()
+}
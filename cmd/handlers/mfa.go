@@ -0,0 +1,487 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/darolpz/students/cmd/handlers/middleware"
+	"github.com/darolpz/students/internal/auth"
+	"github.com/darolpz/students/internal/model"
+	"github.com/darolpz/students/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// recoveryCodesCount is how many recovery codes a user gets on activation.
+const recoveryCodesCount = 10
+
+var (
+	ErrInvalidMFAToken    = errors.New("invalid mfa token")
+	ErrInvalidMFACode     = errors.New("invalid mfa code")
+	ErrTooManyMFAAttempts = errors.New("too many invalid mfa codes, try again later")
+	ErrMFAAlreadyEnabled  = errors.New("mfa is already enabled")
+	ErrMFANotEnabled      = errors.New("mfa is not enabled")
+	ErrMFARequired        = errors.New("mfa is required for this account")
+)
+
+// VerifyMFA godoc
+// @Summary      Verify second factor
+// @Description  exchange the challenge token of a login and a TOTP or recovery code for tokens
+// @Tags         mfa
+// @Param        verification body model.MFAVerification true "MFAVerification"
+// @Accept       json
+// @Produce      json
+// @Success      200 {object} model.TokenPair
+// @Failure      400 {string} string
+// @Failure      401 {string} string
+// @Failure      403 {string} string
+// @Failure      429 {string} string
+// @Failure      500 {string} string
+// @Router       /auth/mfa/verify [post]
+func VerifyMFA(
+	usersRepo repository.IUsersRepository,
+	mfaRepo repository.IMFARepository,
+	tokensRepo repository.IRefreshTokensRepository,
+	sessionsRepo repository.ISessionsRepository,
+	throttlesRepo repository.ILoginThrottlesRepository,
+	authService auth.IAuthService) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var verification model.MFAVerification
+		// Bind the JSON request body to the verification struct.
+		if err := c.BindJSON(&verification); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Check the challenge token
+		claims, err := authService.CheckMFAToken(verification.MFAToken, auth.MFAChallenge)
+		if err != nil {
+			c.String(http.StatusUnauthorized, ErrInvalidMFAToken.Error())
+			return
+		}
+		userID, err := claims.UserID()
+		if err != nil {
+			c.String(http.StatusUnauthorized, ErrInvalidMFAToken.Error())
+			return
+		}
+
+		// Check if the second factor of the user has to wait
+		throttleID := auth.MFAThrottleID(userID)
+		throttles, err := throttlesRepo.FindLoginThrottles([]string{throttleID})
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		for _, throttle := range throttles {
+			if retryAfter := auth.AccountLockout.RetryAfter(throttle, time.Now()); retryAfter > 0 {
+				c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				c.String(http.StatusTooManyRequests, ErrTooManyMFAAttempts.Error())
+				return
+			}
+		}
+
+		enrollment, err := mfaRepo.FindMFAEnrollment(userID)
+		if err != nil {
+			if errors.Is(err, repository.ErrMFAEnrollmentNotFound) {
+				c.String(http.StatusUnauthorized, ErrInvalidMFAToken.Error())
+				return
+			}
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		if enrollment.EnabledAt == nil {
+			c.String(http.StatusUnauthorized, ErrInvalidMFAToken.Error())
+			return
+		}
+
+		// Check the code, or burn a recovery code
+		if verification.RecoveryCode != "" {
+			err = mfaRepo.UseMFARecoveryCode(userID, authService.HashToken(auth.NormalizeRecoveryCode(verification.RecoveryCode)))
+		} else {
+			err = useTOTPCode(mfaRepo, enrollment, verification.Code)
+		}
+		if err != nil {
+			if !isInvalidMFACode(err) {
+				c.String(http.StatusInternalServerError, err.Error())
+				return
+			}
+			if err := throttlesRepo.RecordLoginFailure(throttleID, time.Now().Add(-auth.AccountLockout.LockoutDuration)); err != nil {
+				c.String(http.StatusInternalServerError, err.Error())
+				return
+			}
+			c.String(http.StatusUnauthorized, ErrInvalidMFACode.Error())
+			return
+		}
+
+		if err := throttlesRepo.DeleteLoginThrottle(throttleID); err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		// Retrieve the user the challenge was issued to
+		user, err := usersRepo.FindUserByID(userID)
+		if err != nil {
+			if errors.Is(err, repository.ErrUserNotFound) {
+				c.String(http.StatusUnauthorized, ErrInvalidMFAToken.Error())
+				return
+			}
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		if user.DisabledAt != nil {
+			c.String(http.StatusForbidden, ErrAccountDisabled.Error())
+			return
+		}
+
+		tokens, err := startSession(c, user, sessionsRepo, tokensRepo, authService)
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		c.JSON(http.StatusOK, tokens)
+	}
+}
+
+// EnrollMFA godoc
+// @Summary      Enroll second factor
+// @Description  create a TOTP secret to add to an authenticator app. It has to be activated with a code.
+// @Tags         mfa
+// @Produce      json
+// @Success      200 {object} model.MFASetup
+// @Failure      401 {string} string
+// @Failure      409 {string} string
+// @Failure      500 {string} string
+// @Router       /auth/mfa/enroll [post]
+// @Security Authorization
+func EnrollMFA(
+	usersRepo repository.IUsersRepository,
+	mfaRepo repository.IMFARepository,
+	config AuthConfig) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userID, _, err := mfaUserID(c)
+		if err != nil {
+			c.String(http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		user, err := usersRepo.FindUserByID(userID)
+		if err != nil {
+			if errors.Is(err, repository.ErrUserNotFound) {
+				c.String(http.StatusUnauthorized, err.Error())
+				return
+			}
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		// An enabled second factor has to be disabled before enrolling again
+		enrollment, err := mfaRepo.FindMFAEnrollment(userID)
+		if err != nil && !errors.Is(err, repository.ErrMFAEnrollmentNotFound) {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		if err == nil && enrollment.EnabledAt != nil {
+			c.String(http.StatusConflict, ErrMFAAlreadyEnabled.Error())
+			return
+		}
+
+		secret, err := auth.GenerateTOTPSecret()
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if _, err := mfaRepo.CreateMFAEnrollment(model.MFAEnrollment{UserID: userID, Secret: secret}); err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		c.JSON(http.StatusOK, model.MFASetup{
+			Secret:          secret,
+			ProvisioningURI: auth.TOTPProvisioningURI(config.MFAIssuer, user.Email, secret),
+		})
+	}
+}
+
+// ActivateMFA godoc
+// @Summary      Activate second factor
+// @Description  confirm a pending enrollment with a TOTP code and get recovery codes. Callers using an enrollment token also get their tokens.
+// @Tags         mfa
+// @Param        code body model.MFACode true "MFACode"
+// @Accept       json
+// @Produce      json
+// @Success      200 {object} model.MFAActivation
+// @Failure      400 {string} string
+// @Failure      401 {string} string
+// @Failure      403 {string} string
+// @Failure      404 {string} string
+// @Failure      500 {string} string
+// @Router       /auth/mfa/activate [post]
+// @Security Authorization
+func ActivateMFA(
+	usersRepo repository.IUsersRepository,
+	mfaRepo repository.IMFARepository,
+	tokensRepo repository.IRefreshTokensRepository,
+	sessionsRepo repository.ISessionsRepository,
+	authService auth.IAuthService) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userID, enrolling, err := mfaUserID(c)
+		if err != nil {
+			c.String(http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		var code model.MFACode
+		// Bind the JSON request body to the code struct.
+		if err := c.BindJSON(&code); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+
+		enrollment, err := mfaRepo.FindMFAEnrollment(userID)
+		if err != nil {
+			if errors.Is(err, repository.ErrMFAEnrollmentNotFound) {
+				c.String(http.StatusNotFound, err.Error())
+				return
+			}
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		if enrollment.EnabledAt != nil {
+			c.String(http.StatusConflict, ErrMFAAlreadyEnabled.Error())
+			return
+		}
+
+		// The first code proves the authenticator was set up correctly
+		step, ok := auth.ValidateTOTP(enrollment.Secret, code.Code, time.Now())
+		if !ok {
+			c.String(http.StatusUnauthorized, ErrInvalidMFACode.Error())
+			return
+		}
+
+		recoveryCodes, err := auth.GenerateRecoveryCodes(recoveryCodesCount)
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		hashes := make([]string, len(recoveryCodes))
+		for i, recoveryCode := range recoveryCodes {
+			hashes[i] = authService.HashToken(recoveryCode)
+		}
+
+		if err := mfaRepo.EnableMFAEnrollment(userID, step, hashes); err != nil {
+			if errors.Is(err, repository.ErrMFAEnrollmentNotFound) {
+				c.String(http.StatusConflict, ErrMFAAlreadyEnabled.Error())
+				return
+			}
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		activation := model.MFAActivation{RecoveryCodes: recoveryCodes}
+
+		// A forced enrollment completes the login that started it
+		if enrolling {
+			user, err := usersRepo.FindUserByID(userID)
+			if err != nil {
+				c.String(http.StatusInternalServerError, err.Error())
+				return
+			}
+			if user.DisabledAt != nil {
+				c.String(http.StatusForbidden, ErrAccountDisabled.Error())
+				return
+			}
+			tokens, err := startSession(c, user, sessionsRepo, tokensRepo, authService)
+			if err != nil {
+				c.String(http.StatusInternalServerError, err.Error())
+				return
+			}
+			activation.Tokens = &tokens
+		}
+
+		c.JSON(http.StatusOK, activation)
+	}
+}
+
+// DisableMFA godoc
+// @Summary      Disable second factor
+// @Description  remove the second factor of the current user, confirmed with a TOTP code
+// @Tags         mfa
+// @Param        code body model.MFACode true "MFACode"
+// @Accept       json
+// @Success      200 {string} string
+// @Failure      400 {string} string
+// @Failure      401 {string} string
+// @Failure      403 {string} string
+// @Failure      404 {string} string
+// @Failure      500 {string} string
+// @Router       /auth/mfa/disable [post]
+// @Security Authorization
+func DisableMFA(mfaRepo repository.IMFARepository, config AuthConfig) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		claims, _ := middleware.Claims(c)
+		userID, err := claims.UserID()
+		if err != nil {
+			c.String(http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		// Roles that require a second factor cannot go without one
+		if config.requiresMFA(claims.Role) {
+			c.String(http.StatusForbidden, ErrMFARequired.Error())
+			return
+		}
+
+		var code model.MFACode
+		// Bind the JSON request body to the code struct.
+		if err := c.BindJSON(&code); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+
+		enrollment, err := mfaRepo.FindMFAEnrollment(userID)
+		if err != nil {
+			if errors.Is(err, repository.ErrMFAEnrollmentNotFound) {
+				c.String(http.StatusNotFound, ErrMFANotEnabled.Error())
+				return
+			}
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		if enrollment.EnabledAt == nil {
+			c.String(http.StatusNotFound, ErrMFANotEnabled.Error())
+			return
+		}
+
+		if err := useTOTPCode(mfaRepo, enrollment, code.Code); err != nil {
+			if isInvalidMFACode(err) {
+				c.String(http.StatusUnauthorized, ErrInvalidMFACode.Error())
+				return
+			}
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if err := mfaRepo.DeleteMFAEnrollment(userID); err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		c.String(http.StatusOK, "mfa disabled")
+	}
+}
+
+// ResetUserMFA godoc
+// @Summary      Reset second factor
+// @Description  remove the second factor of a user who lost both the authenticator and the recovery codes
+// @Tags         admin
+// @Param        user_id  path string  true  "user_id"  1
+// @Success      200 {string} string
+// @Failure      400 {string} string
+// @Failure      500 {string} string
+// @Router       /admin/users/{user_id}/mfa [delete]
+// @Security Authorization
+func ResetUserMFA(mfaRepo repository.IMFARepository) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		// Get query params
+		userID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.String(http.StatusBadRequest, ErrInvalidUserID.Error())
+			return
+		}
+
+		if err := mfaRepo.DeleteMFAEnrollment(userID); err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		c.String(http.StatusOK, "mfa reset")
+	}
+}
+
+// completeLogin answers a login whose first factor succeeded. It hands out
+// tokens, or an MFA challenge when user has a second factor, or an enrollment
+// token when the role of user requires one.
+func completeLogin(
+	c *gin.Context,
+	user model.User,
+	mfaRepo repository.IMFARepository,
+	tokensRepo repository.IRefreshTokensRepository,
+	sessionsRepo repository.ISessionsRepository,
+	authService auth.IAuthService,
+	config AuthConfig) {
+	// Disabled accounts cannot log in, whatever the way
+	if user.DisabledAt != nil {
+		c.String(http.StatusForbidden, ErrAccountDisabled.Error())
+		return
+	}
+
+	// Ask for the second factor if the user has one
+	enrollment, err := mfaRepo.FindMFAEnrollment(user.ID)
+	if err != nil && !errors.Is(err, repository.ErrMFAEnrollmentNotFound) {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err == nil && enrollment.EnabledAt != nil {
+		mfaToken, err := authService.GenerateMFAToken(user, auth.MFAChallenge)
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.JSON(http.StatusAccepted, model.MFAChallenge{MFARequired: true, MFAToken: mfaToken})
+		return
+	}
+
+	// Users whose role requires a second factor have to enroll first
+	if config.requiresMFA(user.Role) {
+		mfaToken, err := authService.GenerateMFAToken(user, auth.MFAEnrollment)
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.JSON(http.StatusForbidden, model.MFAChallenge{MFAEnrollmentRequired: true, MFAToken: mfaToken})
+		return
+	}
+
+	// Start a new session with its own refresh token family
+	tokens, err := startSession(c, user, sessionsRepo, tokensRepo, authService)
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// mfaUserID returns the ID of the caller and whether it authenticated with
+// an MFA enrollment token rather than an access token.
+func mfaUserID(c *gin.Context) (int, bool, error) {
+	if userID, ok := middleware.MFAEnrollment(c); ok {
+		return userID, true, nil
+	}
+	claims, ok := middleware.Claims(c)
+	if !ok {
+		return 0, false, middleware.ErrTokenNotFound
+	}
+	userID, err := claims.UserID()
+	return userID, false, err
+}
+
+// useTOTPCode checks code against enrollment and records its time step, so
+// the same code cannot be used twice.
+func useTOTPCode(mfaRepo repository.IMFARepository, enrollment model.MFAEnrollment, code string) error {
+	step, ok := auth.ValidateTOTP(enrollment.Secret, code, time.Now())
+	if !ok {
+		return ErrInvalidMFACode
+	}
+	return mfaRepo.UseMFAStep(enrollment.UserID, step)
+}
+
+func isInvalidMFACode(err error) bool {
+	return errors.Is(err, ErrInvalidMFACode) ||
+		errors.Is(err, repository.ErrMFACodeReused) ||
+		errors.Is(err, repository.ErrMFARecoveryCodeNotFound)
+}
@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/darolpz/students/internal/auth"
+	"github.com/darolpz/students/internal/model"
+	"github.com/darolpz/students/internal/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeAPIKeysRepository struct {
+	keys    map[string]model.APIKey
+	touched map[int]bool
+}
+
+func (f fakeAPIKeysRepository) CreateAPIKey(key model.APIKey) (model.APIKey, error) {
+	return key, nil
+}
+
+func (f fakeAPIKeysRepository) ListAPIKeys() ([]model.APIKey, error) {
+	return nil, nil
+}
+
+func (f fakeAPIKeysRepository) FindAPIKey(hash string) (model.APIKey, error) {
+	key, ok := f.keys[hash]
+	if !ok {
+		return model.APIKey{}, repository.ErrAPIKeyNotFound
+	}
+	return key, nil
+}
+
+func (f fakeAPIKeysRepository) RevokeAPIKey(id int) error {
+	return nil
+}
+
+func (f fakeAPIKeysRepository) TouchAPIKey(id int, before time.Time) error {
+	f.touched[id] = true
+	return nil
+}
+
+func TestAuthMiddleware_APIKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	authService := newTestAuthService(t)
+
+	past := time.Now().Add(-time.Hour)
+	apiKeysRepo := fakeAPIKeysRepository{
+		keys: map[string]model.APIKey{
+			authService.HashToken("stk_reader"):  {ID: 1, Scopes: "students:read"},
+			authService.HashToken("stk_writer"):  {ID: 2, Scopes: "students:read students:write"},
+			authService.HashToken("stk_expired"): {ID: 3, Scopes: "students:read", ExpiresAt: &past},
+			authService.HashToken("stk_revoked"): {ID: 4, Scopes: "students:read", RevokedAt: &past},
+		},
+		touched: map[int]bool{},
+	}
+
+	app := gin.New()
+	group := app.Group("students")
+	group.Use(AuthMiddleware(authService, fakeRevokedTokensRepository{}, apiKeysRepo), Authorize(RoutePermissions{
+		"GET /students/:id":    auth.PermissionReadStudents,
+		"DELETE /students/:id": auth.PermissionWriteStudents,
+	}))
+	ok := func(c *gin.Context) { c.String(http.StatusOK, "ok") }
+	group.GET("/:id", ok)
+	group.DELETE("/:id", ok)
+	app.GET("/admin", AuthMiddleware(authService, fakeRevokedTokensRepository{}, apiKeysRepo), RequireRole(auth.RoleAdmin), ok)
+	app.GET("/me", AuthMiddleware(authService, fakeRevokedTokensRepository{}, nil), ok)
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		key            string
+		expectedStatus int
+	}{
+		{
+			name:           "should_allow_key_with_scope",
+			method:         http.MethodGet,
+			path:           "/students/1",
+			key:            "stk_reader",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "should_forbid_key_without_scope",
+			method:         http.MethodDelete,
+			path:           "/students/1",
+			key:            "stk_reader",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "should_allow_key_with_write_scope",
+			method:         http.MethodDelete,
+			path:           "/students/1",
+			key:            "stk_writer",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "should_reject_unknown_key",
+			method:         http.MethodGet,
+			path:           "/students/1",
+			key:            "stk_unknown",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "should_reject_expired_key",
+			method:         http.MethodGet,
+			path:           "/students/1",
+			key:            "stk_expired",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "should_reject_revoked_key",
+			method:         http.MethodGet,
+			path:           "/students/1",
+			key:            "stk_revoked",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "should_forbid_key_on_role_restricted_route",
+			method:         http.MethodGet,
+			path:           "/admin",
+			key:            "stk_writer",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "should_reject_key_where_keys_are_not_accepted",
+			method:         http.MethodGet,
+			path:           "/me",
+			key:            "stk_writer",
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Authorization", "Bearer "+tt.key)
+			rec := httptest.NewRecorder()
+			app.ServeHTTP(rec, req)
+			require.Equal(t, tt.expectedStatus, rec.Code)
+		})
+	}
+
+	require.True(t, apiKeysRepo.touched[1])
+	require.False(t, apiKeysRepo.touched[3])
+}
@@ -2,32 +2,58 @@ package middleware
 
 import (
 	"errors"
+	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/darolpz/students/internal/auth"
+	"github.com/darolpz/students/internal/repository"
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	// ClaimsKey is the context key under which AuthMiddleware stores the
+	// *auth.JWTClaim of a caller authenticated with an access token.
+	ClaimsKey = "claims"
+	// APIKeyKey is the context key under which AuthMiddleware stores the
+	// model.APIKey of a caller authenticated with an API key.
+	APIKeyKey = "api_key"
+)
+
 var (
 	ErrTokenNotFound = errors.New("token not found")
 	ErrParseClaims   = errors.New("couldn't parse claims")
 	ErrInvalidClaims = errors.New("claims are not valid")
+	ErrTokenRevoked  = errors.New("token has been revoked")
+	ErrInvalidAPIKey = errors.New("invalid api key")
+	ErrAPIKeyExpired = errors.New("api key has been revoked or has expired")
 )
 
-func AuthMiddleware(authService auth.IAuthService) func(c *gin.Context) {
+// AuthMiddleware authenticates the caller with a Bearer access token or, when
+// apiKeysRepo is not nil, with an API key. Routes that act on behalf of a
+// user, rather than on students, pass a nil apiKeysRepo.
+func AuthMiddleware(
+	authService auth.IAuthService,
+	revokedRepo repository.IRevokedTokensRepository,
+	apiKeysRepo repository.IAPIKeysRepository) func(c *gin.Context) {
 	return func(c *gin.Context) {
 		// Get the token from the header
 		authorization := c.GetHeader("Authorization")
 
+		// Strip the Bearer prefix from the token
+		authorization = strings.TrimPrefix(authorization, "Bearer ")
+
 		// If there is no token, return error
 		if authorization == "" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": ErrTokenNotFound.Error()})
 			return
 		}
 
-		// Split the token into the Bearer and the token
-		authorization = strings.Split(authorization, "Bearer ")[1]
+		if apiKeysRepo != nil && auth.IsAPIKey(authorization) {
+			authenticateAPIKey(c, authorization, apiKeysRepo, authService)
+			return
+		}
 
 		// Check token
 		token, err := authService.CheckToken(authorization)
@@ -49,9 +75,59 @@ func AuthMiddleware(authService auth.IAuthService) func(c *gin.Context) {
 			return
 		}
 
+		// Tokens without an ID or issue time could never be revoked
+		userID, err := claims.UserID()
+		if err != nil || claims.ID == "" || claims.IssuedAt == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": ErrInvalidClaims.Error()})
+			return
+		}
+
+		// Check the revocation list
+		revoked, err := revokedRepo.IsTokenRevoked(claims.ID, claims.SessionID, userID, claims.IssuedAt.Time)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if revoked {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": ErrTokenRevoked.Error()})
+			return
+		}
+
 		// Set the user to the context
+		c.Set(ClaimsKey, claims)
 		c.Header("email", claims.Email)
 		c.Header("role", claims.Role)
 		c.Next()
 	}
 }
+
+// authenticateAPIKey looks an API key up by its hash and records its use.
+func authenticateAPIKey(
+	c *gin.Context,
+	credential string,
+	apiKeysRepo repository.IAPIKeysRepository,
+	authService auth.IAuthService) {
+	key, err := apiKeysRepo.FindAPIKey(authService.HashToken(credential))
+	if err != nil {
+		if errors.Is(err, repository.ErrAPIKeyNotFound) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": ErrInvalidAPIKey.Error()})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	if !auth.APIKeyActive(key, now) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": ErrAPIKeyExpired.Error()})
+		return
+	}
+
+	// Usage tracking is best effort and never fails the request
+	if err := apiKeysRepo.TouchAPIKey(key.ID, now.Add(-auth.APIKeyUsageResolution)); err != nil {
+		log.Printf("could not record use of api key %d: %s", key.ID, err)
+	}
+
+	c.Set(APIKeyKey, key)
+	c.Next()
+}
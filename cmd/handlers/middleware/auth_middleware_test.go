@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/darolpz/students/internal/auth"
+	"github.com/darolpz/students/internal/model"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type fakeRevokedTokensRepository struct {
+	revokedUserID    int
+	revokedSessionID string
+}
+
+func (f fakeRevokedTokensRepository) CreateRevokedToken(token model.RevokedToken) (model.RevokedToken, error) {
+	return token, nil
+}
+
+func (f fakeRevokedTokensRepository) IsTokenRevoked(jti, sessionID string, userID int, issuedAt time.Time) (bool, error) {
+	return userID == f.revokedUserID || (sessionID != "" && sessionID == f.revokedSessionID), nil
+}
+
+func (f fakeRevokedTokensRepository) DeleteExpiredRevokedTokens() error {
+	return nil
+}
+
+func newTestAuthService(t *testing.T) auth.IAuthService {
+	keys, err := auth.GenerateKeySet()
+	require.NoError(t, err)
+	hasher, err := auth.NewBcryptHasher(bcrypt.MinCost)
+	require.NoError(t, err)
+	return auth.NewAuthService(keys, hasher)
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	authService := newTestAuthService(t)
+
+	app := gin.New()
+	app.GET("/", AuthMiddleware(authService, fakeRevokedTokensRepository{revokedUserID: 2, revokedSessionID: "revoked"}, nil), func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	validToken, err := authService.GenerateJWT(model.User{ID: 1, Email: "john.doe@gmail.com", Role: auth.RoleUser}, "active")
+	require.NoError(t, err)
+	revokedSessionToken, err := authService.GenerateJWT(model.User{ID: 1, Email: "john.doe@gmail.com", Role: auth.RoleUser}, "revoked")
+	require.NoError(t, err)
+	revokedToken, err := authService.GenerateJWT(model.User{ID: 2, Email: "dario@gmail.com", Role: auth.RoleUser}, "")
+	require.NoError(t, err)
+	otherToken, err := newTestAuthService(t).GenerateJWT(model.User{ID: 1, Email: "john.doe@gmail.com", Role: auth.RoleUser}, "")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		authorization  string
+		expectedStatus int
+	}{
+		{
+			name:           "should_accept_valid_token",
+			authorization:  "Bearer " + validToken,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "should_reject_missing_token",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "should_reject_malformed_header",
+			authorization:  "Basic abc",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "should_reject_token_signed_with_other_key",
+			authorization:  "Bearer " + otherToken,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "should_reject_revoked_token",
+			authorization:  "Bearer " + revokedToken,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "should_reject_token_of_revoked_session",
+			authorization:  "Bearer " + revokedSessionToken,
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			app.ServeHTTP(rec, req)
+			require.Equal(t, tt.expectedStatus, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/darolpz/students/internal/auth"
+	"github.com/darolpz/students/internal/model"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorize(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	authService := newTestAuthService(t)
+
+	app := gin.New()
+	group := app.Group("students")
+	group.Use(AuthMiddleware(authService, fakeRevokedTokensRepository{}, nil), Authorize(RoutePermissions{
+		"GET /students/:id":    auth.PermissionReadStudents,
+		"DELETE /students/:id": auth.PermissionWriteStudents,
+	}))
+	ok := func(c *gin.Context) { c.String(http.StatusOK, "ok") }
+	group.GET("/:id", ok)
+	group.DELETE("/:id", ok)
+	group.POST("/", ok)
+
+	adminToken, err := authService.GenerateJWT(model.User{ID: 1, Email: "admin@gmail.com", Role: auth.RoleAdmin}, "")
+	require.NoError(t, err)
+	userToken, err := authService.GenerateJWT(model.User{ID: 2, Email: "user@gmail.com", Role: auth.RoleUser}, "")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		method         string
+		token          string
+		expectedStatus int
+	}{
+		{
+			name:           "should_allow_user_to_read",
+			method:         http.MethodGet,
+			token:          userToken,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "should_allow_admin_to_read",
+			method:         http.MethodGet,
+			token:          adminToken,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "should_allow_admin_to_delete",
+			method:         http.MethodDelete,
+			token:          adminToken,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "should_forbid_user_to_delete",
+			method:         http.MethodDelete,
+			token:          userToken,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "should_forbid_undeclared_route",
+			method:         http.MethodPost,
+			token:          adminToken,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "should_reject_missing_token",
+			method:         http.MethodGet,
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := "/students/1"
+			if tt.method == http.MethodPost {
+				path = "/students/"
+			}
+			req := httptest.NewRequest(tt.method, path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			app.ServeHTTP(rec, req)
+			require.Equal(t, tt.expectedStatus, rec.Code)
+		})
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	authService := newTestAuthService(t)
+
+	app := gin.New()
+	app.GET("/admin", AuthMiddleware(authService, fakeRevokedTokensRepository{}, nil), RequireRole(auth.RoleAdmin), func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	tests := []struct {
+		name           string
+		role           string
+		expectedStatus int
+	}{
+		{
+			name:           "should_allow_admin",
+			role:           auth.RoleAdmin,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "should_forbid_user",
+			role:           auth.RoleUser,
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := authService.GenerateJWT(model.User{ID: 1, Email: "john.doe@gmail.com", Role: tt.role}, "")
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			app.ServeHTTP(rec, req)
+			require.Equal(t, tt.expectedStatus, rec.Code)
+		})
+	}
+}
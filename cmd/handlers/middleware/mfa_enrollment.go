@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/darolpz/students/internal/auth"
+	"github.com/darolpz/students/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// MFAEnrollmentKey is the context key under which AllowMFAEnrollment stores
+// the user ID of a caller authenticated with an MFA enrollment token.
+const MFAEnrollmentKey = "mfa_enrollment"
+
+// AllowMFAEnrollment accepts, besides access tokens, the enrollment tokens
+// Login hands out to users who must enroll a second factor before they can
+// get an access token.
+func AllowMFAEnrollment(authService auth.IAuthService, revokedRepo repository.IRevokedTokensRepository) func(c *gin.Context) {
+	authenticate := AuthMiddleware(authService, revokedRepo, nil)
+	return func(c *gin.Context) {
+		authorization := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if claims, err := authService.CheckMFAToken(authorization, auth.MFAEnrollment); err == nil {
+			if userID, err := claims.UserID(); err == nil {
+				c.Set(MFAEnrollmentKey, userID)
+				c.Next()
+				return
+			}
+		}
+		authenticate(c)
+	}
+}
+
+// MFAEnrollment returns the user ID of a caller authenticated with an MFA
+// enrollment token.
+func MFAEnrollment(c *gin.Context) (int, bool) {
+	value, ok := c.Get(MFAEnrollmentKey)
+	if !ok {
+		return 0, false
+	}
+	userID, ok := value.(int)
+	return userID, ok
+}
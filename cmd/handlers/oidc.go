@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/darolpz/students/internal/auth"
+	"github.com/darolpz/students/internal/model"
+	"github.com/darolpz/students/internal/oidc"
+	"github.com/darolpz/students/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// oidcStateCookie carries the signed state of a login from the redirect
+	// to the identity provider to the callback.
+	oidcStateCookie = "oidc_state"
+	oidcCookiePath  = "/auth/oidc"
+	// maxNameLength is the size of the users.name column.
+	maxNameLength = 20
+)
+
+var (
+	ErrOIDCUnavailable   = errors.New("identity provider is unavailable")
+	ErrOIDCDenied        = errors.New("login was denied by the identity provider")
+	ErrInvalidOIDCState  = errors.New("invalid or expired login state")
+	ErrOIDCLoginFailed   = errors.New("couldn't log in with the identity provider")
+	ErrOIDCEmailMissing  = errors.New("identity provider did not share an email address")
+	ErrOIDCAccountExists = errors.New("an account with this email already exists and the identity provider did not verify the address")
+)
+
+// OIDCConfig holds the settings of the OpenID Connect endpoints.
+type OIDCConfig struct {
+	// RoleMapping maps groups at the identity provider to our roles. When it
+	// is not empty the role of a user is synchronised on every login.
+	RoleMapping map[string]string
+}
+
+// OIDCLogin godoc
+// @Summary      Login with identity provider
+// @Description  redirect to the identity provider to log in with the authorization code flow and PKCE
+// @Tags         oidc
+// @Success      302 {string} string
+// @Failure      500 {string} string
+// @Failure      502 {string} string
+// @Router       /auth/oidc/login [get]
+func OIDCLogin(provider oidc.IProvider, authService auth.IAuthService, config AuthConfig) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		state, err := authService.GenerateTokenID()
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		nonce, err := authService.GenerateTokenID()
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		verifier, err := oidc.GenerateCodeVerifier()
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		authCodeURL, err := provider.AuthCodeURL(state, nonce, oidc.CodeChallenge(verifier))
+		if err != nil {
+			log.Printf("could not start oidc login: %s", err)
+			c.String(http.StatusBadGateway, ErrOIDCUnavailable.Error())
+			return
+		}
+
+		// Keep what the callback needs to check in a signed cookie
+		cookie, err := authService.GenerateOIDCStateToken(state, nonce, verifier)
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		setOIDCStateCookie(c, cookie, int(auth.OIDCStateDuration.Seconds()), config)
+
+		c.Redirect(http.StatusFound, authCodeURL)
+	}
+}
+
+// OIDCCallback godoc
+// @Summary      Identity provider callback
+// @Description  complete a login at the identity provider, linking or creating the local user
+// @Tags         oidc
+// @Param        code   query string true "authorization code"
+// @Param        state  query string true "state"
+// @Produce      json
+// @Success      200 {object} model.TokenPair
+// @Success      202 {object} model.MFAChallenge
+// @Failure      401 {string} string
+// @Failure      403 {object} model.MFAChallenge
+// @Failure      409 {string} string
+// @Failure      500 {string} string
+// @Failure      502 {string} string
+// @Router       /auth/oidc/callback [get]
+func OIDCCallback(
+	provider oidc.IProvider,
+	usersRepo repository.IUsersRepository,
+	identitiesRepo repository.IUserIdentitiesRepository,
+	tokensRepo repository.IRefreshTokensRepository,
+	sessionsRepo repository.ISessionsRepository,
+	mfaRepo repository.IMFARepository,
+	authService auth.IAuthService,
+	config AuthConfig,
+	oidcConfig OIDCConfig) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		if reason := c.Query("error"); reason != "" {
+			c.String(http.StatusUnauthorized, ErrOIDCDenied.Error()+": "+reason)
+			return
+		}
+
+		// The state has to match the cookie set when the login started, and
+		// each state can only be used once
+		cookie, err := c.Cookie(oidcStateCookie)
+		if err != nil {
+			c.String(http.StatusUnauthorized, ErrInvalidOIDCState.Error())
+			return
+		}
+		setOIDCStateCookie(c, "", -1, config)
+		state, err := authService.CheckOIDCStateToken(cookie)
+		if err != nil || state.ID == "" || c.Query("state") != state.ID {
+			c.String(http.StatusUnauthorized, ErrInvalidOIDCState.Error())
+			return
+		}
+
+		identity, err := provider.Exchange(c.Request.Context(), c.Query("code"), state.CodeVerifier, state.Nonce)
+		if err != nil {
+			log.Printf("oidc login failed: %s", err)
+			if errors.Is(err, oidc.ErrDiscovery) || errors.Is(err, oidc.ErrFetchKeys) {
+				c.String(http.StatusBadGateway, ErrOIDCUnavailable.Error())
+				return
+			}
+			c.String(http.StatusUnauthorized, ErrOIDCLoginFailed.Error())
+			return
+		}
+
+		user, status, err := oidcUser(identity, usersRepo, identitiesRepo, oidcConfig)
+		if err != nil {
+			c.String(status, err.Error())
+			return
+		}
+
+		completeLogin(c, user, mfaRepo, tokensRepo, sessionsRepo, authService, config)
+	}
+}
+
+// oidcUser returns the local user of an external identity. Known identities
+// map to their linked user, verified email addresses link to an existing
+// user, and otherwise a user is provisioned. On failure it also returns the
+// status to answer with.
+func oidcUser(
+	identity oidc.Identity,
+	usersRepo repository.IUsersRepository,
+	identitiesRepo repository.IUserIdentitiesRepository,
+	oidcConfig OIDCConfig) (model.User, int, error) {
+	role := oidc.MapRole(identity.Groups, oidcConfig.RoleMapping)
+
+	var user model.User
+	link, err := identitiesRepo.FindUserIdentity(identity.Issuer, identity.Subject)
+	switch {
+	case err == nil:
+		user, err = usersRepo.FindUserByID(link.UserID)
+		if err != nil {
+			return model.User{}, http.StatusInternalServerError, err
+		}
+
+	case errors.Is(err, repository.ErrUserIdentityNotFound):
+		if identity.Email == "" {
+			return model.User{}, http.StatusForbidden, ErrOIDCEmailMissing
+		}
+		user, err = usersRepo.FindUserByEmail(identity.Email)
+		if err != nil && !errors.Is(err, repository.ErrUserNotFound) {
+			return model.User{}, http.StatusInternalServerError, err
+		}
+
+		// Provision a new user
+		if err != nil {
+			var verifiedAt *time.Time
+			if identity.EmailVerified {
+				now := time.Now()
+				verifiedAt = &now
+			}
+			user, err = identitiesRepo.ProvisionUser(model.User{
+				Name:       truncate(identity.Name, maxNameLength),
+				Email:      identity.Email,
+				Role:       role,
+				VerifiedAt: verifiedAt,
+			}, model.UserIdentity{Issuer: identity.Issuer, Subject: identity.Subject})
+			if err != nil {
+				return model.User{}, http.StatusInternalServerError, err
+			}
+			return user, 0, nil
+		}
+
+		// Only link to an existing user when the provider vouches for the
+		// address, otherwise anyone could take over accounts
+		if !identity.EmailVerified {
+			return model.User{}, http.StatusConflict, ErrOIDCAccountExists
+		}
+		_, err = identitiesRepo.CreateUserIdentity(model.UserIdentity{
+			UserID:  user.ID,
+			Issuer:  identity.Issuer,
+			Subject: identity.Subject,
+		})
+		if err != nil {
+			return model.User{}, http.StatusInternalServerError, err
+		}
+		if user.VerifiedAt == nil {
+			if err := usersRepo.VerifyUserEmail(user.ID); err != nil {
+				return model.User{}, http.StatusInternalServerError, err
+			}
+		}
+
+	default:
+		return model.User{}, http.StatusInternalServerError, err
+	}
+
+	// Keep the role in sync with the groups at the provider
+	if len(oidcConfig.RoleMapping) > 0 && user.Role != role {
+		if err := usersRepo.UpdateUserRole(user.ID, role); err != nil {
+			return model.User{}, http.StatusInternalServerError, err
+		}
+		user.Role = role
+	}
+	return user, 0, nil
+}
+
+func setOIDCStateCookie(c *gin.Context, value string, maxAge int, config AuthConfig) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, value, maxAge, oidcCookiePath, "", strings.HasPrefix(config.PublicURL, "https://"), true)
+}
+
+// truncate shortens s to at most n runes.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/darolpz/students/internal/model"
+	"github.com/darolpz/students/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// studentPage is a page of a student listing with the cursors of its
+// neighbours. total is only known for offset pages.
+type studentPage struct {
+	students []model.Student
+	total    *int64
+	next     *string
+	prev     *string
+}
+
+// listStudents reads an offset page. Its cursors let clients switch to
+// keyset pagination from any page.
+func listStudents(studentsRepo repository.IStudentsRepository, query model.StudentQuery, keyset model.Keyset) (studentPage, error) {
+	students, total, err := studentsRepo.ListStudents(query)
+	if err != nil {
+		return studentPage{}, err
+	}
+
+	page := studentPage{students: students, total: &total}
+	if len(students) > 0 {
+		if int64(query.Offset+len(students)) < total {
+			page.next = cursorOf(students[len(students)-1], keyset)
+		}
+		if query.Offset > 0 {
+			page.prev = cursorOf(students[0], keyset)
+		}
+	}
+	return page, nil
+}
+
+// seekStudents reads a keyset page. One row more than the limit is read to
+// tell whether the listing goes on in the direction of the page.
+func seekStudents(studentsRepo repository.IStudentsRepository, query model.StudentQuery, keyset model.Keyset) (studentPage, error) {
+	limit := query.Limit
+	query.Limit++
+	students, err := studentsRepo.SeekStudents(query)
+	if err != nil {
+		return studentPage{}, err
+	}
+
+	more := len(students) > limit
+	if more {
+		// The extra row is the farthest from the cursor
+		if keyset.Backward {
+			students = students[1:]
+		} else {
+			students = students[:limit]
+		}
+	}
+
+	page := studentPage{students: students}
+	if len(students) > 0 {
+		// The row of the cursor itself lies on the other side of the page
+		if more || keyset.Backward {
+			page.next = cursorOf(students[len(students)-1], keyset)
+		}
+		if more || !keyset.Backward {
+			page.prev = cursorOf(students[0], keyset)
+		}
+	}
+	return page, nil
+}
+
+func cursorOf(student model.Student, keyset model.Keyset) *string {
+	cursor := student.Cursor(keyset.Sort).Encode()
+	return &cursor
+}
+
+// pageLink formats an RFC 8288 link to the request URL with its pagination
+// replaced by cursor as the param query parameter.
+func pageLink(c *gin.Context, param, cursor, rel string) string {
+	values := c.Request.URL.Query()
+	values.Del("offset")
+	values.Del("after")
+	values.Del("before")
+	values.Set(param, cursor)
+	link := url.URL{Path: c.Request.URL.Path, RawQuery: values.Encode()}
+	return fmt.Sprintf("<%s>; rel=%q", link.String(), rel)
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/darolpz/students/internal/auth"
+	"github.com/darolpz/students/internal/mail"
+	"github.com/darolpz/students/internal/model"
+	"github.com/darolpz/students/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrInvalidResetToken = errors.New("invalid or expired reset token")
+)
+
+// ForgotPassword godoc
+// @Summary      Forgot password
+// @Description  email a password reset link. The response is the same whether the account exists or not
+// @Tags         auth
+// @Param        forgot body model.ForgotPassword true "ForgotPassword"
+// @Accept       json
+// @Success      202 {string} string
+// @Failure      400 {string} string
+// @Failure      500 {string} string
+// @Router       /auth/password/forgot [post]
+func ForgotPassword(
+	usersRepo repository.IUsersRepository,
+	resetsRepo repository.IPasswordResetsRepository,
+	authService auth.IAuthService,
+	mailer mail.IMailer,
+	config AuthConfig) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var forgot model.ForgotPassword
+		// Bind the JSON request body to the forgot struct.
+		if err := c.BindJSON(&forgot); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+
+		const response = "if the account exists, a reset link has been sent"
+
+		// Retrieve user from repository
+		user, err := usersRepo.FindUserByEmail(forgot.Email)
+		if err != nil {
+			if errors.Is(err, repository.ErrUserNotFound) {
+				c.String(http.StatusAccepted, response)
+				return
+			}
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		// Generate and persist the reset token
+		token, hash, err := authService.GenerateOpaqueToken()
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		_, err = resetsRepo.CreatePasswordReset(model.PasswordReset{
+			UserID:    user.ID,
+			TokenHash: hash,
+			ExpiresAt: time.Now().Add(auth.PasswordResetDuration),
+		})
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		sendInBackground(mailer, mail.Message{
+			To:      user.Email,
+			Subject: "Reset your password",
+			Body: fmt.Sprintf("Hi %s,\n\nUse the link below to choose a new password. It expires in %s.\n\n%s?token=%s\n\n"+
+				"If you did not ask for a password reset you can ignore this email.\n",
+				user.Name, auth.PasswordResetDuration, config.PasswordResetURL, token),
+		})
+
+		c.String(http.StatusAccepted, response)
+	}
+}
+
+// ResetPassword godoc
+// @Summary      Reset password
+// @Description  set a new password using a reset token and log out every session
+// @Tags         auth
+// @Param        reset body model.ResetPassword true "ResetPassword"
+// @Accept       json
+// @Success      200 {string} string
+// @Failure      400 {string} string
+// @Failure      422 {object} map[string][]validation.FieldError
+// @Failure      500 {string} string
+// @Router       /auth/password/reset [post]
+func ResetPassword(
+	usersRepo repository.IUsersRepository,
+	resetsRepo repository.IPasswordResetsRepository,
+	tokensRepo repository.IRefreshTokensRepository,
+	revokedRepo repository.IRevokedTokensRepository,
+	authService auth.IAuthService,
+	config AuthConfig) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var reset model.ResetPassword
+		// Bind the JSON request body to the reset struct.
+		if err := c.BindJSON(&reset); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		if !checked(c, withPassword(nil, config.PasswordPolicy, "password", reset.Password, "")) {
+			return
+		}
+
+		// Retrieve the stored reset by its hash
+		stored, err := resetsRepo.FindPasswordReset(authService.HashToken(reset.Token))
+		if err != nil {
+			if errors.Is(err, repository.ErrPasswordResetNotFound) {
+				c.String(http.StatusBadRequest, ErrInvalidResetToken.Error())
+				return
+			}
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		if stored.UsedAt != nil || time.Now().After(stored.ExpiresAt) {
+			c.String(http.StatusBadRequest, ErrInvalidResetToken.Error())
+			return
+		}
+
+		// Redeem the token before changing anything
+		if err := resetsRepo.UsePasswordReset(stored.ID); err != nil {
+			if errors.Is(err, repository.ErrPasswordResetUsed) {
+				c.String(http.StatusBadRequest, ErrInvalidResetToken.Error())
+				return
+			}
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		// Hash and persist the new password
+		password, err := authService.GenerateHashPassword(reset.Password)
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		if err := usersRepo.UpdateUserPassword(stored.UserID, password); err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		// Whoever knew the old password must not stay logged in
+		if err := revokeUserTokens(stored.UserID, tokensRepo, revokedRepo); err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		c.String(http.StatusOK, "password updated")
+	}
+}
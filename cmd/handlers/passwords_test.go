@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/darolpz/students/internal/auth"
+	"github.com/darolpz/students/internal/validation"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResetPassword_WeakPassword(t *testing.T) {
+	handler := ResetPassword(nil, nil, nil, nil, nil, AuthConfig{PasswordPolicy: auth.DefaultPasswordPolicy})
+
+	w := serve(handler, http.MethodPost, "/auth/password/reset", "/auth/password/reset", `{"token":"reset","password":"short"}`)
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	var body struct {
+		Errors validation.Errors `json:"errors"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Equal(t, validation.Errors{
+		{Field: "password", Rule: "password", Message: "needs at least 8 characters"},
+	}, body.Errors)
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/darolpz/students/internal/model"
+	"github.com/darolpz/students/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrPreconditionFailed   = errors.New("student was modified since it was read, fetch it again for its current ETag")
+	ErrPreconditionRequired = errors.New("If-Match header with the ETag of the student is required")
+)
+
+// studentETag returns the entity tag of the current version of student.
+func studentETag(student model.Student) string {
+	return `"` + strconv.Itoa(student.Version) + `"`
+}
+
+// etagMatches reports whether the If-Match header holds etag or *. Weak
+// tags never match, as If-Match compares strongly.
+func etagMatches(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatch checks the If-Match header of a request writing the student with
+// id and returns the version it matched, for the write to be made against,
+// or 0 when there is no header. When the request must not go on it writes
+// the response and returns false.
+func ifMatch(c *gin.Context, studentsRepo repository.IStudentsRepository, studentID string, required bool) (int, bool) {
+	if c.GetHeader("If-Match") == "" {
+		if required {
+			c.String(http.StatusPreconditionRequired, ErrPreconditionRequired.Error())
+			return 0, false
+		}
+		return 0, true
+	}
+
+	student, ok := findStudent(c, studentsRepo, studentID)
+	if !ok || !preconditionMet(c, student, required) {
+		return 0, false
+	}
+	return student.Version, true
+}
+
+// preconditionMet checks the If-Match header of a request writing student
+// as it is now. When it is not met it writes the response and returns false.
+func preconditionMet(c *gin.Context, student model.Student, required bool) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		if required {
+			c.String(http.StatusPreconditionRequired, ErrPreconditionRequired.Error())
+			return false
+		}
+		return true
+	}
+	if !etagMatches(header, studentETag(student)) {
+		c.String(http.StatusPreconditionFailed, ErrPreconditionFailed.Error())
+		return false
+	}
+	return true
+}
+
+// findStudent returns the student with id, or writes the response and
+// returns false when it cannot.
+func findStudent(c *gin.Context, studentsRepo repository.IStudentsRepository, studentID string) (model.Student, bool) {
+	student, err := studentsRepo.FindStudent(studentID)
+	if err != nil {
+		if errors.Is(err, repository.ErrStudentNotFound) {
+			log.Printf("could not find student with id %s: %s", studentID, err)
+			c.String(http.StatusNotFound, err.Error())
+			return student, false
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return student, false
+	}
+	return student, true
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/darolpz/students/cmd/handlers/middleware"
+	"github.com/darolpz/students/internal/auth"
+	"github.com/darolpz/students/internal/model"
+	"github.com/darolpz/students/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// userAgentLength is the size of the sessions.user_agent column.
+	userAgentLength = 255
+)
+
+// ListSessions godoc
+// @Summary      List sessions
+// @Description  list the devices the current user is logged in on, most recently seen first
+// @Tags         account
+// @Produce      json
+// @Success      200 {array} model.Session
+// @Failure      401 {string} string
+// @Failure      500 {string} string
+// @Router       /auth/sessions [get]
+// @Security Authorization
+func ListSessions(sessionsRepo repository.ISessionsRepository) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		claims, _ := middleware.Claims(c)
+		userID, err := claims.UserID()
+		if err != nil {
+			c.String(http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		sessions, err := sessionsRepo.ListSessions(userID)
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		for i := range sessions {
+			sessions[i].Current = sessions[i].ID == claims.SessionID
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"sessions": sessions,
+		})
+	}
+}
+
+// RevokeSession godoc
+// @Summary      Revoke session
+// @Description  log out a single device of the current user. Its access and refresh tokens stop working immediately.
+// @Tags         account
+// @Param        session_id  path string  true  "session_id"
+// @Success      200 {string} string
+// @Failure      401 {string} string
+// @Failure      404 {string} string
+// @Failure      500 {string} string
+// @Router       /auth/sessions/{session_id} [delete]
+// @Security Authorization
+func RevokeSession(
+	sessionsRepo repository.ISessionsRepository,
+	tokensRepo repository.IRefreshTokensRepository,
+	revokedRepo repository.IRevokedTokensRepository) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		claims, _ := middleware.Claims(c)
+		userID, err := claims.UserID()
+		if err != nil {
+			c.String(http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		// Sessions of other users look the same as missing ones
+		session, err := sessionsRepo.FindSession(c.Param("id"))
+		if err != nil && !errors.Is(err, repository.ErrSessionNotFound) {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		if err != nil || session.UserID != userID {
+			c.String(http.StatusNotFound, repository.ErrSessionNotFound.Error())
+			return
+		}
+
+		if err := revokeSession(session.UserID, session.ID, "", tokensRepo, revokedRepo); err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		c.String(http.StatusOK, "session revoked")
+	}
+}
+
+// startSession records a new session of user on the calling device and
+// issues its first token pair.
+func startSession(
+	c *gin.Context,
+	user model.User,
+	sessionsRepo repository.ISessionsRepository,
+	tokensRepo repository.IRefreshTokensRepository,
+	authService auth.IAuthService) (model.TokenPair, error) {
+	id, err := authService.GenerateTokenID()
+	if err != nil {
+		return model.TokenPair{}, err
+	}
+
+	_, err = sessionsRepo.CreateSession(model.Session{
+		ID:         id,
+		UserID:     user.ID,
+		UserAgent:  truncate(c.Request.UserAgent(), userAgentLength),
+		IP:         c.ClientIP(),
+		LastSeenAt: time.Now(),
+	})
+	if err != nil {
+		return model.TokenPair{}, err
+	}
+
+	return issueTokenPair(user, id, tokensRepo, authService)
+}
+
+// revokeSession revokes the refresh tokens of a session of userID and every
+// access token issued to it. jti additionally revokes a single token that
+// predates sessions.
+func revokeSession(
+	userID int,
+	sessionID, jti string,
+	tokensRepo repository.IRefreshTokensRepository,
+	revokedRepo repository.IRevokedTokensRepository) error {
+	if sessionID != "" {
+		if err := tokensRepo.RevokeRefreshTokenFamily(sessionID); err != nil {
+			return err
+		}
+	}
+
+	_, err := revokedRepo.CreateRevokedToken(model.RevokedToken{
+		JTI:       jti,
+		SessionID: sessionID,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(auth.AccessTokenDuration),
+	})
+	return err
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/darolpz/students/internal/auth"
+	"github.com/darolpz/students/internal/model"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionEndpoints(t *testing.T) {
+	authService := newAuthService(t)
+	john := model.User{ID: 1, Name: "John", Email: "john.doe@gmail.com", Role: auth.RoleUser}
+	jane := model.User{ID: 2, Name: "Jane", Email: "jane@gmail.com", Role: auth.RoleUser}
+	johnToken := bearer(t, authService, john, "laptop")
+
+	tests := []struct {
+		name             string
+		method           string
+		path             string
+		token            string
+		expectedStatus   int
+		expectedSessions []model.Session
+		expectedRevoked  []string
+	}{
+		{
+			name:           "should_list_own_sessions",
+			method:         http.MethodGet,
+			path:           "/auth/sessions",
+			token:          johnToken,
+			expectedStatus: http.StatusOK,
+			expectedSessions: []model.Session{
+				{ID: "laptop", UserID: 1, Current: true},
+				{ID: "phone", UserID: 1},
+			},
+		},
+		{
+			name:           "should_return_unauthorized_list_without_token",
+			method:         http.MethodGet,
+			path:           "/auth/sessions",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:            "should_revoke_own_session",
+			method:          http.MethodDelete,
+			path:            "/auth/sessions/phone",
+			token:           johnToken,
+			expectedStatus:  http.StatusOK,
+			expectedRevoked: []string{"phone"},
+		},
+		{
+			name:           "should_return_not_found_session_of_other_user",
+			method:         http.MethodDelete,
+			path:           "/auth/sessions/tablet",
+			token:          johnToken,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "should_return_not_found_missing_session",
+			method:         http.MethodDelete,
+			path:           "/auth/sessions/desktop",
+			token:          johnToken,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "should_return_unauthorized_revoke_without_token",
+			method:         http.MethodDelete,
+			path:           "/auth/sessions/phone",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "should_return_unauthorized_revoked_session",
+			method:         http.MethodGet,
+			path:           "/auth/sessions",
+			token:          bearer(t, authService, jane, "revoked"),
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessionsRepo := &fakeSessionsRepository{sessions: []model.Session{
+				{ID: "laptop", UserID: 1},
+				{ID: "phone", UserID: 1},
+				{ID: "tablet", UserID: 2},
+			}}
+			tokensRepo := &fakeRefreshTokensRepository{}
+			revokedRepo := &fakeRevokedTokensRepository{revoked: []model.RevokedToken{{SessionID: "revoked", UserID: 2}}}
+			gin.SetMode(gin.TestMode)
+			app := gin.New()
+			CreateAuthEndpoints(app, &fakeUsersRepository{user: &john}, tokensRepo, sessionsRepo, revokedRepo,
+				nil, nil, nil, nil, authService, nopMailer{}, AuthConfig{})
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", tt.token)
+			}
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, req)
+			require.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedSessions != nil {
+				var body struct {
+					Sessions []model.Session `json:"sessions"`
+				}
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+				require.Equal(t, tt.expectedSessions, body.Sessions)
+			}
+			// Revoking a session logs out its refresh and access tokens
+			require.Equal(t, tt.expectedRevoked, tokensRepo.revokedFamilies)
+			if tt.expectedRevoked != nil {
+				require.Len(t, revokedRepo.revoked, 2)
+				require.Equal(t, "phone", revokedRepo.revoked[1].SessionID)
+			}
+		})
+	}
+}
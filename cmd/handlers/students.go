@@ -1,14 +1,25 @@
 package handlers
 
 import (
+	"encoding/json"
 	"errors"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"github.com/darolpz/students/cmd/handlers/middleware"
 	"github.com/darolpz/students/internal/model"
+	"github.com/darolpz/students/internal/patch"
 	"github.com/darolpz/students/internal/repository"
+	"github.com/darolpz/students/internal/search"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+var (
+	ErrOffsetWithCursor = errors.New("offset cannot be combined with a cursor")
+	ErrInvalidStudentID = errors.New("invalid student id")
 )
 
 // FindStudent godoc
@@ -17,8 +28,10 @@ import (
 // @Tags         students
 // @Param        student_id  path string  true  "student_id"  1
 // @Success      200 {object} model.Student
+// @Header       200 {string} ETag "version of the student, for If-Match"
 // @Failure      404  {string} string
 // @Failure      500 {string} string
+// @Failure      403 {object} map[string]string
 // @Router       /students/{student_id} [get]
 // @Security Authorization
 func FindStudent(studentsRepo repository.IStudentsRepository) func(c *gin.Context) {
@@ -38,6 +51,7 @@ func FindStudent(studentsRepo repository.IStudentsRepository) func(c *gin.Contex
 			return
 		}
 
+		c.Header("ETag", studentETag(student))
 		c.JSON(http.StatusOK, gin.H{
 			"student": student,
 		})
@@ -46,69 +60,165 @@ func FindStudent(studentsRepo repository.IStudentsRepository) func(c *gin.Contex
 
 // ListStudent godoc
 // @Summary      List Student
-// @Description  returns all students
+// @Description  returns a page of students, filtered and sorted. Pages are addressed by offset, or by the next and prev cursors of a previous page passed as after and before, which stay stable while students are added. Offset pages include the total number of matches. Next and prev pages are also linked in the Link header.
 // @Tags         students
-// @Param        offset    query     string  false  "list offset"  0
-// @Param        limit    query     string  false  "list limit"  0
-// @Success      200 {array} model.Student
+// @Param        offset    query     int     false  "list offset"  0
+// @Param        limit     query     int     false  "list limit, at most 100"  10
+// @Param        after     query     string  false  "cursor of the page to continue after"
+// @Param        before    query     string  false  "cursor of the page to continue before"
+// @Param        name      query     string  false  "first or last name prefix"
+// @Param        email     query     string  false  "email"
+// @Param        min_age   query     int     false  "minimum age"
+// @Param        max_age   query     int     false  "maximum age"
+// @Param        sort      query     string  false  "comma separated fields among id, first_name, last_name, age and email, prefixed with - for descending order"  "last_name,-age"
+// @Produce      json
+// @Success      200 {object} map[string]interface{}
+// @Header       200 {string} Link "RFC 8288 links to the next and prev pages"
+// @Failure      400 {string} string
 // @Failure      500 {string} string
+// @Failure      403 {object} map[string]string
 // @Router       /students/list [get]
 // @Security Authorization
 func ListStudents(studentsRepo repository.IStudentsRepository) func(c *gin.Context) {
 	return func(c *gin.Context) {
 		// Get query params
-		offset := c.DefaultQuery("offset", "0")
-		limit := c.DefaultQuery("limit", "10")
-
-		offsetInt, err := strconv.Atoi(offset)
-		if err != nil {
+		query := model.StudentQuery{Limit: defaultListLimit}
+		if err := c.ShouldBindQuery(&query); err != nil {
 			c.String(http.StatusBadRequest, err.Error())
 			return
 		}
-		limitInt, err := strconv.Atoi(limit)
+		if query.Offset < 0 || query.Limit < 1 || query.Limit > maxListLimit {
+			c.String(http.StatusBadRequest, ErrInvalidPagination.Error())
+			return
+		}
+		keyset, err := query.Keyset()
 		if err != nil {
 			c.String(http.StatusBadRequest, err.Error())
 			return
 		}
 
-		// Retrieve students from repository
-		students, err := studentsRepo.ListStudents(offsetInt, limitInt)
+		var page studentPage
+		if query.After != "" || query.Before != "" {
+			if query.Offset != 0 {
+				c.String(http.StatusBadRequest, ErrOffsetWithCursor.Error())
+				return
+			}
+			page, err = seekStudents(studentsRepo, query, keyset)
+		} else {
+			page, err = listStudents(studentsRepo, query, keyset)
+		}
 		if err != nil {
 			log.Printf("could not list student: %s", err)
 			c.String(http.StatusInternalServerError, err.Error())
 			return
 		}
 
+		// Link the neighbouring pages
+		var links []string
+		if page.next != nil {
+			links = append(links, pageLink(c, "after", *page.next, "next"))
+		}
+		if page.prev != nil {
+			links = append(links, pageLink(c, "before", *page.prev, "prev"))
+		}
+		if len(links) > 0 {
+			c.Header("Link", strings.Join(links, ", "))
+		}
+
+		response := gin.H{
+			"students": page.students,
+			"limit":    query.Limit,
+			"next":     page.next,
+			"prev":     page.prev,
+		}
+		if page.total != nil {
+			response["total"] = *page.total
+			response["offset"] = query.Offset
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// SearchStudents godoc
+// @Summary      Search students
+// @Description  returns the students best matching every word of q in their first name, last name or email, best first. Words match by prefix and tolerate typos. Highlights hold the matched fields, HTML-escaped, with the matches wrapped in em tags.
+// @Tags         students
+// @Param        q      query     string  true   "words to search for"  "jhon smith"
+// @Param        limit  query     int     false  "how many matches, at most 100"  10
+// @Produce      json
+// @Success      200 {object} map[string][]search.Hit
+// @Failure      400 {string} string
+// @Failure      500 {string} string
+// @Failure      403 {object} map[string]string
+// @Router       /students/search [get]
+// @Security Authorization
+func SearchStudents(searcher search.ISearcher) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		// Get query params
+		query := model.StudentSearchQuery{Limit: defaultListLimit}
+		if err := c.ShouldBindQuery(&query); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		if query.Limit < 1 || query.Limit > maxListLimit {
+			c.String(http.StatusBadRequest, ErrInvalidPagination.Error())
+			return
+		}
+
+		hits, err := searcher.SearchStudents(query.Q, query.Limit)
+		if err != nil {
+			if errors.Is(err, search.ErrEmptyQuery) {
+				c.String(http.StatusBadRequest, err.Error())
+				return
+			}
+			log.Printf("could not search students: %s", err)
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
 		c.JSON(http.StatusOK, gin.H{
-			"students": students,
+			"results": hits,
 		})
 	}
 }
 
 // CreateStudent godoc
 // @Summary      Create student
-// @Description  creates a new student
+// @Description  creates a new student. Its email must not belong to another active student
 // @Tags         students
 // @Param        student body model.Student true "user"
 // @Accept       json
 // @Produce      json
 // @Success      200 {object} model.Student
 // @Failure      400 {string} string
+// @Failure      409 {string} string
+// @Failure      422 {object} map[string][]validation.FieldError
 // @Failure      500 {string} string
+// @Failure      403 {object} map[string]string
 // @Router       /students [post]
 // @Security Authorization
 func CreateStudent(studentsRepo repository.IStudentsRepository) func(c *gin.Context) {
 	return func(c *gin.Context) {
-		newStudent := model.Student{}
-		// Bind the JSON body to the newStudent struct
-		if err := c.BindJSON(&newStudent); err != nil {
+		body, err := c.GetRawData()
+		if err != nil {
 			c.String(http.StatusBadRequest, err.Error())
 			return
 		}
+		// Bind the JSON body to the newStudent struct
+		newStudent, ok := bindStudent(c, body)
+		if !ok {
+			return
+		}
+		// Students can only be deleted through DeleteStudent
+		newStudent.DeletedAt, newStudent.DeletedBy = nil, nil
 
 		// Persist the new student to repository
-		student, err := studentsRepo.CreateStudent(newStudent)
+		student, err := studentsRepo.CreateStudent(newStudent, callerID(c))
 		if err != nil {
+			if errors.Is(err, repository.ErrDuplicateEmail) {
+				c.String(http.StatusConflict, err.Error())
+				return
+			}
 			log.Printf("could not create student: %s", err)
 			c.String(http.StatusInternalServerError, err.Error())
 			return
@@ -122,69 +232,192 @@ func CreateStudent(studentsRepo repository.IStudentsRepository) func(c *gin.Cont
 
 // UpdateStudent godoc
 // @Summary      Update student
-// @Description  modify student data
+// @Description  modify some fields of a student with a JSON Merge Patch, where null removes a field, or a JSON Patch. Plain JSON bodies are read as merge patches. The patched student is validated as when it is replaced, so null cannot remove a field the student requires. With an If-Match header the student is only modified if it still has that ETag, which can be required by configuration
 // @Tags         students
 // @Param        student_id  path string  true  "student_id"  1
-// @Param        student body model.Student true "user"
+// @Param        If-Match    header string  false  "ETag of the student as it was read"
+// @Param        patch body object true "merge patch, such as {\"age\": 34}, or JSON Patch operations, such as [{\"op\": \"replace\", \"path\": \"/age\", \"value\": 34}]"
 // @Accept       json
+// @Accept       application/merge-patch+json
+// @Accept       application/json-patch+json
 // @Produce      json
 // @Success      200 {object} model.Student
+// @Header       200 {string} ETag "new version of the student"
 // @Failure      400 {string} string
 // @Failure      404 {string} string
+// @Failure      409 {string} string
+// @Failure      412 {string} string
+// @Failure      415 {string} string
+// @Failure      422 {object} map[string][]validation.FieldError
+// @Failure      428 {string} string
 // @Failure      500 {string} string
+// @Failure      403 {object} map[string]string
 // @Router       /students/{student_id} [patch]
 // @Security Authorization
-func UpdateStudent(studentsRepo repository.IStudentsRepository) func(c *gin.Context) {
+func UpdateStudent(studentsRepo repository.IStudentsRepository, config StudentsConfig) func(c *gin.Context) {
 	return func(c *gin.Context) {
 		// Get query params
 		studentID := c.Param("id")
-		newStudent := model.Student{}
-		// Bind the JSON body to the newStudent struct
-		if err := c.BindJSON(&newStudent); err != nil {
+		contentType := c.ContentType()
+		// Plain JSON bodies used to be whole students, which merge the same
+		if contentType == binding.MIMEJSON {
+			contentType = patch.MergePatchType
+		}
+		if contentType != patch.MergePatchType && contentType != patch.JSONPatchType {
+			c.String(http.StatusUnsupportedMediaType, patch.ErrUnsupportedType.Error())
+			return
+		}
+		body, err := c.GetRawData()
+		if err != nil {
 			c.String(http.StatusBadRequest, err.Error())
 			return
 		}
-		// Update the student in the repository
-		student, err := studentsRepo.UpdateStudent(studentID, newStudent)
+
+		// The patch applies to the student as it is now, which the update
+		// must not overwrite if someone changed it meanwhile
+		current, ok := findStudent(c, studentsRepo, studentID)
+		if !ok || !preconditionMet(c, current, config.RequireIfMatch) {
+			return
+		}
+		doc, err := json.Marshal(current)
 		if err != nil {
-			if errors.Is(err, repository.ErrStudentNotFound) {
-				log.Printf("could not find student with id %s: %s", studentID, err)
-				c.String(http.StatusNotFound, err.Error())
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		patched, err := patch.Apply(contentType, doc, body)
+		if err != nil {
+			if errors.Is(err, patch.ErrConflict) {
+				c.String(http.StatusConflict, err.Error())
 				return
 			}
-			log.Printf("could not create student: %s", err)
-			c.String(http.StatusInternalServerError, err.Error())
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		// Removing a field the student requires, as null does, is invalid
+		newStudent, ok := bindStudent(c, patched)
+		if !ok {
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"student": student,
-		})
+		saveStudent(c, studentsRepo, studentID, newStudent, current.Version)
 	}
 }
 
+// ReplaceStudent godoc
+// @Summary      Replace student
+// @Description  replace every field of a student, which must all be given. With an If-Match header the student is only modified if it still has that ETag, which can be required by configuration
+// @Tags         students
+// @Param        student_id  path string  true  "student_id"  1
+// @Param        If-Match    header string  false  "ETag of the student as it was read"
+// @Param        student body model.Student true "user"
+// @Accept       json
+// @Produce      json
+// @Success      200 {object} model.Student
+// @Header       200 {string} ETag "new version of the student"
+// @Failure      400 {string} string
+// @Failure      404 {string} string
+// @Failure      409 {string} string
+// @Failure      412 {string} string
+// @Failure      422 {object} map[string][]validation.FieldError
+// @Failure      428 {string} string
+// @Failure      500 {string} string
+// @Failure      403 {object} map[string]string
+// @Router       /students/{student_id} [put]
+// @Security Authorization
+func ReplaceStudent(studentsRepo repository.IStudentsRepository, config StudentsConfig) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		// Get query params
+		studentID := c.Param("id")
+		body, err := c.GetRawData()
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		// Bind the JSON body to the newStudent struct
+		newStudent, ok := bindStudent(c, body)
+		if !ok {
+			return
+		}
+		version, ok := ifMatch(c, studentsRepo, studentID, config.RequireIfMatch)
+		if !ok {
+			return
+		}
+
+		saveStudent(c, studentsRepo, studentID, newStudent, version)
+	}
+}
+
+// saveStudent writes the fields of newStudent, which bindStudent checked,
+// to the student with id, made against version as in UpdateStudent of the
+// repository, and responds with the student saved.
+func saveStudent(c *gin.Context, studentsRepo repository.IStudentsRepository, studentID string, newStudent model.Student, version int) {
+	// Update the student in the repository
+	student, err := studentsRepo.UpdateStudent(studentID, newStudent, version, callerID(c))
+	if err != nil {
+		if errors.Is(err, repository.ErrStudentNotFound) {
+			log.Printf("could not find student with id %s: %s", studentID, err)
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if errors.Is(err, repository.ErrStudentModified) {
+			c.String(http.StatusPreconditionFailed, ErrPreconditionFailed.Error())
+			return
+		}
+		if errors.Is(err, repository.ErrDuplicateEmail) {
+			c.String(http.StatusConflict, err.Error())
+			return
+		}
+		log.Printf("could not update student: %s", err)
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.Header("ETag", studentETag(student))
+	c.JSON(http.StatusOK, gin.H{
+		"student": student,
+	})
+}
+
 // DeleteStudent godoc
 // @Summary      Delete student
-// @Description  delete student data
+// @Description  move a student to the trash, where it can be restored from until it is purged. With an If-Match header the student is only deleted if it still has that ETag, which can be required by configuration
 // @Tags         students
 // @Param        student_id  path string  true  "student_id"  1
+// @Param        If-Match    header string  false  "ETag of the student as it was read"
 // @Success      200 {string} string
+// @Failure      400 {string} string
 // @Failure      404 {string} string "bad request"
+// @Failure      412 {string} string
+// @Failure      428 {string} string
 // @Failure      500 {string} string
+// @Failure      403 {object} map[string]string
 // @Router       /students/{student_id} [delete]
 // @Security Authorization
-func DeleteStudent(studentRepo repository.IStudentsRepository) func(c *gin.Context) {
+func DeleteStudent(studentRepo repository.IStudentsRepository, config StudentsConfig) func(c *gin.Context) {
 	return func(c *gin.Context) {
 		// Get query params
 		studentID := c.Param("id")
-		// Delete the student from the repository
-		err := studentRepo.DeleteStudent(studentID)
+		id, err := strconv.Atoi(studentID)
+		if err != nil {
+			c.String(http.StatusBadRequest, ErrInvalidStudentID.Error())
+			return
+		}
+		version, ok := ifMatch(c, studentRepo, studentID, config.RequireIfMatch)
+		if !ok {
+			return
+		}
+		// Move the student to the trash
+		err = studentRepo.DeleteStudent(id, version, callerID(c))
 		if err != nil {
 			if errors.Is(err, repository.ErrStudentNotFound) {
 				log.Printf("could not find student with id %s: %s", studentID, err)
 				c.String(http.StatusNotFound, err.Error())
 				return
 			}
+			if errors.Is(err, repository.ErrStudentModified) {
+				c.String(http.StatusPreconditionFailed, ErrPreconditionFailed.Error())
+				return
+			}
 			log.Printf("could not delete student: %s", err)
 			c.String(http.StatusInternalServerError, err.Error())
 			return
@@ -193,3 +426,17 @@ func DeleteStudent(studentRepo repository.IStudentsRepository) func(c *gin.Conte
 		c.String(http.StatusOK, "student deleted")
 	}
 }
+
+// callerID returns the ID of the user making the request, or nil when it is
+// made with an API key.
+func callerID(c *gin.Context) *int {
+	claims, ok := middleware.Claims(c)
+	if !ok {
+		return nil
+	}
+	id, err := claims.UserID()
+	if err != nil {
+		return nil
+	}
+	return &id
+}
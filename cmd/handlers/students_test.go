@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/darolpz/students/internal/validation"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateStudent(t *testing.T) {
+	tests := []struct {
+		name           string
+		contentType    string
+		body           string
+		expectedStatus int
+		expectedErrors validation.Errors
+		expectedWrites int
+	}{
+		{
+			name:           "should_merge_patch",
+			contentType:    "application/merge-patch+json",
+			body:           `{"age":34}`,
+			expectedStatus: http.StatusOK,
+			expectedWrites: 1,
+		},
+		{
+			name:           "should_reject_null_age",
+			contentType:    "application/merge-patch+json",
+			body:           `{"age":null}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedErrors: validation.Errors{{Field: "age", Rule: "required", Message: "is required"}},
+		},
+		{
+			name:           "should_reject_removed_email",
+			contentType:    "application/json-patch+json",
+			body:           `[{"op":"remove","path":"/email"}]`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedErrors: validation.Errors{{Field: "email", Rule: "required", Message: "is required"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeStudentsRepository{student: johnDoe()}
+			w := serve(UpdateStudent(repo, StudentsConfig{}), http.MethodPatch, "/students/:id", "/students/1", tt.body,
+				"Content-Type", tt.contentType)
+			require.Equal(t, tt.expectedStatus, w.Code)
+			require.Equal(t, tt.expectedWrites, repo.writes)
+			if tt.expectedErrors != nil {
+				var body struct {
+					Errors validation.Errors `json:"errors"`
+				}
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+				require.Equal(t, tt.expectedErrors, body.Errors)
+			}
+		})
+	}
+}
+
+func TestStudentWrites_Invalid(t *testing.T) {
+	invalid := `{"first_name":" ","last_name":"Doe","age":151,"email":"john"}`
+	expectedErrors := validation.Errors{
+		{Field: "first_name", Rule: "notblank", Message: "is required"},
+		{Field: "age", Rule: "max", Param: "150", Message: "must be at most 150"},
+		{Field: "email", Rule: "email", Message: "must be an email address"},
+	}
+
+	tests := []struct {
+		name    string
+		handler func(repo *fakeStudentsRepository) gin.HandlerFunc
+		method  string
+		path    string
+		body    string
+		headers []string
+	}{
+		{
+			name:    "should_reject_invalid_create",
+			handler: func(repo *fakeStudentsRepository) gin.HandlerFunc { return CreateStudent(repo) },
+			method:  http.MethodPost,
+			path:    "/students",
+			body:    invalid,
+		},
+		{
+			name: "should_reject_invalid_patch",
+			handler: func(repo *fakeStudentsRepository) gin.HandlerFunc {
+				return UpdateStudent(repo, StudentsConfig{})
+			},
+			method:  http.MethodPatch,
+			path:    "/students/1",
+			body:    `{"first_name":" ","age":151,"email":"john"}`,
+			headers: []string{"Content-Type", "application/merge-patch+json"},
+		},
+		{
+			name: "should_reject_invalid_put",
+			handler: func(repo *fakeStudentsRepository) gin.HandlerFunc {
+				return ReplaceStudent(repo, StudentsConfig{})
+			},
+			method:  http.MethodPut,
+			path:    "/students/1",
+			body:    invalid,
+			headers: []string{"If-Match", `"3"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeStudentsRepository{student: johnDoe()}
+			route := "/students"
+			if tt.path != route {
+				route = "/students/:id"
+			}
+			w := serve(tt.handler(repo), tt.method, route, tt.path, tt.body, tt.headers...)
+			require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+			var body struct {
+				Errors validation.Errors `json:"errors"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			require.Equal(t, expectedErrors, body.Errors)
+			require.Zero(t, repo.writes)
+		})
+	}
+}
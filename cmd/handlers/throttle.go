@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"sync"
+	"time"
+
+	"github.com/darolpz/students/internal/auth"
+	"github.com/darolpz/students/internal/repository"
+)
+
+// loginRetryAfter returns how long a client at ip has to wait before trying
+// to log in as email again, or zero if it may try now.
+func loginRetryAfter(throttlesRepo repository.ILoginThrottlesRepository, email, ip string) (time.Duration, error) {
+	accountID := auth.AccountThrottleID(email)
+	throttles, err := throttlesRepo.FindLoginThrottles([]string{accountID, auth.IPThrottleID(ip)})
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	var retryAfter time.Duration
+	for _, throttle := range throttles {
+		policy := auth.IPLockout
+		if throttle.ID == accountID {
+			policy = auth.AccountLockout
+		}
+		if wait := policy.RetryAfter(throttle, now); wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+	return retryAfter, nil
+}
+
+// recordLoginFailure counts a failed login as email from ip. The email is
+// counted whether an account exists for it or not.
+func recordLoginFailure(throttlesRepo repository.ILoginThrottlesRepository, email, ip string) error {
+	now := time.Now()
+	if err := throttlesRepo.RecordLoginFailure(auth.AccountThrottleID(email), now.Add(-auth.AccountLockout.LockoutDuration)); err != nil {
+		return err
+	}
+	return throttlesRepo.RecordLoginFailure(auth.IPThrottleID(ip), now.Add(-auth.IPLockout.LockoutDuration))
+}
+
+var (
+	dummyHash     string
+	dummyHashOnce sync.Once
+)
+
+// checkDummyPassword takes as long as checking a real password, so that
+// unknown emails cannot be told apart by response time.
+func checkDummyPassword(authService auth.IAuthService, password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = authService.GenerateHashPassword("dummy password")
+	})
+	authService.CheckPasswordHash(password, dummyHash)
+}
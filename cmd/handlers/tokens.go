@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/darolpz/students/internal/auth"
+	"github.com/darolpz/students/internal/model"
+	"github.com/darolpz/students/internal/repository"
+)
+
+// issueTokenPair signs a new access token for user and stores a new refresh
+// token in familyID, which is also the ID of the session.
+func issueTokenPair(
+	user model.User,
+	familyID string,
+	tokensRepo repository.IRefreshTokensRepository,
+	authService auth.IAuthService) (model.TokenPair, error) {
+	accessToken, err := authService.GenerateJWT(user, familyID)
+	if err != nil {
+		return model.TokenPair{}, err
+	}
+
+	refreshToken, hash, err := authService.GenerateOpaqueToken()
+	if err != nil {
+		return model.TokenPair{}, err
+	}
+
+	_, err = tokensRepo.CreateRefreshToken(model.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(auth.RefreshTokenDuration),
+	})
+	if err != nil {
+		return model.TokenPair{}, err
+	}
+
+	return model.TokenPair{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+}
+
+// revokeUserTokens revokes every refresh token of userID and every access
+// token issued to it so far.
+func revokeUserTokens(
+	userID int,
+	tokensRepo repository.IRefreshTokensRepository,
+	revokedRepo repository.IRevokedTokensRepository) error {
+	if err := tokensRepo.RevokeUserRefreshTokens(userID); err != nil {
+		return err
+	}
+
+	// Access tokens carry their issue time in microseconds (see main), so the
+	// cut-off only spares tokens issued after it, such as a new session of the
+	// caller
+	now := time.Now()
+	_, err := revokedRepo.CreateRevokedToken(model.RevokedToken{
+		UserID:       userID,
+		IssuedBefore: &now,
+		ExpiresAt:    now.Add(auth.AccessTokenDuration),
+	})
+	return err
+}
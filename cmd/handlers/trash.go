@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/darolpz/students/internal/model"
+	"github.com/darolpz/students/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// ListDeletedStudents godoc
+// @Summary      List deleted students
+// @Description  returns a page of the trash, most recently deleted first, with when and by whom each student was deleted
+// @Tags         students
+// @Param        offset  query  int  false  "list offset"  0
+// @Param        limit   query  int  false  "list limit, at most 100"  10
+// @Produce      json
+// @Success      200 {object} map[string]interface{}
+// @Failure      400 {string} string
+// @Failure      500 {string} string
+// @Failure      403 {object} map[string]string
+// @Router       /students/trash [get]
+// @Security Authorization
+func ListDeletedStudents(studentsRepo repository.IStudentsRepository) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		// Get query params
+		query := model.DeletedStudentQuery{Limit: defaultListLimit}
+		if err := c.ShouldBindQuery(&query); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		if query.Offset < 0 || query.Limit < 1 || query.Limit > maxListLimit {
+			c.String(http.StatusBadRequest, ErrInvalidPagination.Error())
+			return
+		}
+
+		students, total, err := studentsRepo.ListDeletedStudents(query)
+		if err != nil {
+			log.Printf("could not list deleted students: %s", err)
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"students": students,
+			"total":    total,
+			"offset":   query.Offset,
+			"limit":    query.Limit,
+		})
+	}
+}
+
+// RestoreStudent godoc
+// @Summary      Restore student
+// @Description  takes a deleted student out of the trash, unless another student has taken its email since. Restores need no If-Match header, as deleted students have no ETag to read
+// @Tags         students
+// @Param        student_id  path string  true  "student_id"  1
+// @Produce      json
+// @Success      200 {object} model.Student
+// @Header       200 {string} ETag "new version of the student"
+// @Failure      400 {string} string
+// @Failure      404 {string} string
+// @Failure      409 {string} string
+// @Failure      500 {string} string
+// @Failure      403 {object} map[string]string
+// @Router       /students/{student_id}/restore [post]
+// @Security Authorization
+func RestoreStudent(studentsRepo repository.IStudentsRepository) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		// Get query params
+		studentID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.String(http.StatusBadRequest, ErrInvalidStudentID.Error())
+			return
+		}
+
+		student, err := studentsRepo.RestoreStudent(studentID, callerID(c))
+		if err != nil {
+			if errors.Is(err, repository.ErrStudentNotFound) {
+				log.Printf("could not find deleted student with id %d: %s", studentID, err)
+				c.String(http.StatusNotFound, err.Error())
+				return
+			}
+			if errors.Is(err, repository.ErrDuplicateEmail) {
+				c.String(http.StatusConflict, err.Error())
+				return
+			}
+			log.Printf("could not restore student: %s", err)
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		c.Header("ETag", studentETag(student))
+		c.JSON(http.StatusOK, gin.H{
+			"student": student,
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/darolpz/students/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestoreStudent(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		err            error
+		expectedStatus int
+		expectedETag   string
+		expectedWrites int
+	}{
+		{
+			name:           "should_restore_student",
+			path:           "/students/1/restore",
+			expectedStatus: http.StatusOK,
+			expectedETag:   `"4"`,
+			expectedWrites: 1,
+		},
+		{
+			name:           "should_return_bad_request_invalid_id",
+			path:           "/students/1=1/restore",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "should_return_not_found",
+			path:           "/students/1/restore",
+			err:            repository.ErrStudentNotFound,
+			expectedStatus: http.StatusNotFound,
+			expectedWrites: 1,
+		},
+		{
+			name:           "should_return_conflict_email_taken",
+			path:           "/students/1/restore",
+			err:            repository.ErrDuplicateEmail,
+			expectedStatus: http.StatusConflict,
+			expectedWrites: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeStudentsRepository{student: johnDoe(), err: tt.err}
+			w := serve(RestoreStudent(repo), http.MethodPost, "/students/:id/restore", tt.path, "")
+			require.Equal(t, tt.expectedStatus, w.Code)
+			require.Equal(t, tt.expectedETag, w.Header().Get("ETag"))
+			require.Equal(t, tt.expectedWrites, repo.writes)
+		})
+	}
+}
@@ -1,94 +1,412 @@
 package handlers
 
 import (
+	"errors"
+	"io"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/darolpz/students/cmd/handlers/middleware"
 	"github.com/darolpz/students/internal/auth"
+	"github.com/darolpz/students/internal/mail"
 	"github.com/darolpz/students/internal/model"
 	"github.com/darolpz/students/internal/repository"
+	"github.com/darolpz/students/internal/validation"
 	"github.com/gin-gonic/gin"
 )
 
+var (
+	ErrInvalidCredentials  = errors.New("invalid email or password")
+	ErrTooManyAttempts     = errors.New("too many failed login attempts, try again later")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+	ErrAccountDisabled     = errors.New("account disabled")
+)
+
 // Login godoc
 // @Summary      Login
 // @Description  get authorization token
 // @Tags         auth
 // @Param        student body model.Authentication true "Authentication"
 // @Accept       json
-// @Success      200 {string} token
+// @Produce      json
+// @Success      200 {object} model.TokenPair
+// @Success      202 {object} model.MFAChallenge
 // @Failure      400 {string} string
 // @Failure      401 {string} string
+// @Failure      403 {object} model.MFAChallenge
+// @Failure      429 {string} string
 // @Failure      500 {string} string
 // @Router       /auth/login [post]
-func Login(repo repository.IUsersRepository, authService auth.IAuthService) func(c *gin.Context) {
+func Login(
+	repo repository.IUsersRepository,
+	tokensRepo repository.IRefreshTokensRepository,
+	sessionsRepo repository.ISessionsRepository,
+	throttlesRepo repository.ILoginThrottlesRepository,
+	mfaRepo repository.IMFARepository,
+	authService auth.IAuthService,
+	config AuthConfig) func(c *gin.Context) {
 	return func(c *gin.Context) {
-		var auth model.Authentication
-		// Bind the JSON request body to the auth struct.
-		if err := c.BindJSON(&auth); err != nil {
+		var credentials model.Authentication
+		// Bind the JSON request body to the credentials struct.
+		if err := c.BindJSON(&credentials); err != nil {
 			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Check if the account or the client has to wait
+		retryAfter, err := loginRetryAfter(throttlesRepo, credentials.Email, c.ClientIP())
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		if retryAfter > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			c.String(http.StatusTooManyRequests, ErrTooManyAttempts.Error())
+			return
 		}
 
 		// Retrieve user from repository
-		user, err := repo.FindUserByEmail(auth.Email)
+		user, err := repo.FindUserByEmail(credentials.Email)
+		if err != nil && !errors.Is(err, repository.ErrUserNotFound) {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		// Check if password is correct. Unknown emails and wrong passwords
+		// get the same answer, so accounts cannot be enumerated.
+		isPasswordValid := false
+		if err == nil {
+			isPasswordValid = authService.CheckPasswordHash(credentials.Password, user.Password)
+		} else {
+			checkDummyPassword(authService, credentials.Password)
+		}
+		if !isPasswordValid {
+			if err := recordLoginFailure(throttlesRepo, credentials.Email, c.ClientIP()); err != nil {
+				c.String(http.StatusInternalServerError, err.Error())
+				return
+			}
+			c.String(http.StatusUnauthorized, ErrInvalidCredentials.Error())
+			return
+		}
+
+		// Forget the failures of the account once the password is right
+		if err := throttlesRepo.DeleteLoginThrottle(auth.AccountThrottleID(credentials.Email)); err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		// Upgrade hashes produced with another algorithm or weaker parameters
+		// while the password is at hand. Failing to do so is not fatal.
+		if authService.PasswordNeedsRehash(user.Password) {
+			if hash, err := authService.GenerateHashPassword(credentials.Password); err != nil {
+				log.Printf("could not rehash password of user %d: %s", user.ID, err)
+			} else if err := repo.UpdateUserPassword(user.ID, hash); err != nil {
+				log.Printf("could not rehash password of user %d: %s", user.ID, err)
+			}
+		}
+
+		// Check if the email address was confirmed
+		if user.VerifiedAt == nil {
+			c.String(http.StatusForbidden, ErrEmailNotVerified.Error())
+			return
+		}
+
+		completeLogin(c, user, mfaRepo, tokensRepo, sessionsRepo, authService, config)
+	}
+}
+
+// Refresh godoc
+// @Summary      Refresh tokens
+// @Description  exchange a refresh token for a new access and refresh token
+// @Tags         auth
+// @Param        refresh body model.Refresh true "Refresh"
+// @Accept       json
+// @Produce      json
+// @Success      200 {object} model.TokenPair
+// @Failure      400 {string} string
+// @Failure      401 {string} string
+// @Failure      403 {string} string
+// @Failure      500 {string} string
+// @Router       /auth/refresh [post]
+func Refresh(
+	usersRepo repository.IUsersRepository,
+	tokensRepo repository.IRefreshTokensRepository,
+	sessionsRepo repository.ISessionsRepository,
+	revokedRepo repository.IRevokedTokensRepository,
+	authService auth.IAuthService) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var refresh model.Refresh
+		// Bind the JSON request body to the refresh struct.
+		if err := c.BindJSON(&refresh); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Retrieve the stored token by its hash
+		stored, err := tokensRepo.FindRefreshToken(authService.HashToken(refresh.RefreshToken))
 		if err != nil {
+			if errors.Is(err, repository.ErrRefreshTokenNotFound) {
+				c.String(http.StatusUnauthorized, ErrInvalidRefreshToken.Error())
+				return
+			}
 			c.String(http.StatusInternalServerError, err.Error())
 			return
 		}
 
-		// Check if password is correct
-		if isPasswordValid := authService.CheckPasswordHash(auth.Password, user.Password); !isPasswordValid {
-			c.String(http.StatusUnauthorized, "invalid password")
+		if stored.RevokedAt != nil || time.Now().After(stored.ExpiresAt) {
+			c.String(http.StatusUnauthorized, ErrInvalidRefreshToken.Error())
 			return
 		}
 
-		// Generate JWT token
-		token, err := authService.GenerateJWT(user.Email, user.Role)
+		// Mark the token as used. If it was already rotated somebody is
+		// replaying it, so the whole session is revoked, along with the
+		// access tokens issued to it.
+		if err := tokensRepo.RotateRefreshToken(stored.ID); err != nil {
+			if errors.Is(err, repository.ErrRefreshTokenRotated) {
+				log.Printf("refresh token reuse detected for family %s", stored.FamilyID)
+				if err := revokeSession(stored.UserID, stored.FamilyID, "", tokensRepo, revokedRepo); err != nil {
+					c.String(http.StatusInternalServerError, err.Error())
+					return
+				}
+				c.String(http.StatusUnauthorized, ErrRefreshTokenReused.Error())
+				return
+			}
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		// Retrieve the owner of the token
+		user, err := usersRepo.FindUserByID(stored.UserID)
 		if err != nil {
+			if errors.Is(err, repository.ErrUserNotFound) {
+				c.String(http.StatusUnauthorized, ErrInvalidRefreshToken.Error())
+				return
+			}
 			c.String(http.StatusInternalServerError, err.Error())
 			return
 		}
 
-		c.String(http.StatusOK, token)
+		if user.DisabledAt != nil {
+			c.String(http.StatusForbidden, ErrAccountDisabled.Error())
+			return
+		}
+
+		// Issue the next pair in the same family
+		tokens, err := issueTokenPair(user, stored.FamilyID, tokensRepo, authService)
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		// Activity tracking is best effort and never fails the refresh
+		if err := sessionsRepo.TouchSession(stored.FamilyID); err != nil {
+			log.Printf("could not record activity of session %s: %s", stored.FamilyID, err)
+		}
+
+		c.JSON(http.StatusOK, tokens)
 	}
 }
 
 // Register godoc
 // @Summary      Register user
-// @Description  create new user
+// @Description  create a new user, whose name, email address and password are validated. Without an invitation the account gets the user role and a link to verify its address is emailed; this is only possible when open signup is enabled. With an invitation the account gets the role of the invitation and its address counts as verified.
 // @Tags         auth
-// @Param        student body model.User true "user"
+// @Param        invite  query  string  false  "invitation token"
+// @Param        user body model.Registration true "Registration"
 // @Accept       json
-// @Success      200 {object} model.Student
+// @Produce      json
+// @Success      200 {object} model.User
 // @Failure      400 {string} string
+// @Failure      403 {string} string
+// @Failure      422 {object} map[string][]validation.FieldError
 // @Failure      500 {string} string
 // @Router       /auth/register [post]
-func Register(repo repository.IUsersRepository, authService auth.IAuthService) func(c *gin.Context) {
+func Register(
+	repo repository.IUsersRepository,
+	invitationsRepo repository.IInvitationsRepository,
+	authService auth.IAuthService,
+	mailer mail.IMailer,
+	config AuthConfig) func(c *gin.Context) {
 	return func(c *gin.Context) {
-		var newUser model.User
-		// Bind the JSON request body to the newUser struct.
-		if err := c.BindJSON(&newUser); err != nil {
+		var registration model.Registration
+		// Bind the JSON request body to the registration struct.
+		if err := c.BindJSON(&registration); err != nil {
 			c.String(http.StatusBadRequest, err.Error())
 			return
 		}
 
+		// Self-registered accounts never get more than the least privileged
+		// role. Only an invitation can grant another one.
+		newUser := model.User{
+			Name:  registration.Name,
+			Email: registration.Email,
+			Role:  auth.RoleUser,
+		}
+
+		var invitation *model.Invitation
+		if token := c.Query("invite"); token != "" {
+			found, err := findInvitation(invitationsRepo, authService, token)
+			if err != nil {
+				if errors.Is(err, ErrInvalidInvitation) {
+					c.String(http.StatusBadRequest, err.Error())
+					return
+				}
+				c.String(http.StatusInternalServerError, err.Error())
+				return
+			}
+			if newUser.Email == "" {
+				newUser.Email = found.Email
+			}
+			if !strings.EqualFold(newUser.Email, found.Email) {
+				c.String(http.StatusBadRequest, ErrInvitationMismatch.Error())
+				return
+			}
+			invitation = &found
+		} else if !config.OpenSignup {
+			c.String(http.StatusForbidden, ErrSignupClosed.Error())
+			return
+		}
+		// The password is checked against the policy along with the user
+		err := withPassword(validation.Struct(newUser), config.PasswordPolicy, "password", registration.Password, newUser.Email)
+		if !checked(c, err) {
+			return
+		}
+
 		// Hash password
-		password, err := authService.GenerateHashPassword(newUser.Password)
+		password, err := authService.GenerateHashPassword(registration.Password)
 		if err != nil {
 			c.String(http.StatusInternalServerError, err.Error())
 			return
 		}
-
-		// Override password with hashed password
 		newUser.Password = password
 
+		// The invitation reached the address, so it needs no verification
+		if invitation != nil {
+			now := time.Now()
+			newUser.Role = invitation.Role
+			newUser.VerifiedAt = &now
+
+			user, err := invitationsRepo.RedeemInvitation(invitation.ID, newUser)
+			if err != nil {
+				if errors.Is(err, repository.ErrInvitationUsed) {
+					c.String(http.StatusBadRequest, ErrInvalidInvitation.Error())
+					return
+				}
+				c.String(http.StatusInternalServerError, err.Error())
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"user": user.Public(),
+			})
+			return
+		}
+
 		// Persist user to repository
 		user, err := repo.CreateUser(newUser)
 		if err != nil {
 			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		// Send the verification link. The account already exists, so a
+		// failure here is only logged and the user can ask for a new link.
+		if err := sendVerification(user, authService, mailer, config); err != nil {
+			log.Printf("could not send verification to user %d: %s", user.ID, err)
 		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"user": user,
+			"user": user.Public(),
 		})
 	}
 }
+
+// Logout godoc
+// @Summary      Logout
+// @Description  end the current session, revoking its access and refresh tokens. A refresh token given in the body is revoked too.
+// @Tags         auth
+// @Param        refresh body model.Refresh false "Refresh"
+// @Accept       json
+// @Success      200 {string} string
+// @Failure      400 {string} string
+// @Failure      401 {string} string
+// @Failure      500 {string} string
+// @Router       /auth/logout [post]
+// @Security Authorization
+func Logout(
+	tokensRepo repository.IRefreshTokensRepository,
+	revokedRepo repository.IRevokedTokensRepository,
+	authService auth.IAuthService) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		claims, _ := middleware.Claims(c)
+		userID, err := claims.UserID()
+		if err != nil {
+			c.String(http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		// The refresh token is optional
+		var refresh model.Refresh
+		if err := c.ShouldBindJSON(&refresh); err != nil && !errors.Is(err, io.EOF) {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Revoke the access token and the rest of its session
+		if err := revokeSession(userID, claims.SessionID, claims.ID, tokensRepo, revokedRepo); err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		// Revoke the refresh token family, if the token belongs to the caller
+		if refresh.RefreshToken != "" {
+			stored, err := tokensRepo.FindRefreshToken(authService.HashToken(refresh.RefreshToken))
+			if err != nil && !errors.Is(err, repository.ErrRefreshTokenNotFound) {
+				c.String(http.StatusInternalServerError, err.Error())
+				return
+			}
+			if err == nil && stored.UserID == userID {
+				if err := tokensRepo.RevokeRefreshTokenFamily(stored.FamilyID); err != nil {
+					c.String(http.StatusInternalServerError, err.Error())
+					return
+				}
+			}
+		}
+
+		c.String(http.StatusOK, "logged out")
+	}
+}
+
+// LogoutAll godoc
+// @Summary      Logout everywhere
+// @Description  revoke every access and refresh token of the current user
+// @Tags         auth
+// @Success      200 {string} string
+// @Failure      401 {string} string
+// @Failure      500 {string} string
+// @Router       /auth/logout/all [post]
+// @Security Authorization
+func LogoutAll(
+	tokensRepo repository.IRefreshTokensRepository,
+	revokedRepo repository.IRevokedTokensRepository) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		claims, _ := middleware.Claims(c)
+		userID, err := claims.UserID()
+		if err != nil {
+			c.String(http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		if err := revokeUserTokens(userID, tokensRepo, revokedRepo); err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		c.String(http.StatusOK, "logged out everywhere")
+	}
+}
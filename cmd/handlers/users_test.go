@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/darolpz/students/internal/auth"
+	"github.com/darolpz/students/internal/model"
+	"github.com/darolpz/students/internal/repository"
+	"github.com/darolpz/students/internal/validation"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegister_Invalid(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		expectedErrors validation.Errors
+	}{
+		{
+			name: "should_reject_invalid_user",
+			body: `{"name":"","email":"john","password":"correct horse battery staple"}`,
+			expectedErrors: validation.Errors{
+				{Field: "name", Rule: "notblank", Message: "is required"},
+				{Field: "email", Rule: "email", Message: "must be an email address"},
+			},
+		},
+		{
+			name: "should_reject_weak_password",
+			body: `{"name":"John","email":"john.doe@gmail.com","password":"short"}`,
+			expectedErrors: validation.Errors{
+				{Field: "password", Rule: "password", Message: "needs at least 8 characters"},
+			},
+		},
+		{
+			name: "should_reject_invalid_user_and_weak_password",
+			body: `{"name":"","email":"john.doe@gmail.com","password":"john.doe@gmail.com"}`,
+			expectedErrors: validation.Errors{
+				{Field: "name", Rule: "notblank", Message: "is required"},
+				{Field: "password", Rule: "password", Message: "needs something other than the email address"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeUsersRepository{}
+			handler := Register(repo, nil, nil, nil, AuthConfig{OpenSignup: true, PasswordPolicy: auth.DefaultPasswordPolicy})
+
+			w := serve(handler, http.MethodPost, "/users/register", "/users/register", tt.body)
+			require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+			var body struct {
+				Errors validation.Errors `json:"errors"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			require.Equal(t, tt.expectedErrors, body.Errors)
+			require.Zero(t, repo.created)
+		})
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	authService := newAuthService(t)
+	user := &model.User{ID: 1, Name: "John", Email: "john.doe@gmail.com", Role: "user"}
+	token := model.RefreshToken{
+		ID:        1,
+		UserID:    1,
+		FamilyID:  "session",
+		TokenHash: authService.HashToken("refresh"),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+
+	tests := []struct {
+		name            string
+		body            string
+		rotateErr       error
+		expectedStatus  int
+		expectedRevoked []model.RevokedToken
+		expectedIssued  int
+	}{
+		{
+			name:           "should_issue_tokens",
+			body:           `{"refresh_token":"refresh"}`,
+			expectedStatus: http.StatusOK,
+			expectedIssued: 1,
+		},
+		{
+			name:           "should_return_unauthorized_unknown_token",
+			body:           `{"refresh_token":"unknown"}`,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "should_revoke_session_reused_token",
+			body:           `{"refresh_token":"refresh"}`,
+			rotateErr:      repository.ErrRefreshTokenRotated,
+			expectedStatus: http.StatusUnauthorized,
+			expectedRevoked: []model.RevokedToken{
+				{SessionID: "session", UserID: 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokensRepo := &fakeRefreshTokensRepository{token: token, rotateErr: tt.rotateErr}
+			revokedRepo := &fakeRevokedTokensRepository{}
+			handler := Refresh(&fakeUsersRepository{user: user}, tokensRepo, &fakeSessionsRepository{}, revokedRepo, authService)
+
+			w := serve(handler, http.MethodPost, "/auth/refresh", "/auth/refresh", tt.body)
+			require.Equal(t, tt.expectedStatus, w.Code)
+			require.Len(t, tokensRepo.created, tt.expectedIssued)
+			require.Len(t, revokedRepo.revoked, len(tt.expectedRevoked))
+			for i, revoked := range revokedRepo.revoked {
+				revoked.ExpiresAt = time.Time{}
+				require.Equal(t, tt.expectedRevoked[i], revoked)
+			}
+			if tt.expectedRevoked != nil {
+				require.Equal(t, []string{"session"}, tokensRepo.revokedFamilies)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/darolpz/students/internal/auth"
+	"github.com/darolpz/students/internal/model"
+	"github.com/darolpz/students/internal/validation"
+	"github.com/gin-gonic/gin"
+)
+
+// valid checks value against the rules of its validate tags. When it breaks
+// any it responds with the list of fields at fault and returns false.
+func valid(c *gin.Context, value interface{}) bool {
+	return checked(c, validation.Struct(value))
+}
+
+// bindStudent decodes the student in the JSON object doc and checks it as
+// valid does. Every field with rules must have a value in doc, as a missing
+// or null one would otherwise become an empty field or an age of 0. When the
+// student cannot be bound it writes the response and returns false.
+func bindStudent(c *gin.Context, doc []byte) (model.Student, bool) {
+	var student model.Student
+	if err := json.Unmarshal(doc, &student); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return student, false
+	}
+	return student, checked(c, validation.JSON(doc, student))
+}
+
+// checked responds to err, the result of a validation, and reports whether
+// it passed.
+func checked(c *gin.Context, err error) bool {
+	if err == nil {
+		return true
+	}
+	var fields validation.Errors
+	if errors.As(err, &fields) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"errors": fields,
+		})
+		return false
+	}
+	c.String(http.StatusInternalServerError, err.Error())
+	return false
+}
+
+// withPassword adds to err, the result of a validation, the rules of policy
+// that password, sent in field, breaks. A password that meets the policy
+// leaves err as it is.
+func withPassword(err error, policy auth.PasswordPolicy, field, password, email string) error {
+	problems := policy.Problems(password, email)
+	if len(problems) == 0 {
+		return err
+	}
+	var fields validation.Errors
+	if err != nil && !errors.As(err, &fields) {
+		return err
+	}
+	return append(fields, validation.FieldError{
+		Field:   field,
+		Rule:    "password",
+		Message: "needs " + strings.Join(problems, ", "),
+	})
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/darolpz/students/internal/auth"
+	"github.com/darolpz/students/internal/mail"
+	"github.com/darolpz/students/internal/model"
+	"github.com/darolpz/students/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrEmailNotVerified         = errors.New("email address not verified")
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+)
+
+// VerifyEmail godoc
+// @Summary      Verify email
+// @Description  confirm the email address of an account using the emailed link
+// @Tags         auth
+// @Param        token  query string  true  "verification token"
+// @Success      200 {string} string
+// @Failure      400 {string} string
+// @Failure      500 {string} string
+// @Router       /auth/verify [get]
+func VerifyEmail(usersRepo repository.IUsersRepository, authService auth.IAuthService) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		claims, err := authService.CheckVerificationToken(c.Query("token"))
+		if err != nil {
+			c.String(http.StatusBadRequest, ErrInvalidVerificationToken.Error())
+			return
+		}
+		userID, err := claims.UserID()
+		if err != nil {
+			c.String(http.StatusBadRequest, ErrInvalidVerificationToken.Error())
+			return
+		}
+
+		// Retrieve user from repository
+		user, err := usersRepo.FindUserByID(userID)
+		if err != nil {
+			if errors.Is(err, repository.ErrUserNotFound) {
+				c.String(http.StatusBadRequest, ErrInvalidVerificationToken.Error())
+				return
+			}
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		// The link only verifies the address it was sent to
+		if user.Email != claims.Email {
+			c.String(http.StatusBadRequest, ErrInvalidVerificationToken.Error())
+			return
+		}
+
+		if user.VerifiedAt == nil {
+			if err := usersRepo.VerifyUserEmail(user.ID); err != nil {
+				c.String(http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+
+		c.String(http.StatusOK, "email verified")
+	}
+}
+
+// ResendVerification godoc
+// @Summary      Resend verification
+// @Description  send a new verification link. The response is the same whether the account exists or not
+// @Tags         auth
+// @Param        resend body model.ResendVerification true "ResendVerification"
+// @Accept       json
+// @Success      202 {string} string
+// @Failure      400 {string} string
+// @Failure      500 {string} string
+// @Router       /auth/verify/resend [post]
+func ResendVerification(
+	usersRepo repository.IUsersRepository,
+	authService auth.IAuthService,
+	mailer mail.IMailer,
+	config AuthConfig) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var resend model.ResendVerification
+		// Bind the JSON request body to the resend struct.
+		if err := c.BindJSON(&resend); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+
+		const response = "if the account exists and is not verified, a verification link has been sent"
+
+		// Retrieve user from repository
+		user, err := usersRepo.FindUserByEmail(resend.Email)
+		if err != nil {
+			if errors.Is(err, repository.ErrUserNotFound) {
+				c.String(http.StatusAccepted, response)
+				return
+			}
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if user.VerifiedAt == nil {
+			if err := sendVerification(user, authService, mailer, config); err != nil {
+				c.String(http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+
+		c.String(http.StatusAccepted, response)
+	}
+}
+
+// sendVerification emails user a signed link confirming its address.
+func sendVerification(user model.User, authService auth.IAuthService, mailer mail.IMailer, config AuthConfig) error {
+	token, err := authService.GenerateVerificationToken(user)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/auth/verify?token=%s", config.PublicURL, url.QueryEscape(token))
+	sendInBackground(mailer, mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nOpen the link below to verify your email address. It expires in %s.\n\n%s\n",
+			user.Name, auth.VerificationTokenDuration, link),
+	})
+	return nil
+}
@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/darolpz/students/internal/model"
+)
+
+const (
+	// APIKeyPrefix starts every API key, which tells them apart from JWTs in
+	// the Authorization header and makes leaked keys easy to scan for.
+	APIKeyPrefix = "stk_"
+	// APIKeyDisplayLength is how many leading characters of a key are kept
+	// in clear to identify it.
+	APIKeyDisplayLength = 12
+	// APIKeyUsageResolution is how precisely the last use of a key is
+	// tracked.
+	APIKeyUsageResolution = time.Minute
+)
+
+var ErrUnknownScope = errors.New("unknown scope")
+
+// IsAPIKey reports whether a credential looks like an API key.
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, APIKeyPrefix)
+}
+
+// FormatScopes validates scopes and joins them as stored in APIKey.Scopes.
+func FormatScopes(scopes []string) (string, error) {
+	for _, scope := range scopes {
+		if !isPermission(scope) {
+			return "", fmt.Errorf("%w: %s", ErrUnknownScope, scope)
+		}
+	}
+	return strings.Join(scopes, " "), nil
+}
+
+// APIKeyHasScope reports whether key was granted permission.
+func APIKeyHasScope(key model.APIKey, permission Permission) bool {
+	for _, scope := range strings.Fields(key.Scopes) {
+		if Permission(scope) == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyActive reports whether key is neither revoked nor expired at now.
+func APIKeyActive(key model.APIKey, now time.Time) bool {
+	return key.RevokedAt == nil && (key.ExpiresAt == nil || now.Before(*key.ExpiresAt))
+}
+
+func isPermission(scope string) bool {
+	for _, p := range Permissions {
+		if string(p) == scope {
+			return true
+		}
+	}
+	return false
+}
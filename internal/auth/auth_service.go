@@ -1,12 +1,17 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
+	"github.com/darolpz/students/internal/model"
 	"github.com/golang-jwt/jwt/v4"
-	"golang.org/x/crypto/bcrypt"
 )
 
 var (
@@ -14,40 +19,167 @@ var (
 
 	ErrParseToken   = errors.New("couldn't parse token")
 	ErrInvalidToken = errors.New("token is not valid")
+
+	ErrGenerateToken = errors.New("couldn't generate token")
+)
+
+const (
+	// AccessTokenDuration is the lifetime of the JWT returned by GenerateJWT.
+	AccessTokenDuration = 1 * time.Hour
+	// RefreshTokenDuration is the lifetime of each refresh token. Every
+	// rotation issues a new token with a fresh expiry.
+	RefreshTokenDuration = 30 * 24 * time.Hour
+	// PasswordResetDuration is how long a password reset token can be used.
+	PasswordResetDuration = 1 * time.Hour
+	// VerificationTokenDuration is how long an email verification link works.
+	VerificationTokenDuration = 24 * time.Hour
+	// MFATokenDuration is how long a user has to complete a second factor
+	// challenge or a forced enrollment after entering the password.
+	MFATokenDuration = 5 * time.Minute
+	// OIDCStateDuration is how long a user has to log in at the identity
+	// provider.
+	OIDCStateDuration = 10 * time.Minute
+	// InvitationDuration is how long an invitation can be redeemed when the
+	// admin does not choose an expiry.
+	InvitationDuration = 7 * 24 * time.Hour
+)
+
+// Every token signed by the service names what it is for in its aud claim,
+// so that a token issued for one purpose is rejected everywhere else.
+const (
+	audienceAccess            = "access"
+	audienceEmailVerification = "email-verification"
+	audienceOIDCState         = "oidc-state"
 )
 
+// MFAPurpose is what an MFA token allows its bearer to do.
+type MFAPurpose string
+
+const (
+	// MFAChallenge tokens are exchanged together with a valid code for an
+	// access token.
+	MFAChallenge MFAPurpose = "mfa-challenge"
+	// MFAEnrollment tokens only allow enrolling a second factor, for users
+	// whose role requires one.
+	MFAEnrollment MFAPurpose = "mfa-enrollment"
+)
+
+// audienceClaims are claims whose aud can be checked.
+type audienceClaims interface {
+	jwt.Claims
+	VerifyAudience(cmp string, req bool) bool
+}
+
+// JWTClaim are the claims of an access token. The registered sub claim
+// holds the user ID and jti a unique token ID used for revocation. sid names
+// the session the token was issued to.
 type JWTClaim struct {
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// UserID returns the ID of the user the token was issued to.
+func (c JWTClaim) UserID() (int, error) {
+	return subjectUserID(c.RegisteredClaims)
+}
+
+// VerificationClaim are the claims of an email verification token. The email
+// is included so that a link stops working if the address changes.
+type VerificationClaim struct {
 	Email string `json:"email"`
-	Role  string `json:"role"`
 	jwt.RegisteredClaims
 }
 
+// UserID returns the ID of the user whose address is being verified.
+func (c VerificationClaim) UserID() (int, error) {
+	return subjectUserID(c.RegisteredClaims)
+}
+
+// MFAClaim are the claims of a token issued after a correct password, while
+// the second factor is still pending.
+type MFAClaim struct {
+	jwt.RegisteredClaims
+}
+
+// UserID returns the ID of the user who entered the password.
+func (c MFAClaim) UserID() (int, error) {
+	return subjectUserID(c.RegisteredClaims)
+}
+
+// OIDCStateClaim are the claims of the cookie that carries an OpenID Connect
+// login from the redirect to the identity provider to the callback. The
+// token ID is the state parameter.
+type OIDCStateClaim struct {
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+	jwt.RegisteredClaims
+}
+
+func subjectUserID(claims jwt.RegisteredClaims) (int, error) {
+	id, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid subject %q", ErrInvalidToken, claims.Subject)
+	}
+	return id, nil
+}
+
 type IAuthService interface {
-	GenerateJWT(email, role string) (string, error)
+	GenerateJWT(user model.User, sessionID string) (string, error)
 	GenerateHashPassword(password string) (string, error)
 	CheckPasswordHash(password, hash string) bool
+	PasswordNeedsRehash(hash string) bool
 	CheckToken(signedToken string) (*jwt.Token, error)
+	GenerateOpaqueToken() (token, hash string, err error)
+	HashToken(token string) string
+	GenerateTokenID() (string, error)
+	JWKS() JWKS
+	GenerateVerificationToken(user model.User) (string, error)
+	CheckVerificationToken(signedToken string) (*VerificationClaim, error)
+	GenerateMFAToken(user model.User, purpose MFAPurpose) (string, error)
+	CheckMFAToken(signedToken string, purpose MFAPurpose) (*MFAClaim, error)
+	GenerateOIDCStateToken(state, nonce, codeVerifier string) (string, error)
+	CheckOIDCStateToken(signedToken string) (*OIDCStateClaim, error)
 }
 
 type authService struct {
-	secretkey string
+	keys   *KeySet
+	hasher IPasswordHasher
 }
 
-func NewAuthService(secret string) IAuthService {
-	return authService{secretkey: secret}
+func NewAuthService(keys *KeySet, hasher IPasswordHasher) IAuthService {
+	return authService{keys: keys, hasher: hasher}
 }
 
-func (s authService) GenerateJWT(email, role string) (string, error) {
-	var mySigningKey = []byte(s.secretkey)
+func (s authService) GenerateJWT(user model.User, sessionID string) (string, error) {
+	jti, err := s.GenerateTokenID()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
 	claims := &JWTClaim{
-		Email: email,
-		Role:  role,
+		Email:     user.Email,
+		Role:      user.Role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(1 * time.Hour)),
+			ID:        jti,
+			Subject:   strconv.Itoa(user.ID),
+			Audience:  jwt.ClaimStrings{audienceAccess},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenDuration)),
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(mySigningKey)
+	return s.sign(claims)
+}
+
+// sign signs claims with the active key, naming it in the kid header so
+// verifiers can pick the right public key.
+func (s authService) sign(claims jwt.Claims) (string, error) {
+	key := s.keys.Active()
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	tokenString, err := token.SignedString(key.PrivateKey)
 	if err != nil {
 		return "", fmt.Errorf("%w: %s", ErrSignToken, err)
 	}
@@ -55,33 +187,149 @@ func (s authService) GenerateJWT(email, role string) (string, error) {
 }
 
 func (s authService) GenerateHashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
-	return string(bytes), err
+	return s.hasher.Hash(password)
 }
 
 func (s authService) CheckPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+	return s.hasher.Verify(password, hash)
+}
+
+// PasswordNeedsRehash reports whether hash should be replaced by a hash of
+// the configured algorithm and parameters next time the password is known.
+func (s authService) PasswordNeedsRehash(hash string) bool {
+	return s.hasher.NeedsRehash(hash)
 }
 
 func (s authService) CheckToken(signedToken string) (*jwt.Token, error) {
-	var mySigningKey = []byte(s.secretkey)
+	return s.parse(signedToken, &JWTClaim{}, audienceAccess)
+}
+
+// parse verifies signedToken against the key named by its kid header,
+// decodes it into claims and checks it was issued for audience.
+func (s authService) parse(signedToken string, claims audienceClaims, audience string) (*jwt.Token, error) {
 	token, err := jwt.ParseWithClaims(
 		signedToken,
-		&JWTClaim{},
+		claims,
 		func(t *jwt.Token) (interface{}, error) {
-			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-				return t, fmt.Errorf("there was an error in parsing")
+			kid, _ := t.Header["kid"].(string)
+			key, err := s.keys.Find(kid)
+			if err != nil {
+				return nil, err
+			}
+			// Never let the token pick the algorithm
+			if t.Method.Alg() != key.Method.Alg() {
+				return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
 			}
-			return []byte(mySigningKey), nil
+			return key.PrivateKey.Public(), nil
 		})
 	if err != nil {
 		return nil, fmt.Errorf("%w: %s", ErrParseToken, err)
 	}
 
-	if !token.Valid {
+	if !token.Valid || !claims.VerifyAudience(audience, true) {
 		return nil, ErrInvalidToken
 	}
 
 	return token, nil
 }
+
+// GenerateOpaqueToken returns an opaque random token, such as a refresh or
+// password reset token, to hand out to the client and the hash under which it
+// should be stored.
+func (s authService) GenerateOpaqueToken() (string, string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", "", fmt.Errorf("%w: %s", ErrGenerateToken, err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(bytes)
+	return token, s.HashToken(token), nil
+}
+
+// HashToken returns the hex encoded SHA-256 of an opaque token. Tokens are
+// high entropy random values, so a fast unsalted hash is enough.
+func (s authService) HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateTokenID returns a random identifier suitable for token IDs and
+// token families.
+func (s authService) GenerateTokenID() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", fmt.Errorf("%w: %s", ErrGenerateToken, err)
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// JWKS returns the public keys tokens can be verified with.
+func (s authService) JWKS() JWKS {
+	return s.keys.JWKS()
+}
+
+// GenerateVerificationToken signs a token proving control of user's email.
+func (s authService) GenerateVerificationToken(user model.User) (string, error) {
+	now := time.Now()
+	claims := &VerificationClaim{
+		Email: user.Email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.Itoa(user.ID),
+			Audience:  jwt.ClaimStrings{audienceEmailVerification},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(VerificationTokenDuration)),
+		},
+	}
+	return s.sign(claims)
+}
+
+func (s authService) CheckVerificationToken(signedToken string) (*VerificationClaim, error) {
+	claims := &VerificationClaim{}
+	if _, err := s.parse(signedToken, claims, audienceEmailVerification); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (s authService) GenerateMFAToken(user model.User, purpose MFAPurpose) (string, error) {
+	now := time.Now()
+	claims := &MFAClaim{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.Itoa(user.ID),
+			Audience:  jwt.ClaimStrings{string(purpose)},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(MFATokenDuration)),
+		},
+	}
+	return s.sign(claims)
+}
+
+func (s authService) CheckMFAToken(signedToken string, purpose MFAPurpose) (*MFAClaim, error) {
+	claims := &MFAClaim{}
+	if _, err := s.parse(signedToken, claims, string(purpose)); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (s authService) GenerateOIDCStateToken(state, nonce, codeVerifier string) (string, error) {
+	now := time.Now()
+	claims := &OIDCStateClaim{
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        state,
+			Audience:  jwt.ClaimStrings{audienceOIDCState},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(OIDCStateDuration)),
+		},
+	}
+	return s.sign(claims)
+}
+
+func (s authService) CheckOIDCStateToken(signedToken string) (*OIDCStateClaim, error) {
+	claims := &OIDCStateClaim{}
+	if _, err := s.parse(signedToken, claims, audienceOIDCState); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
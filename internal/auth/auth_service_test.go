@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/darolpz/students/internal/model"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthService_TokenAudiences(t *testing.T) {
+	keys, err := GenerateKeySet()
+	require.NoError(t, err)
+	service := NewAuthService(keys, newTestHasher(t))
+	user := model.User{ID: 7, Email: "john.doe@gmail.com", Role: RoleUser}
+
+	accessToken, err := service.GenerateJWT(user, "session")
+	require.NoError(t, err)
+	verificationToken, err := service.GenerateVerificationToken(user)
+	require.NoError(t, err)
+	challengeToken, err := service.GenerateMFAToken(user, MFAChallenge)
+	require.NoError(t, err)
+
+	// Each token is only accepted for its own purpose
+	_, err = service.CheckToken(verificationToken)
+	require.ErrorIs(t, err, ErrInvalidToken)
+	_, err = service.CheckVerificationToken(accessToken)
+	require.ErrorIs(t, err, ErrInvalidToken)
+	_, err = service.CheckToken(challengeToken)
+	require.ErrorIs(t, err, ErrInvalidToken)
+	_, err = service.CheckMFAToken(challengeToken, MFAEnrollment)
+	require.ErrorIs(t, err, ErrInvalidToken)
+	_, err = service.CheckMFAToken(accessToken, MFAChallenge)
+	require.ErrorIs(t, err, ErrInvalidToken)
+	_, err = service.CheckMFAToken(challengeToken, MFAChallenge)
+	require.NoError(t, err)
+
+	token, err := service.CheckToken(accessToken)
+	require.NoError(t, err)
+	require.Equal(t, "session", token.Claims.(*JWTClaim).SessionID)
+
+	claims, err := service.CheckVerificationToken(verificationToken)
+	require.NoError(t, err)
+	require.Equal(t, "john.doe@gmail.com", claims.Email)
+	id, err := claims.UserID()
+	require.NoError(t, err)
+	require.Equal(t, 7, id)
+}
+
+func TestAuthService_IssuedAtPrecision(t *testing.T) {
+	// The server sets the precision on start up
+	precision := jwt.TimePrecision
+	jwt.TimePrecision = time.Microsecond
+	defer func() { jwt.TimePrecision = precision }()
+
+	keys, err := GenerateKeySet()
+	require.NoError(t, err)
+	service := NewAuthService(keys, newTestHasher(t))
+	user := model.User{ID: 7, Email: "john.doe@gmail.com", Role: RoleUser}
+
+	before := time.Now().Truncate(time.Microsecond)
+	accessToken, err := service.GenerateJWT(user, "session")
+	require.NoError(t, err)
+	after := time.Now()
+
+	// A revocation made before the token was issued must not cover it, even
+	// within the same second
+	token, err := service.CheckToken(accessToken)
+	require.NoError(t, err)
+	issuedAt := token.Claims.(*JWTClaim).IssuedAt.Time
+	require.False(t, issuedAt.Before(before))
+	require.False(t, issuedAt.After(after))
+}
@@ -0,0 +1,255 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var (
+	ErrLoadKey        = errors.New("couldn't load signing key")
+	ErrUnsupportedKey = errors.New("unsupported signing key")
+	ErrNoActiveKey    = errors.New("active signing key not found")
+	ErrUnknownKey     = errors.New("unknown signing key")
+)
+
+// SigningKey is a private key used to sign tokens, identified by its kid.
+type SigningKey struct {
+	ID         string
+	Method     jwt.SigningMethod
+	PrivateKey crypto.Signer
+}
+
+// NewSigningKey wraps an RSA (RS256) or Ed25519 (EdDSA) private key.
+func NewSigningKey(id string, key crypto.Signer) (SigningKey, error) {
+	switch key.(type) {
+	case *rsa.PrivateKey:
+		return SigningKey{ID: id, Method: jwt.SigningMethodRS256, PrivateKey: key}, nil
+	case ed25519.PrivateKey:
+		return SigningKey{ID: id, Method: jwt.SigningMethodEdDSA, PrivateKey: key}, nil
+	}
+	return SigningKey{}, fmt.Errorf("%w: %T", ErrUnsupportedKey, key)
+}
+
+// KeySet holds every key tokens may be signed with. New tokens are signed
+// with the active key only, while tokens signed with any key of the set are
+// accepted. Rotating means adding a new key, making it active, and removing
+// the previous one once the tokens it signed have expired.
+type KeySet struct {
+	active string
+	keys   map[string]SigningKey
+}
+
+func NewKeySet(active string, keys ...SigningKey) (*KeySet, error) {
+	set := &KeySet{active: active, keys: map[string]SigningKey{}}
+	for _, key := range keys {
+		set.keys[key.ID] = key
+	}
+	if _, ok := set.keys[active]; !ok {
+		return nil, fmt.Errorf("%w: %q", ErrNoActiveKey, active)
+	}
+	return set, nil
+}
+
+// LoadKeySet reads every *.pem private key in dir. The kid of each key is its
+// file name without the extension. If active is empty, the last kid in
+// lexical order is used, so date based names rotate naturally.
+func LoadKeySet(dir, active string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrLoadKey, err)
+	}
+	sort.Strings(paths)
+
+	keys := make([]SigningKey, 0, len(paths))
+	for _, path := range paths {
+		id := strings.TrimSuffix(filepath.Base(path), ".pem")
+		key, err := loadSigningKey(id, path)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	if active == "" && len(keys) > 0 {
+		active = keys[len(keys)-1].ID
+	}
+	return NewKeySet(active, keys...)
+}
+
+// GenerateKeySet returns a set with a single random Ed25519 key. Tokens
+// signed with it do not survive a restart, so it is only meant for local
+// development and tests.
+func GenerateKeySet() (*KeySet, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrLoadKey, err)
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrLoadKey, err)
+	}
+	key, err := NewSigningKey(hex.EncodeToString(id), private)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeySet(key.ID, key)
+}
+
+func loadSigningKey(id, path string) (SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return SigningKey{}, fmt.Errorf("%w: %s", ErrLoadKey, err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return SigningKey{}, fmt.Errorf("%w: %s is not PEM encoded", ErrLoadKey, path)
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return SigningKey{}, fmt.Errorf("%w: %s has PEM type %q", ErrUnsupportedKey, path, block.Type)
+	}
+	if err != nil {
+		return SigningKey{}, fmt.Errorf("%w: %s: %s", ErrLoadKey, path, err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return SigningKey{}, fmt.Errorf("%w: %T", ErrUnsupportedKey, key)
+	}
+	return NewSigningKey(id, signer)
+}
+
+// Active returns the key new tokens are signed with.
+func (k *KeySet) Active() SigningKey {
+	return k.keys[k.active]
+}
+
+// Find returns the key identified by id.
+func (k *KeySet) Find(id string) (SigningKey, error) {
+	key, ok := k.keys[id]
+	if !ok {
+		return SigningKey{}, fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+	return key, nil
+}
+
+// JWKS returns the public half of every key in the set.
+func (k *KeySet) JWKS() JWKS {
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	jwks := JWKS{Keys: make([]JWK, 0, len(ids))}
+	for _, id := range ids {
+		jwks.Keys = append(jwks.Keys, newJWK(k.keys[id]))
+	}
+	return jwks
+}
+
+// JWKS is a JSON Web Key Set as defined by RFC 7517.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK is the public part of a signing key as defined by RFC 7517.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	// RSA keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519 and EC keys
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	Y     string `json:"y,omitempty"`
+}
+
+func newJWK(key SigningKey) JWK {
+	jwk := JWK{KeyID: key.ID, Use: "sig", Algorithm: key.Method.Alg()}
+	switch public := key.PrivateKey.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	}
+	return jwk
+}
+
+// PublicKey decodes the key, so that tokens signed by other issuers can be
+// verified. RSA, EC and Ed25519 keys are supported.
+func (j JWK) PublicKey() (crypto.PublicKey, error) {
+	switch j.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(j.N)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedKey, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(j.E)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedKey, err)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch j.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("%w: curve %s", ErrUnsupportedKey, j.Curve)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedKey, err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(j.Y)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedKey, err)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		if j.Curve != "Ed25519" {
+			return nil, fmt.Errorf("%w: curve %s", ErrUnsupportedKey, j.Curve)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: invalid Ed25519 key", ErrUnsupportedKey)
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("%w: key type %s", ErrUnsupportedKey, j.KeyType)
+}
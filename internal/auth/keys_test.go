@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/darolpz/students/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func writeKey(t *testing.T, dir, name string, key interface{}) {
+	bytes, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: bytes})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), data, 0600))
+}
+
+func TestLoadKeySet_Rotation(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	writeKey(t, dir, "2022-06.pem", rsaKey)
+
+	// Sign a token with the only key
+	keys, err := LoadKeySet(dir, "")
+	require.NoError(t, err)
+	require.Equal(t, "2022-06", keys.Active().ID)
+	oldToken, err := NewAuthService(keys, newTestHasher(t)).GenerateJWT(model.User{ID: 1, Email: "john.doe@gmail.com", Role: RoleUser}, "")
+	require.NoError(t, err)
+
+	// Rotate to a newer Ed25519 key
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	writeKey(t, dir, "2022-07.pem", edKey)
+
+	keys, err = LoadKeySet(dir, "")
+	require.NoError(t, err)
+	require.Equal(t, "2022-07", keys.Active().ID)
+	service := NewAuthService(keys, newTestHasher(t))
+
+	// Tokens signed before the rotation are still accepted
+	_, err = service.CheckToken(oldToken)
+	require.NoError(t, err)
+
+	newToken, err := service.GenerateJWT(model.User{ID: 1, Email: "john.doe@gmail.com", Role: RoleUser}, "")
+	require.NoError(t, err)
+	token, err := service.CheckToken(newToken)
+	require.NoError(t, err)
+	require.Equal(t, "2022-07", token.Header["kid"])
+	require.Equal(t, "EdDSA", token.Method.Alg())
+
+	// Both public keys are published
+	jwks := service.JWKS()
+	require.Len(t, jwks.Keys, 2)
+	require.Equal(t, "RSA", jwks.Keys[0].KeyType)
+	require.Equal(t, "RS256", jwks.Keys[0].Algorithm)
+	require.Equal(t, "OKP", jwks.Keys[1].KeyType)
+	require.Equal(t, "Ed25519", jwks.Keys[1].Curve)
+}
+
+func TestLoadKeySet_UnknownActiveKey(t *testing.T) {
+	dir := t.TempDir()
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	writeKey(t, dir, "2022-07.pem", edKey)
+
+	_, err = LoadKeySet(dir, "2022-08")
+	require.ErrorIs(t, err, ErrNoActiveKey)
+}
+
+func TestJWK_PublicKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	for _, private := range []crypto.Signer{rsaKey, edKey} {
+		key, err := NewSigningKey("key", private)
+		require.NoError(t, err)
+
+		public, err := newJWK(key).PublicKey()
+		require.NoError(t, err)
+		require.Equal(t, key.PrivateKey.Public(), public)
+	}
+
+	_, err = JWK{KeyType: "oct"}.PublicKey()
+	require.ErrorIs(t, err, ErrUnsupportedKey)
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/darolpz/students/internal/model"
+)
+
+// LockoutPolicy decides how long a client has to wait after failed logins.
+// The first FreeAttempts failures cost nothing, each further failure doubles
+// the wait starting at BaseDelay, and MaxAttempts failures lock the key for
+// LockoutDuration. Failures older than LockoutDuration are forgotten.
+type LockoutPolicy struct {
+	FreeAttempts    int
+	BaseDelay       time.Duration
+	MaxAttempts     int
+	LockoutDuration time.Duration
+}
+
+var (
+	// AccountLockout throttles logins to a single email address.
+	AccountLockout = LockoutPolicy{
+		FreeAttempts:    3,
+		BaseDelay:       1 * time.Second,
+		MaxAttempts:     10,
+		LockoutDuration: 15 * time.Minute,
+	}
+	// IPLockout throttles logins from a single client address. Several
+	// users may share an address, so it is more lenient.
+	IPLockout = LockoutPolicy{
+		FreeAttempts:    20,
+		BaseDelay:       1 * time.Second,
+		MaxAttempts:     100,
+		LockoutDuration: 15 * time.Minute,
+	}
+)
+
+// AccountThrottleID returns the throttle key of an email address. Logins may
+// send addresses of any length, so the key holds a SHA-256 hash of the
+// address to fit the id column.
+func AccountThrottleID(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return "email:" + hex.EncodeToString(sum[:])
+}
+
+// IPThrottleID returns the throttle key of a client address.
+func IPThrottleID(ip string) string {
+	return "ip:" + ip
+}
+
+// MFAThrottleID returns the throttle key of the second factor of a user.
+// Failed codes are throttled with the AccountLockout policy.
+func MFAThrottleID(userID int) string {
+	return "mfa:" + strconv.Itoa(userID)
+}
+
+// Stale reports whether the failures of t are old enough to be forgotten.
+func (p LockoutPolicy) Stale(t model.LoginThrottle, now time.Time) bool {
+	return now.Sub(t.LastFailureAt) >= p.LockoutDuration
+}
+
+// RetryAfter returns how long the key of t must wait before its next login
+// attempt, or zero if it may try now.
+func (p LockoutPolicy) RetryAfter(t model.LoginThrottle, now time.Time) time.Duration {
+	if p.Stale(t, now) || t.Failures <= p.FreeAttempts {
+		return 0
+	}
+
+	wait := p.LockoutDuration
+	if t.Failures < p.MaxAttempts {
+		wait = p.BaseDelay
+		for i := p.FreeAttempts + 1; i < t.Failures && wait < p.LockoutDuration; i++ {
+			wait *= 2
+		}
+		if wait > p.LockoutDuration {
+			wait = p.LockoutDuration
+		}
+	}
+
+	remaining := t.LastFailureAt.Add(wait).Sub(now)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/darolpz/students/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockoutPolicy_RetryAfter(t *testing.T) {
+	policy := LockoutPolicy{
+		FreeAttempts:    3,
+		BaseDelay:       time.Second,
+		MaxAttempts:     10,
+		LockoutDuration: 15 * time.Minute,
+	}
+	now := time.Date(2022, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		failures      int
+		lastFailureAt time.Time
+		want          time.Duration
+	}{
+		{
+			name:          "should_allow_free_attempts",
+			failures:      3,
+			lastFailureAt: now,
+			want:          0,
+		},
+		{
+			name:          "should_delay_first_attempt_after_free_ones",
+			failures:      4,
+			lastFailureAt: now,
+			want:          time.Second,
+		},
+		{
+			name:          "should_double_delay_per_failure",
+			failures:      6,
+			lastFailureAt: now,
+			want:          4 * time.Second,
+		},
+		{
+			name:          "should_subtract_elapsed_time",
+			failures:      6,
+			lastFailureAt: now.Add(-3 * time.Second),
+			want:          time.Second,
+		},
+		{
+			name:          "should_allow_after_delay",
+			failures:      6,
+			lastFailureAt: now.Add(-5 * time.Second),
+			want:          0,
+		},
+		{
+			name:          "should_lock_after_max_attempts",
+			failures:      10,
+			lastFailureAt: now.Add(-5 * time.Minute),
+			want:          10 * time.Minute,
+		},
+		{
+			name:          "should_forget_stale_failures",
+			failures:      10,
+			lastFailureAt: now.Add(-15 * time.Minute),
+			want:          0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			throttle := model.LoginThrottle{ID: "email:john.doe@gmail.com", Failures: tt.failures, LastFailureAt: tt.lastFailureAt}
+			require.Equal(t, tt.want, policy.RetryAfter(throttle, now))
+		})
+	}
+}
+
+func TestAccountThrottleID(t *testing.T) {
+	id := AccountThrottleID(" John.Doe@gmail.com")
+	require.Equal(t, AccountThrottleID("john.doe@gmail.com"), id)
+	require.Len(t, id, len("email:")+64)
+
+	// Long addresses still fit the id column
+	long := AccountThrottleID(strings.Repeat("a", 300) + "@gmail.com")
+	require.Len(t, long, len(id))
+	require.NotEqual(t, id, long)
+}
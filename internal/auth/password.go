@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// DefaultBcryptCost is the bcrypt cost used when none is configured.
+	DefaultBcryptCost = 12
+	argon2idPrefix    = "$argon2id$"
+)
+
+var (
+	ErrHashPassword        = errors.New("couldn't hash password")
+	ErrInvalidHasherConfig = errors.New("invalid password hasher configuration")
+	ErrInvalidPasswordHash = errors.New("invalid password hash")
+)
+
+// IPasswordHasher hashes passwords in a self-describing format. Every hasher
+// verifies hashes of any supported algorithm, so the algorithm can change
+// without locking out users, and reports the hashes that should be replaced
+// because they were produced with another algorithm or weaker parameters.
+type IPasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(password, hash string) bool
+	NeedsRehash(hash string) bool
+}
+
+// verifyPassword checks password against a hash of any supported algorithm.
+func verifyPassword(password, hash string) bool {
+	if strings.HasPrefix(hash, argon2idPrefix) {
+		params, salt, key, err := decodeArgon2id(hash)
+		if err != nil {
+			return false
+		}
+		other := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+		return subtle.ConstantTimeCompare(key, other) == 1
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+type bcryptHasher struct {
+	cost int
+}
+
+// NewBcryptHasher returns a hasher producing bcrypt hashes of cost.
+func NewBcryptHasher(cost int) (IPasswordHasher, error) {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("%w: bcrypt cost %d", ErrInvalidHasherConfig, cost)
+	}
+	return bcryptHasher{cost: cost}, nil
+}
+
+func (h bcryptHasher) Hash(password string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrHashPassword, err)
+	}
+	return string(bytes), nil
+}
+
+func (h bcryptHasher) Verify(password, hash string) bool {
+	return verifyPassword(password, hash)
+}
+
+func (h bcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost < h.cost
+}
+
+// Argon2idParams are the cost parameters of Argon2id as named by RFC 9106.
+// Memory is in KiB.
+type Argon2idParams struct {
+	Memory     uint32
+	Time       uint32
+	Threads    uint8
+	SaltLength uint32
+	KeyLength  uint32
+}
+
+// DefaultArgon2idParams follow the second recommended option of RFC 9106.
+var DefaultArgon2idParams = Argon2idParams{
+	Memory:     64 * 1024,
+	Time:       3,
+	Threads:    4,
+	SaltLength: 16,
+	KeyLength:  32,
+}
+
+type argon2idHasher struct {
+	params Argon2idParams
+}
+
+// NewArgon2idHasher returns a hasher producing Argon2id hashes in the PHC
+// string format, $argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<key>.
+func NewArgon2idHasher(params Argon2idParams) (IPasswordHasher, error) {
+	if params.Time < 1 || params.Threads < 1 || params.Memory < 8*uint32(params.Threads) ||
+		params.SaltLength < 8 || params.KeyLength < 16 {
+		return nil, fmt.Errorf("%w: argon2id %+v", ErrInvalidHasherConfig, params)
+	}
+	return argon2idHasher{params: params}, nil
+}
+
+func (h argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("%w: %s", ErrHashPassword, err)
+	}
+	key := argon2.IDKey([]byte(password), salt, h.params.Time, h.params.Memory, h.params.Threads, h.params.KeyLength)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix,
+		argon2.Version,
+		h.params.Memory,
+		h.params.Time,
+		h.params.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h argon2idHasher) Verify(password, hash string) bool {
+	return verifyPassword(password, hash)
+}
+
+func (h argon2idHasher) NeedsRehash(hash string) bool {
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return true
+	}
+	return params.Memory < h.params.Memory ||
+		params.Time < h.params.Time ||
+		uint32(len(salt)) < h.params.SaltLength ||
+		uint32(len(key)) < h.params.KeyLength
+}
+
+func decodeArgon2id(hash string) (Argon2idParams, []byte, []byte, error) {
+	var params Argon2idParams
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, ErrInvalidPasswordHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrInvalidPasswordHash
+	}
+	_, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads)
+	if err != nil || params.Time < 1 || params.Threads < 1 {
+		return params, nil, nil, ErrInvalidPasswordHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrInvalidPasswordHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrInvalidPasswordHash
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
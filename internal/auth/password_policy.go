@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var ErrWeakPassword = errors.New("password does not meet the policy")
+
+// PasswordPolicy is what a new password must satisfy. MinLength counts
+// characters; MaxLength counts bytes, since bcrypt ignores anything past 72.
+type PasswordPolicy struct {
+	MinLength        int
+	MaxLength        int
+	RequireMixedCase bool
+	RequireDigit     bool
+	RequireSymbol    bool
+}
+
+// DefaultPasswordPolicy only enforces a sensible length.
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLength: 8,
+	MaxLength: 72,
+}
+
+// Validate returns an ErrWeakPassword listing every rule password breaks.
+// A password equal to the email address of its owner is never accepted.
+func (p PasswordPolicy) Validate(password, email string) error {
+	if problems := p.Problems(password, email); len(problems) > 0 {
+		return fmt.Errorf("%w: needs %s", ErrWeakPassword, strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// Problems describes every rule password breaks, as what it needs, such as
+// "a digit". It is empty for passwords that meet the policy.
+func (p PasswordPolicy) Problems(password, email string) []string {
+	var problems []string
+	if utf8.RuneCountInString(password) < p.MinLength {
+		problems = append(problems, fmt.Sprintf("at least %d characters", p.MinLength))
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		problems = append(problems, fmt.Sprintf("at most %d bytes", p.MaxLength))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.RequireMixedCase && !(upper && lower) {
+		problems = append(problems, "upper and lower case letters")
+	}
+	if p.RequireDigit && !digit {
+		problems = append(problems, "a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		problems = append(problems, "a symbol")
+	}
+	if email != "" && strings.EqualFold(password, email) {
+		problems = append(problems, "something other than the email address")
+	}
+	return problems
+}
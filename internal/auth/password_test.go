@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testArgon2idParams keep the tests fast.
+var testArgon2idParams = Argon2idParams{Memory: 64, Time: 1, Threads: 1, SaltLength: 16, KeyLength: 32}
+
+func newTestHasher(t *testing.T) IPasswordHasher {
+	hasher, err := NewBcryptHasher(bcrypt.MinCost)
+	require.NoError(t, err)
+	return hasher
+}
+
+func TestPasswordHashers(t *testing.T) {
+	bcryptHasher, err := NewBcryptHasher(bcrypt.MinCost)
+	require.NoError(t, err)
+	argon2idHasher, err := NewArgon2idHasher(testArgon2idParams)
+	require.NoError(t, err)
+
+	for name, hasher := range map[string]IPasswordHasher{"bcrypt": bcryptHasher, "argon2id": argon2idHasher} {
+		t.Run(name, func(t *testing.T) {
+			hash, err := hasher.Hash("correct horse")
+			require.NoError(t, err)
+			require.True(t, hasher.Verify("correct horse", hash))
+			require.False(t, hasher.Verify("wrong horse", hash))
+			require.False(t, hasher.NeedsRehash(hash))
+
+			// Hashes are salted
+			other, err := hasher.Hash("correct horse")
+			require.NoError(t, err)
+			require.NotEqual(t, hash, other)
+		})
+	}
+
+	argon2idHash, err := argon2idHasher.Hash("correct horse")
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(argon2idHash, "$argon2id$v=19$m=64,t=1,p=1$"))
+
+	// Hashes of the other algorithm still verify but should be replaced
+	bcryptHash, err := bcryptHasher.Hash("correct horse")
+	require.NoError(t, err)
+	require.True(t, argon2idHasher.Verify("correct horse", bcryptHash))
+	require.True(t, argon2idHasher.NeedsRehash(bcryptHash))
+	require.True(t, bcryptHasher.Verify("correct horse", argon2idHash))
+	require.True(t, bcryptHasher.NeedsRehash(argon2idHash))
+
+	// Hashes produced with weaker parameters should be replaced
+	strongerBcrypt, err := NewBcryptHasher(bcrypt.MinCost + 1)
+	require.NoError(t, err)
+	require.True(t, strongerBcrypt.NeedsRehash(bcryptHash))
+	strongerParams := testArgon2idParams
+	strongerParams.Time = 2
+	strongerArgon2id, err := NewArgon2idHasher(strongerParams)
+	require.NoError(t, err)
+	require.True(t, strongerArgon2id.NeedsRehash(argon2idHash))
+
+	// Malformed hashes never verify
+	for _, hash := range []string{"", "plain", "$argon2id$v=19$m=64,t=0,p=0$c2FsdA$a2V5", argon2idHash[:len(argon2idHash)-4] + "AAAA"} {
+		require.False(t, argon2idHasher.Verify("correct horse", hash), hash)
+	}
+
+	_, err = NewBcryptHasher(64)
+	require.ErrorIs(t, err, ErrInvalidHasherConfig)
+	_, err = NewArgon2idHasher(Argon2idParams{})
+	require.ErrorIs(t, err, ErrInvalidHasherConfig)
+}
+
+func TestPasswordPolicy_Validate(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 10, MaxLength: 72, RequireMixedCase: true, RequireDigit: true, RequireSymbol: true}
+
+	tests := []struct {
+		name          string
+		password      string
+		email         string
+		expectedError error
+	}{
+		{
+			name:     "should_accept_strong_password",
+			password: "Correct horse 7",
+		},
+		{
+			name:          "should_reject_short_password",
+			password:      "Sh0rt!",
+			expectedError: ErrWeakPassword,
+		},
+		{
+			name:          "should_reject_long_password",
+			password:      "Aa1!" + strings.Repeat("x", 72),
+			expectedError: ErrWeakPassword,
+		},
+		{
+			name:          "should_reject_password_without_digit",
+			password:      "Correct horse!",
+			expectedError: ErrWeakPassword,
+		},
+		{
+			name:          "should_reject_password_without_symbol",
+			password:      "Correcthorse7",
+			expectedError: ErrWeakPassword,
+		},
+		{
+			name:          "should_reject_single_case_password",
+			password:      "correct horse 7",
+			expectedError: ErrWeakPassword,
+		},
+		{
+			name:          "should_reject_email_as_password",
+			password:      "John.Doe1@gmail.com",
+			email:         "john.doe1@gmail.com",
+			expectedError: ErrWeakPassword,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.password, tt.email)
+			if tt.expectedError != nil {
+				require.True(t, errors.Is(err, tt.expectedError))
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
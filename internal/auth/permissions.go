@@ -0,0 +1,43 @@
+package auth
+
+// Roles stored in the users.role column.
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+// Permission is an action a caller may perform on a resource.
+type Permission string
+
+const (
+	PermissionReadStudents  Permission = "students:read"
+	PermissionWriteStudents Permission = "students:write"
+	// PermissionTrashStudents allows browsing and restoring deleted students.
+	PermissionTrashStudents Permission = "students:trash"
+)
+
+// Permissions lists every permission, which are also the scopes an API key
+// can be granted.
+var Permissions = []Permission{PermissionReadStudents, PermissionWriteStudents, PermissionTrashStudents}
+
+// RolePermissions lists the permissions granted to each role.
+var RolePermissions = map[string][]Permission{
+	RoleAdmin: {PermissionReadStudents, PermissionWriteStudents, PermissionTrashStudents},
+	RoleUser:  {PermissionReadStudents},
+}
+
+// HasPermission reports whether role is granted permission.
+func HasPermission(role string, permission Permission) bool {
+	for _, p := range RolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRole reports whether role is one of the known roles.
+func IsRole(role string) bool {
+	_, ok := RolePermissions[role]
+	return ok
+}
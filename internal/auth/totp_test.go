@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateTOTP(t *testing.T) {
+	// Secret of the RFC 6238 SHA1 test vectors
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		name     string
+		code     string
+		now      time.Time
+		wantStep int64
+		wantOK   bool
+	}{
+		{
+			name:     "should_accept_rfc_vector_59",
+			code:     "287082",
+			now:      time.Unix(59, 0),
+			wantStep: 1,
+			wantOK:   true,
+		},
+		{
+			name:     "should_accept_rfc_vector_1111111109",
+			code:     "081804",
+			now:      time.Unix(1111111109, 0),
+			wantStep: 37037036,
+			wantOK:   true,
+		},
+		{
+			name:     "should_accept_previous_period",
+			code:     "081804",
+			now:      time.Unix(1111111109+30, 0),
+			wantStep: 37037036,
+			wantOK:   true,
+		},
+		{
+			name: "should_reject_code_two_periods_old",
+			code: "081804",
+			now:  time.Unix(1111111109+60, 0),
+		},
+		{
+			name: "should_reject_wrong_code",
+			code: "123456",
+			now:  time.Unix(59, 0),
+		},
+		{
+			name: "should_reject_malformed_code",
+			code: "28708",
+			now:  time.Unix(59, 0),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := ValidateTOTP(secret, tt.code, tt.now)
+			require.Equal(t, tt.wantOK, ok)
+			require.Equal(t, tt.wantStep, step)
+		})
+	}
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	uri := TOTPProvisioningURI("students", "john.doe@gmail.com", "JBSWY3DPEHPK3PXP")
+	require.Equal(t, "otpauth://totp/students:john.doe@gmail.com?algorithm=SHA1&digits=6&issuer=students&period=30&secret=JBSWY3DPEHPK3PXP", uri)
+}
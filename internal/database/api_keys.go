@@ -0,0 +1,73 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/darolpz/students/internal/model"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrFindAPIKey     = errors.New("couldn't find api key")
+	ErrListAPIKeys    = errors.New("couldn't list api keys")
+	ErrCreateAPIKey   = errors.New("couldn't create api key")
+	ErrRevokeAPIKey   = errors.New("couldn't revoke api key")
+	ErrTouchAPIKey    = errors.New("couldn't record api key use")
+)
+
+func (s databaseService) CreateAPIKey(key model.APIKey) (model.APIKey, error) {
+	if err := s.db.Create(&key).Error; err != nil {
+		return key, fmt.Errorf("%w: %s", ErrCreateAPIKey, err)
+	}
+	return key, nil
+}
+
+func (s databaseService) ListAPIKeys() ([]model.APIKey, error) {
+	var keys []model.APIKey
+	if err := s.db.Order("id").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrListAPIKeys, err)
+	}
+	return keys, nil
+}
+
+func (s databaseService) FindAPIKey(hash string) (model.APIKey, error) {
+	var key model.APIKey
+	if err := s.db.First(&key, "key_hash = ?", hash).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return key, fmt.Errorf("%w: %s", ErrAPIKeyNotFound, err)
+		}
+		return key, fmt.Errorf("%w: %s", ErrFindAPIKey, err)
+	}
+	return key, nil
+}
+
+// RevokeAPIKey revokes an active key. Revoking a missing or already revoked
+// key fails with ErrAPIKeyNotFound.
+func (s databaseService) RevokeAPIKey(id int) error {
+	result := s.db.Model(&model.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("%w: %s", ErrRevokeAPIKey, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// TouchAPIKey records that a key was used now. The row is only written when
+// the previous use is older than before, so busy keys don't cost a write per
+// request.
+func (s databaseService) TouchAPIKey(id int, before time.Time) error {
+	err := s.db.Model(&model.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, before).
+		Update("last_used_at", time.Now()).Error
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrTouchAPIKey, err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func Test_databaseService_RevokeAPIKey(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	dialector := mysql.New(mysql.Config{
+		Conn:                      mockDB,
+		DriverName:                "mysql",
+		DSN:                       "sqlmock_db",
+		SkipInitializeWithVersion: true,
+	})
+
+	db, err := gorm.Open(dialector)
+	require.NoError(t, err)
+	s := databaseService{
+		db: db,
+	}
+
+	query := regexp.QuoteMeta("UPDATE `api_keys` SET `revoked_at`=? WHERE id = ? AND revoked_at IS NULL")
+
+	tests := []struct {
+		name          string
+		setMock       func(mock sqlmock.Sqlmock)
+		expectedError error
+	}{
+		{
+			name: "should_revoke_api_key",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(query).
+					WithArgs(sqlmock.AnyArg(), 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "should_return_error_api_key_not_found",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(query).
+					WithArgs(sqlmock.AnyArg(), 1).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			},
+			expectedError: ErrAPIKeyNotFound,
+		},
+		{
+			name: "should_return_error_revoke_api_key",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(query).
+					WithArgs(sqlmock.AnyArg(), 1).
+					WillReturnError(errors.New("some error"))
+				mock.ExpectRollback()
+			},
+			expectedError: ErrRevokeAPIKey,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setMock(mock)
+			err := s.RevokeAPIKey(1)
+			if tt.expectedError != nil {
+				require.Error(t, err)
+				require.True(t, errors.Is(err, tt.expectedError))
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
@@ -3,20 +3,82 @@ package database
 import (
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/darolpz/students/internal/model"
+	mysqldriver "github.com/go-sql-driver/mysql"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type IDatabaseService interface {
 	FindStudent(id string) (model.Student, error)
-	ListStudents(offset, limit int) ([]model.Student, error)
-	CreateStudent(student model.Student) (model.Student, error)
-	UpdateStudent(id string, student model.Student) (model.Student, error)
+	ListStudents(query model.StudentQuery) ([]model.Student, int64, error)
+	SeekStudents(query model.StudentQuery) ([]model.Student, error)
+	SearchStudents(terms []string, limit int) ([]model.Student, error)
+	EachStudent(query model.StudentQuery, fn func(student model.Student) error) error
+	FindStudentsByEmail(emails []string) ([]model.Student, error)
+	SaveStudents(students []model.Student, changedBy *int) ([]model.Student, error)
+	CreateStudent(student model.Student, changedBy *int) (model.Student, error)
+	UpdateStudent(id string, student model.Student, version int, changedBy *int) (model.Student, error)
 	FindUserByEmail(email string) (model.User, error)
+	FindUserByID(id int) (model.User, error)
 	CreateUser(user model.User) (model.User, error)
-	DeleteStudent(id string) error
+	UpdateUserPassword(id int, password string) error
+	VerifyUserEmail(id int) error
+	UpdateUserRole(id int, role string) error
+	ListUsers(query model.UserQuery) ([]model.User, int64, error)
+	UpdateUser(id int, update model.UserUpdate) (model.User, error)
+	SetUserDisabled(id int, disabled bool) error
+	DeleteUser(id int) error
+	DeleteStudent(id int, version int, deletedBy *int) error
+	ListDeletedStudents(query model.DeletedStudentQuery) ([]model.Student, int64, error)
+	RestoreStudent(id int, restoredBy *int) (model.Student, error)
+	PurgeDeletedStudents(before time.Time) error
+	ListStudentVersions(id int) ([]model.StudentVersion, error)
+	FindStudentVersion(id int, version int) (model.StudentVersion, error)
+	RevertStudent(id int, version int, current int, changedBy *int) (model.Student, error)
+	CreateRefreshToken(token model.RefreshToken) (model.RefreshToken, error)
+	FindRefreshToken(hash string) (model.RefreshToken, error)
+	RotateRefreshToken(id int) error
+	RevokeRefreshTokenFamily(familyID string) error
+	RevokeUserRefreshTokens(userID int) error
+	CreateRevokedToken(token model.RevokedToken) (model.RevokedToken, error)
+	IsTokenRevoked(jti, sessionID string, userID int, issuedAt time.Time) (bool, error)
+	DeleteExpiredRevokedTokens() error
+	CreatePasswordReset(reset model.PasswordReset) (model.PasswordReset, error)
+	FindPasswordReset(hash string) (model.PasswordReset, error)
+	UsePasswordReset(id int) error
+	FindLoginThrottles(ids []string) ([]model.LoginThrottle, error)
+	RecordLoginFailure(id string, resetBefore time.Time) error
+	DeleteLoginThrottle(id string) error
+	DeleteStaleLoginThrottles(before time.Time) error
+	FindMFAEnrollment(userID int) (model.MFAEnrollment, error)
+	CreateMFAEnrollment(enrollment model.MFAEnrollment) (model.MFAEnrollment, error)
+	EnableMFAEnrollment(userID int, step int64, recoveryCodeHashes []string) error
+	UseMFAStep(userID int, step int64) error
+	UseMFARecoveryCode(userID int, hash string) error
+	DeleteMFAEnrollment(userID int) error
+	CreateAPIKey(key model.APIKey) (model.APIKey, error)
+	ListAPIKeys() ([]model.APIKey, error)
+	FindAPIKey(hash string) (model.APIKey, error)
+	RevokeAPIKey(id int) error
+	TouchAPIKey(id int, before time.Time) error
+	FindUserIdentity(issuer, subject string) (model.UserIdentity, error)
+	CreateUserIdentity(identity model.UserIdentity) (model.UserIdentity, error)
+	ProvisionUser(user model.User, identity model.UserIdentity) (model.User, error)
+	CreateInvitation(invitation model.Invitation) (model.Invitation, error)
+	ListInvitations() ([]model.Invitation, error)
+	FindInvitation(hash string) (model.Invitation, error)
+	RedeemInvitation(id int, user model.User) (model.User, error)
+	DeleteInvitation(id int) error
+	CreateSession(session model.Session) (model.Session, error)
+	FindSession(id string) (model.Session, error)
+	ListSessions(userID int) ([]model.Session, error)
+	TouchSession(id string) error
+	DeleteStaleSessions(before time.Time) error
 }
 
 type databaseService struct {
@@ -30,14 +92,33 @@ var (
 	ErrFindStudent        = errors.New("couldn't find student")
 	ErrStudentNotFound    = errors.New("student not found")
 	ErrListStudents       = errors.New("couldn't list students")
+	ErrSearchStudents     = errors.New("couldn't search students")
 	ErrCreateStudent      = errors.New("couldn't create student")
 	ErrUpdateStudent      = errors.New("couldn't update student")
 	ErrUserNotFound       = errors.New("user not found")
 	ErrFindUser           = errors.New("couldn't find user")
 	ErrCreateUser         = errors.New("couldn't create user")
+	ErrUpdateUser         = errors.New("couldn't update user")
 	ErrDeleteStudent      = errors.New("couldn't delete student")
+	ErrRestoreStudent     = errors.New("couldn't restore student")
+	ErrStudentModified    = errors.New("student was modified since it was read")
+	ErrDuplicateEmail     = errors.New("email already belongs to another student")
 )
 
+// duplicateEntry is the number of the MySQL error for a duplicate key.
+const duplicateEntry = 1062
+
+// studentWriteError wraps err, the failure of a write to students, in fail,
+// or in ErrDuplicateEmail when the email is taken by an active student.
+// Email is the only unique column students can write.
+func studentWriteError(fail, err error) error {
+	var mysqlErr *mysqldriver.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == duplicateEntry {
+		return fmt.Errorf("%w: %s", ErrDuplicateEmail, err)
+	}
+	return fmt.Errorf("%w: %s", fail, err)
+}
+
 func NewDatabaseService(dbUser, dbPass, dbHost, dbPort, dbName string) (*databaseService, error) {
 	database, err := gorm.Open(mysql.Open(fmt.Sprintf(connectionFormat, dbUser, dbPass, dbHost, dbPort, dbName)))
 	if err != nil {
@@ -46,9 +127,15 @@ func NewDatabaseService(dbUser, dbPass, dbHost, dbPort, dbName string) (*databas
 	return &databaseService{db: database}, nil
 }
 
+// NewDatabaseServiceFromDB returns a service over an open connection.
+func NewDatabaseServiceFromDB(db *gorm.DB) *databaseService {
+	return &databaseService{db: db}
+}
+
+// FindStudent returns the student with id unless it was deleted.
 func (s databaseService) FindStudent(id string) (model.Student, error) {
 	var student model.Student
-	if err := s.db.First(&student, id).Error; err != nil {
+	if err := s.db.Where("deleted_at IS NULL").First(&student, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return student, fmt.Errorf("%w: %s", ErrStudentNotFound, err)
 		}
@@ -58,41 +145,242 @@ func (s databaseService) FindStudent(id string) (model.Student, error) {
 	return student, nil
 }
 
-func (s databaseService) ListStudents(offset, limit int) ([]model.Student, error) {
+// ListStudents returns a page of the students matching query, in the order
+// it asks for, and how many match in total.
+func (s databaseService) ListStudents(query model.StudentQuery) ([]model.Student, int64, error) {
+	sort, err := query.SortFields()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	tx := filterStudents(s.db.Model(&model.Student{}), query)
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("%w: %s", ErrListStudents, err)
+	}
+
+	var students []model.Student
+	if err := orderBy(tx, sort).Limit(query.Limit).Offset(query.Offset).Find(&students).Error; err != nil {
+		return nil, 0, fmt.Errorf("%w: %s", ErrListStudents, err)
+	}
+	return students, total, nil
+}
+
+// SeekStudents returns a page of the students matching query using keyset
+// pagination: instead of skipping Offset rows, the page starts right after,
+// or ends right before, the row its cursor points at. Pages come back in the
+// order query asks for either way.
+func (s databaseService) SeekStudents(query model.StudentQuery) ([]model.Student, error) {
+	keyset, err := query.Keyset()
+	if err != nil {
+		return nil, err
+	}
+
+	// A backward page is read in reverse order from the cursor on
+	sort := keyset.Sort
+	if keyset.Backward {
+		sort = reverseSort(sort)
+	}
+
+	tx := filterStudents(s.db.Model(&model.Student{}), query)
+	if keyset.Values != nil {
+		tx = seek(tx, sort, keyset.Values)
+	}
 	var students []model.Student
-	if err := s.db.Limit(limit).Offset(offset).Find(&students).Error; err != nil {
-		return students, fmt.Errorf("%w: %s", ErrListStudents, err)
+	if err := orderBy(tx, sort).Limit(query.Limit).Find(&students).Error; err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrListStudents, err)
+	}
+
+	if keyset.Backward {
+		for i, j := 0, len(students)-1; i < j; i, j = i+1, j-1 {
+			students[i], students[j] = students[j], students[i]
+		}
 	}
 	return students, nil
 }
 
-func (s databaseService) CreateStudent(student model.Student) (model.Student, error) {
-	if err := s.db.Create(&student).Error; err != nil {
-		return student, fmt.Errorf("%w: %s", ErrCreateStudent, err)
+// EachStudent calls fn with every student matching the filters of query, in
+// the order it asks for, reading them one at a time instead of loading them
+// all. It stops at the first error fn returns, and returns it.
+func (s databaseService) EachStudent(query model.StudentQuery, fn func(student model.Student) error) error {
+	sort, err := query.SortFields()
+	if err != nil {
+		return err
+	}
+
+	tx := orderBy(filterStudents(s.db.Model(&model.Student{}), query), sort)
+	rows, err := tx.Rows()
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrListStudents, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var student model.Student
+		if err := tx.ScanRows(rows, &student); err != nil {
+			return fmt.Errorf("%w: %s", ErrListStudents, err)
+		}
+		if err := fn(student); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("%w: %s", ErrListStudents, err)
+	}
+	return nil
+}
+
+// studentsFullText is the full-text index over the searchable student
+// columns. MATCH must name exactly the columns of the index.
+const studentsFullText = "MATCH(first_name, last_name, email) AGAINST (? IN BOOLEAN MODE)"
+
+// SearchStudents returns the candidates for a search of terms: up to limit
+// full-text matches, best first, followed by up to limit more students with
+// a name sounding like a term. Terms are words without full-text operators.
+// The full-text index finds words starting with a term, while names sounding
+// alike catch misspelt names, even when other terms are spelt right, and
+// terms too short for the index. SOUNDS LIKE cannot use the index. Callers
+// are expected to rank the candidates themselves.
+func (s databaseService) SearchStudents(terms []string, limit int) ([]model.Student, error) {
+	prefixes := make([]string, len(terms))
+	for i, term := range terms {
+		prefixes[i] = term + "*"
+	}
+	against := strings.Join(prefixes, " ")
+
+	var students []model.Student
+	err := s.db.Where("deleted_at IS NULL").
+		Where(studentsFullText, against).
+		Clauses(clause.OrderBy{Expression: clause.Expr{SQL: studentsFullText + " DESC", Vars: []interface{}{against}}}).
+		Limit(limit).
+		Find(&students).Error
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrSearchStudents, err)
+	}
+
+	conditions := make([]string, len(terms))
+	args := make([]interface{}, 0, 2*len(terms))
+	for i, term := range terms {
+		conditions[i] = "first_name SOUNDS LIKE ? OR last_name SOUNDS LIKE ?"
+		args = append(args, term, term)
+	}
+	var soundAlike []model.Student
+	err = s.db.Where("deleted_at IS NULL").
+		Where(strings.Join(conditions, " OR "), args...).
+		Limit(limit).
+		Find(&soundAlike).Error
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrSearchStudents, err)
+	}
+
+	found := make(map[int]bool, len(students))
+	for _, student := range students {
+		found[student.ID] = true
+	}
+	for _, student := range soundAlike {
+		if !found[student.ID] {
+			students = append(students, student)
+		}
+	}
+	return students, nil
+}
+
+// filterStudents applies the filters of query to tx, which never matches
+// deleted students.
+func filterStudents(tx *gorm.DB, query model.StudentQuery) *gorm.DB {
+	tx = tx.Where("deleted_at IS NULL")
+	if query.Name != "" {
+		name := escapeLike(query.Name) + "%"
+		tx = tx.Where("first_name LIKE ? OR last_name LIKE ?", name, name)
+	}
+	if query.Email != "" {
+		tx = tx.Where("email = ?", query.Email)
+	}
+	if query.MinAge != nil {
+		tx = tx.Where("age >= ?", *query.MinAge)
+	}
+	if query.MaxAge != nil {
+		tx = tx.Where("age <= ?", *query.MaxAge)
+	}
+	return tx
+}
+
+// CreateStudent creates student and starts its history. It fails with
+// ErrDuplicateEmail when an active student has the email. changedBy is the
+// user making the change, or nil for API keys, here and in every other
+// method that records history.
+func (s databaseService) CreateStudent(student model.Student, changedBy *int) (model.Student, error) {
+	student.Version = 1
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&student).Error; err != nil {
+			return studentWriteError(ErrCreateStudent, err)
+		}
+		return recordVersion(tx, model.StudentCreated, nil, student, changedBy)
+	})
+	if err != nil {
+		return student, err
 	}
 	return student, nil
 }
 
-func (s databaseService) UpdateStudent(id string, newStudent model.Student) (model.Student, error) {
+// UpdateStudent replaces the fields of the student with id. A version other
+// than 0 is the one the caller read: the update fails with
+// ErrStudentModified when the student has changed since. It fails with
+// ErrDuplicateEmail as CreateStudent does. An update that changes nothing
+// leaves the student at its version.
+func (s databaseService) UpdateStudent(id string, newStudent model.Student, version int, changedBy *int) (model.Student, error) {
 	var student model.Student
-	if err := s.db.First(&student, id).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return student, fmt.Errorf("%w: %s", ErrStudentNotFound, err)
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("deleted_at IS NULL").First(&student, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: %s", ErrStudentNotFound, err)
+			}
+			return fmt.Errorf("%w: %s", ErrFindStudent, err)
+		}
+		if version != 0 && student.Version != version {
+			return ErrStudentModified
 		}
-		return student, fmt.Errorf("%w: %s", ErrFindStudent, err)
-	}
 
-	student.FirstName = newStudent.FirstName
-	student.LastName = newStudent.LastName
-	student.Email = newStudent.Email
-	student.Age = newStudent.Age
+		before := student
+		student.FirstName = newStudent.FirstName
+		student.LastName = newStudent.LastName
+		student.Email = newStudent.Email
+		student.Age = newStudent.Age
+		if len(model.DiffStudents(&before, student)) == 0 {
+			return nil
+		}
 
-	if err := s.db.Save(&student).Error; err != nil {
-		return student, fmt.Errorf("%w: %s", ErrUpdateStudent, err)
+		if err := saveStudent(tx, &student); err != nil {
+			return err
+		}
+		return recordVersion(tx, model.StudentUpdated, &before, student, changedBy)
+	})
+	if err != nil {
+		return student, err
 	}
 	return student, nil
 }
 
+// saveStudent writes student over the version of it that was read, bumping
+// its version. It fails with ErrStudentModified when another write got
+// there first.
+func saveStudent(tx *gorm.DB, student *model.Student) error {
+	saved := *student
+	saved.Version++
+	result := tx.Model(&saved).
+		Where("version = ?", student.Version).
+		Select("first_name", "last_name", "age", "email", "deleted_at", "deleted_by", "version").
+		Updates(&saved)
+	if result.Error != nil {
+		return studentWriteError(ErrUpdateStudent, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrStudentModified
+	}
+	*student = saved
+	return nil
+}
+
 func (s databaseService) FindUserByEmail(email string) (model.User, error) {
 	var user model.User
 	if err := s.db.First(&user, "email = ?", email).Error; err != nil {
@@ -104,6 +392,17 @@ func (s databaseService) FindUserByEmail(email string) (model.User, error) {
 	return user, nil
 }
 
+func (s databaseService) FindUserByID(id int) (model.User, error) {
+	var user model.User
+	if err := s.db.First(&user, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return user, fmt.Errorf("%w: %s", ErrUserNotFound, err)
+		}
+		return user, fmt.Errorf("%w: %s", ErrFindUser, err)
+	}
+	return user, nil
+}
+
 func (s databaseService) CreateUser(user model.User) (model.User, error) {
 	if err := s.db.Create(&user).Error; err != nil {
 		return user, fmt.Errorf("%w: %s", ErrCreateUser, err)
@@ -111,17 +410,130 @@ func (s databaseService) CreateUser(user model.User) (model.User, error) {
 	return user, nil
 }
 
-func (s databaseService) DeleteStudent(id string) error {
+func (s databaseService) UpdateUserPassword(id int, password string) error {
+	result := s.db.Model(&model.User{}).Where("id = ?", id).Update("password", password)
+	if result.Error != nil {
+		return fmt.Errorf("%w: %s", ErrUpdateUser, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// VerifyUserEmail marks the email address of a user as verified. Users that
+// are already verified keep their original verification time.
+func (s databaseService) VerifyUserEmail(id int) error {
+	err := s.db.Model(&model.User{}).
+		Where("id = ? AND verified_at IS NULL", id).
+		Update("verified_at", time.Now()).Error
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrUpdateUser, err)
+	}
+	return nil
+}
+
+func (s databaseService) UpdateUserRole(id int, role string) error {
+	if err := s.db.Model(&model.User{}).Where("id = ?", id).Update("role", role).Error; err != nil {
+		return fmt.Errorf("%w: %s", ErrUpdateUser, err)
+	}
+	return nil
+}
+
+// DeleteStudent moves the student with id to the trash, recording who
+// deleted it. Deleted students are hidden until they are restored or purged.
+func (s databaseService) DeleteStudent(id int, version int, deletedBy *int) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		student, err := trashStudent(tx, id, true, version, map[string]interface{}{"deleted_at": time.Now(), "deleted_by": deletedBy})
+		if err != nil {
+			return err
+		}
+		return recordVersion(tx, model.StudentDeleted, &student, student, deletedBy)
+	})
+}
+
+// ListDeletedStudents returns a page of the trash, most recently deleted
+// first, and how many students it holds.
+func (s databaseService) ListDeletedStudents(query model.DeletedStudentQuery) ([]model.Student, int64, error) {
+	tx := s.db.Model(&model.Student{}).Where("deleted_at IS NOT NULL")
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("%w: %s", ErrListStudents, err)
+	}
+
+	var students []model.Student
+	if err := tx.Order("deleted_at DESC, id DESC").Limit(query.Limit).Offset(query.Offset).Find(&students).Error; err != nil {
+		return nil, 0, fmt.Errorf("%w: %s", ErrListStudents, err)
+	}
+	return students, total, nil
+}
+
+// RestoreStudent takes the student with id out of the trash. Restoring a
+// student that is not in the trash fails with ErrStudentNotFound, and one
+// whose email an active student has taken since with ErrDuplicateEmail.
+func (s databaseService) RestoreStudent(id int, restoredBy *int) (model.Student, error) {
 	var student model.Student
-	if err := s.db.First(&student, id).Error; err != nil {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		student, err = trashStudent(tx, id, false, 0, map[string]interface{}{"deleted_at": nil, "deleted_by": nil})
+		if err != nil {
+			return err
+		}
+		student.DeletedAt, student.DeletedBy = nil, nil
+		return recordVersion(tx, model.StudentRestored, &student, student, restoredBy)
+	})
+	if err != nil {
+		return model.Student{}, err
+	}
+	return student, nil
+}
+
+// trashStudent moves the student with id into the trash, or out of it when
+// deleted is false, by applying updates. It returns the student as it was
+// but with its new version, and fails with ErrStudentNotFound when the
+// student is missing or already where it is being moved to. A version other
+// than 0 must be the current one, as in UpdateStudent.
+func trashStudent(tx *gorm.DB, id int, deleted bool, version int, updates map[string]interface{}) (model.Student, error) {
+	from, fail := "deleted_at IS NULL", ErrDeleteStudent
+	if !deleted {
+		from, fail = "deleted_at IS NOT NULL", ErrRestoreStudent
+	}
+
+	var student model.Student
+	if err := tx.Where(from).First(&student, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return fmt.Errorf("%w: %s", ErrStudentNotFound, err)
+			return student, fmt.Errorf("%w: %s", ErrStudentNotFound, err)
 		}
-		return fmt.Errorf("%w: %s", ErrFindStudent, err)
+		return student, fmt.Errorf("%w: %s", ErrFindStudent, err)
+	}
+	if version != 0 && student.Version != version {
+		return student, ErrStudentModified
 	}
 
-	if err := s.db.Delete(&student).Error; err != nil {
-		return fmt.Errorf("%w: %s", ErrDeleteStudent, err)
+	current := student.Version
+	updates["version"] = current + 1
+	result := tx.Model(&model.Student{}).Where("id = ? AND "+from+" AND version = ?", student.ID, current).Updates(updates)
+	if result.Error != nil {
+		return student, studentWriteError(fail, result.Error)
 	}
-	return nil
+	if result.RowsAffected == 0 {
+		return student, ErrStudentModified
+	}
+	student.Version = current + 1
+	return student, nil
+}
+
+// PurgeDeletedStudents permanently deletes the students deleted before
+// before, along with their history.
+func (s databaseService) PurgeDeletedStudents(before time.Time) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		purged := tx.Model(&model.Student{}).Select("id").Where("deleted_at < ?", before)
+		if err := tx.Where("student_id IN (?)", purged).Delete(&model.StudentVersion{}).Error; err != nil {
+			return fmt.Errorf("%w: %s", ErrDeleteStudent, err)
+		}
+		if err := tx.Where("deleted_at < ?", before).Delete(&model.Student{}).Error; err != nil {
+			return fmt.Errorf("%w: %s", ErrDeleteStudent, err)
+		}
+		return nil
+	})
 }
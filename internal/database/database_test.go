@@ -4,14 +4,20 @@ import (
 	"errors"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/darolpz/students/internal/model"
+	mysqldriver "github.com/go-sql-driver/mysql"
 	"github.com/stretchr/testify/require"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 )
 
+// errDuplicateEmail is what MySQL answers a write of an email an active
+// student has.
+var errDuplicateEmail = &mysqldriver.MySQLError{Number: 1062, Message: "Duplicate entry 'john.doe@gmail.com' for key 'students_active_email'"}
+
 func Test_databaseService_FindStudent(t *testing.T) {
 	mockDB, mock, err := sqlmock.New()
 	require.NoError(t, err)
@@ -42,7 +48,7 @@ func Test_databaseService_FindStudent(t *testing.T) {
 			student_id: "1",
 			setMock: func(mock sqlmock.Sqlmock) {
 				mock.ExpectQuery(
-					regexp.QuoteMeta("SELECT * FROM `students` WHERE `students`.`id` = ? ORDER BY `students`.`id` LIMIT 1")).
+					regexp.QuoteMeta("SELECT * FROM `students` WHERE deleted_at IS NULL AND `students`.`id` = ? ORDER BY `students`.`id` LIMIT 1")).
 					WithArgs("1").
 					WillReturnRows(sqlmock.NewRows([]string{"id", "first_name", "last_name", "email", "age"}).
 						AddRow("1", "John", "Doe", "john.doe@gmail.com", "33"))
@@ -60,7 +66,7 @@ func Test_databaseService_FindStudent(t *testing.T) {
 			student_id: "5",
 			setMock: func(mock sqlmock.Sqlmock) {
 				mock.ExpectQuery(
-					regexp.QuoteMeta("SELECT * FROM `students` WHERE `students`.`id` = ? ORDER BY `students`.`id` LIMIT 1")).
+					regexp.QuoteMeta("SELECT * FROM `students` WHERE deleted_at IS NULL AND `students`.`id` = ? ORDER BY `students`.`id` LIMIT 1")).
 					WithArgs("5").
 					WillReturnError(gorm.ErrRecordNotFound)
 			},
@@ -71,7 +77,7 @@ func Test_databaseService_FindStudent(t *testing.T) {
 			student_id: "5",
 			setMock: func(mock sqlmock.Sqlmock) {
 				mock.ExpectQuery(
-					regexp.QuoteMeta("SELECT * FROM `students` WHERE `students`.`id` = ? ORDER BY `students`.`id` LIMIT 1")).
+					regexp.QuoteMeta("SELECT * FROM `students` WHERE deleted_at IS NULL AND `students`.`id` = ? ORDER BY `students`.`id` LIMIT 1")).
 					WithArgs("x").
 					WillReturnError(errors.New("error"))
 			},
@@ -111,21 +117,25 @@ func Test_databaseService_ListStudents(t *testing.T) {
 		db: db,
 	}
 
+	minAge, maxAge := 18, 30
+
 	tests := []struct {
 		name          string
-		limit         int
-		offset        int
+		query         model.StudentQuery
 		setMock       func(mock sqlmock.Sqlmock)
 		want          []model.Student
+		wantTotal     int64
 		expectedError error
 	}{
 		{
-			name:   "should_return_students_list",
-			limit:  10,
-			offset: 0,
+			name:  "should_return_students_list",
+			query: model.StudentQuery{Limit: 10},
 			setMock: func(mock sqlmock.Sqlmock) {
 				mock.ExpectQuery(
-					regexp.QuoteMeta("SELECT * FROM `students` LIMIT 10")).
+					regexp.QuoteMeta("SELECT count(*) FROM `students`")).
+					WillReturnRows(sqlmock.NewRows([]string{"count(*)"}).AddRow(12))
+				mock.ExpectQuery(
+					regexp.QuoteMeta("SELECT * FROM `students` WHERE deleted_at IS NULL ORDER BY `id` LIMIT 10")).
 					WillReturnRows(sqlmock.NewRows([]string{"id", "first_name", "last_name", "email", "age"}).
 						AddRow("1", "John", "Doe", "john.doe@gmail.com", "33").
 						AddRow("2", "Dario", "Lopez", "daropl12@gmail.com", "26"))
@@ -134,14 +144,48 @@ func Test_databaseService_ListStudents(t *testing.T) {
 				{ID: 1, FirstName: "John", LastName: "Doe", Email: "john.doe@gmail.com", Age: 33},
 				{ID: 2, FirstName: "Dario", LastName: "Lopez", Email: "daropl12@gmail.com", Age: 26},
 			},
+			wantTotal: 12,
+		},
+		{
+			name: "should_filter_and_sort_students",
+			query: model.StudentQuery{
+				Offset: 10,
+				Limit:  5,
+				Name:   "Lo_",
+				Email:  "daropl12@gmail.com",
+				MinAge: &minAge,
+				MaxAge: &maxAge,
+				Sort:   "last_name,-age",
+			},
+			setMock: func(mock sqlmock.Sqlmock) {
+				where := "WHERE deleted_at IS NULL AND (first_name LIKE ? OR last_name LIKE ?) AND email = ? AND age >= ? AND age <= ?"
+				mock.ExpectQuery(
+					regexp.QuoteMeta("SELECT count(*) FROM `students` "+where)).
+					WithArgs(`Lo\_%`, `Lo\_%`, "daropl12@gmail.com", 18, 30).
+					WillReturnRows(sqlmock.NewRows([]string{"count(*)"}).AddRow(11))
+				mock.ExpectQuery(
+					regexp.QuoteMeta("SELECT * FROM `students` "+where+" ORDER BY `last_name`,`age` DESC,`id` LIMIT 5 OFFSET 10")).
+					WithArgs(`Lo\_%`, `Lo\_%`, "daropl12@gmail.com", 18, 30).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "first_name", "last_name", "email", "age"}).
+						AddRow("2", "Dario", "Lopez", "daropl12@gmail.com", "26"))
+			},
+			want: []model.Student{
+				{ID: 2, FirstName: "Dario", LastName: "Lopez", Email: "daropl12@gmail.com", Age: 26},
+			},
+			wantTotal: 11,
+		},
+		{
+			name:          "should_return_error_invalid_sort",
+			query:         model.StudentQuery{Limit: 10, Sort: "password"},
+			setMock:       func(mock sqlmock.Sqlmock) {},
+			expectedError: model.ErrInvalidSort,
 		},
 		{
-			name:   "should_return_error_list_students",
-			limit:  25,
-			offset: 9,
+			name:  "should_return_error_list_students",
+			query: model.StudentQuery{Offset: 9, Limit: 25},
 			setMock: func(mock sqlmock.Sqlmock) {
 				mock.ExpectQuery(
-					regexp.QuoteMeta("SELECT * FROM `students` LIMIT 25 OFFSET 9")).
+					regexp.QuoteMeta("SELECT count(*) FROM `students`")).
 					WillReturnError(errors.New("error"))
 			},
 			expectedError: ErrListStudents,
@@ -150,19 +194,22 @@ func Test_databaseService_ListStudents(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.setMock(mock)
-			got, err := s.ListStudents(tt.offset, tt.limit)
+			got, total, err := s.ListStudents(tt.query)
 			if tt.expectedError != nil {
 				require.Error(t, err)
 				require.True(t, errors.Is(err, tt.expectedError))
 			} else {
 				require.NoError(t, err)
 				require.Equal(t, tt.want, got)
+				require.Equal(t, tt.wantTotal, total)
 			}
+			require.NoError(t, mock.ExpectationsWereMet())
 		})
 	}
 }
 
 func Test_databaseService_CreateStudent(t *testing.T) {
+	changedBy := 7
 	mockDB, mock, err := sqlmock.New()
 	require.NoError(t, err)
 	defer mockDB.Close()
@@ -198,7 +245,11 @@ func Test_databaseService_CreateStudent(t *testing.T) {
 			setMock: func(mock sqlmock.Sqlmock) {
 				mock.ExpectBegin()
 				mock.ExpectExec("INSERT INTO `students`").
-					WithArgs("dario", "lopez", 26, "daropl12@gmail.com").
+					WithArgs("dario", "lopez", 26, "daropl12@gmail.com", nil, nil, 1).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("INSERT INTO `student_versions`").
+					WithArgs(1, 1, "create", &changedBy, sqlmock.AnyArg(), "dario", "lopez", 26, "daropl12@gmail.com",
+						`{"age":{"from":null,"to":26},"email":{"from":null,"to":"daropl12@gmail.com"},"first_name":{"from":null,"to":"dario"},"last_name":{"from":null,"to":"lopez"}}`).
 					WillReturnResult(sqlmock.NewResult(1, 1))
 				mock.ExpectCommit()
 			},
@@ -208,6 +259,7 @@ func Test_databaseService_CreateStudent(t *testing.T) {
 				LastName:  "lopez",
 				Age:       26,
 				Email:     "daropl12@gmail.com",
+				Version:   1,
 			},
 		},
 		{
@@ -221,17 +273,34 @@ func Test_databaseService_CreateStudent(t *testing.T) {
 			setMock: func(mock sqlmock.Sqlmock) {
 				mock.ExpectBegin()
 				mock.ExpectExec("INSERT INTO `students`").
-					WithArgs("john", "doe", 33, "john.doe@gmail.com").
+					WithArgs("john", "doe", 33, "john.doe@gmail.com", nil, nil, 1).
 					WillReturnError(errors.New("some error"))
 				mock.ExpectRollback()
 			},
 			expectedError: ErrCreateStudent,
 		},
+		{
+			name: "should_return_error_duplicate_email",
+			student: model.Student{
+				FirstName: "john",
+				LastName:  "doe",
+				Age:       33,
+				Email:     "john.doe@gmail.com",
+			},
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO `students`").
+					WithArgs("john", "doe", 33, "john.doe@gmail.com", nil, nil, 1).
+					WillReturnError(errDuplicateEmail)
+				mock.ExpectRollback()
+			},
+			expectedError: ErrDuplicateEmail,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.setMock(mock)
-			got, err := s.CreateStudent(tt.student)
+			got, err := s.CreateStudent(tt.student, &changedBy)
 			if tt.expectedError != nil {
 				require.Error(t, err)
 				require.True(t, errors.Is(err, tt.expectedError))
@@ -265,6 +334,7 @@ func Test_databaseService_UpdateStudent(t *testing.T) {
 		name          string
 		student_id    string
 		newStudent    model.Student
+		version       int
 		setMock       func(mock sqlmock.Sqlmock)
 		want          model.Student
 		expectedError error
@@ -284,19 +354,50 @@ func Test_databaseService_UpdateStudent(t *testing.T) {
 				LastName:  "Lopez",
 				Age:       26,
 				Email:     "daropl12@gmail.com",
+				Version:   4,
 			},
 			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
 				mock.ExpectQuery(
-					regexp.QuoteMeta("SELECT * FROM `students` WHERE `students`.`id` = ? ORDER BY `students`.`id` LIMIT 1")).
+					regexp.QuoteMeta("SELECT * FROM `students` WHERE deleted_at IS NULL AND `students`.`id` = ? ORDER BY `students`.`id` LIMIT 1")).
 					WithArgs("1").
-					WillReturnRows(sqlmock.NewRows([]string{"id", "first_name", "last_name", "email", "age"}).
-						AddRow("1", "John", "Doe", "john.doe@gmail.com", "33"))
-
-				mock.ExpectBegin()
+					WillReturnRows(sqlmock.NewRows([]string{"id", "first_name", "last_name", "email", "age", "version"}).
+						AddRow("1", "John", "Doe", "john.doe@gmail.com", "33", 3))
 				mock.ExpectExec(
-					regexp.QuoteMeta("UPDATE `students` SET `first_name`=?,`last_name`=?,`age`=?,`email`=? WHERE `id` = ?")).
-					WithArgs("Dario", "Lopez", 26, "daropl12@gmail.com", 1).
+					regexp.QuoteMeta("UPDATE `students` SET `first_name`=?,`last_name`=?,`age`=?,`email`=?,`deleted_at`=?,`deleted_by`=?,`version`=? WHERE version = ? AND `id` = ?")).
+					WithArgs("Dario", "Lopez", 26, "daropl12@gmail.com", nil, nil, 4, 3, 1).
 					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("INSERT INTO `student_versions`").
+					WithArgs(1, 4, "update", nil, sqlmock.AnyArg(), "Dario", "Lopez", 26, "daropl12@gmail.com",
+						`{"age":{"from":33,"to":26},"email":{"from":"john.doe@gmail.com","to":"daropl12@gmail.com"},"first_name":{"from":"John","to":"Dario"},"last_name":{"from":"Doe","to":"Lopez"}}`).
+					WillReturnResult(sqlmock.NewResult(2, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name:       "should_keep_version_nothing_changed",
+			student_id: "1",
+			newStudent: model.Student{
+				FirstName: "John",
+				LastName:  "Doe",
+				Age:       33,
+				Email:     "john.doe@gmail.com",
+			},
+			want: model.Student{
+				ID:        1,
+				FirstName: "John",
+				LastName:  "Doe",
+				Age:       33,
+				Email:     "john.doe@gmail.com",
+				Version:   3,
+			},
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(
+					regexp.QuoteMeta("SELECT * FROM `students` WHERE deleted_at IS NULL AND `students`.`id` = ? ORDER BY `students`.`id` LIMIT 1")).
+					WithArgs("1").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "first_name", "last_name", "email", "age", "version"}).
+						AddRow("1", "John", "Doe", "john.doe@gmail.com", "33", 3))
 				mock.ExpectCommit()
 			},
 		},
@@ -310,10 +411,12 @@ func Test_databaseService_UpdateStudent(t *testing.T) {
 				Email:     "daropl12@gmail.com",
 			},
 			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
 				mock.ExpectQuery(
-					regexp.QuoteMeta("SELECT * FROM `students` WHERE `students`.`id` = ? ORDER BY `students`.`id` LIMIT 1")).
+					regexp.QuoteMeta("SELECT * FROM `students` WHERE deleted_at IS NULL AND `students`.`id` = ? ORDER BY `students`.`id` LIMIT 1")).
 					WithArgs("99").
 					WillReturnError(gorm.ErrRecordNotFound)
+				mock.ExpectRollback()
 			},
 			expectedError: ErrStudentNotFound,
 		},
@@ -327,10 +430,12 @@ func Test_databaseService_UpdateStudent(t *testing.T) {
 				Email:     "daropl12@gmail.com",
 			},
 			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
 				mock.ExpectQuery(
-					regexp.QuoteMeta("SELECT * FROM `students` WHERE `students`.`id` = ? ORDER BY `students`.`id` LIMIT 1")).
+					regexp.QuoteMeta("SELECT * FROM `students` WHERE deleted_at IS NULL AND `students`.`id` = ? ORDER BY `students`.`id` LIMIT 1")).
 					WithArgs("00").
 					WillReturnError(errors.New("error"))
+				mock.ExpectRollback()
 			},
 			expectedError: ErrFindStudent,
 		},
@@ -344,26 +449,95 @@ func Test_databaseService_UpdateStudent(t *testing.T) {
 				Email:     "daropl12@gmail.com",
 			},
 			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
 				mock.ExpectQuery(
-					regexp.QuoteMeta("SELECT * FROM `students` WHERE `students`.`id` = ? ORDER BY `students`.`id` LIMIT 1")).
+					regexp.QuoteMeta("SELECT * FROM `students` WHERE deleted_at IS NULL AND `students`.`id` = ? ORDER BY `students`.`id` LIMIT 1")).
 					WithArgs("2").
-					WillReturnRows(sqlmock.NewRows([]string{"id", "first_name", "last_name", "email", "age"}).
-						AddRow("1", "John", "Doe", "john.doe@gmail.com", "33"))
-
-				mock.ExpectBegin()
+					WillReturnRows(sqlmock.NewRows([]string{"id", "first_name", "last_name", "email", "age", "version"}).
+						AddRow("1", "John", "Doe", "john.doe@gmail.com", "33", 3))
 				mock.ExpectExec(
-					regexp.QuoteMeta("UPDATE `students` SET `first_name`=?,`last_name`=?,`age`=?,`email`=? WHERE `id` = ?")).
-					WithArgs("Dario", "Lopez", 26, "daropl12@gmail.com", 1).
+					regexp.QuoteMeta("UPDATE `students` SET `first_name`=?,`last_name`=?,`age`=?,`email`=?,`deleted_at`=?,`deleted_by`=?,`version`=? WHERE version = ? AND `id` = ?")).
+					WithArgs("Dario", "Lopez", 26, "daropl12@gmail.com", nil, nil, 4, 3, 1).
 					WillReturnError(errors.New("error"))
 				mock.ExpectRollback()
 			},
 			expectedError: ErrUpdateStudent,
 		},
+		{
+			name:       "should_return_error_duplicate_email",
+			student_id: "1",
+			newStudent: model.Student{
+				FirstName: "Dario",
+				LastName:  "Lopez",
+				Age:       26,
+				Email:     "jane.doe@gmail.com",
+			},
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(
+					regexp.QuoteMeta("SELECT * FROM `students` WHERE deleted_at IS NULL AND `students`.`id` = ? ORDER BY `students`.`id` LIMIT 1")).
+					WithArgs("1").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "first_name", "last_name", "email", "age", "version"}).
+						AddRow("1", "John", "Doe", "john.doe@gmail.com", "33", 3))
+				mock.ExpectExec(
+					regexp.QuoteMeta("UPDATE `students` SET `first_name`=?,`last_name`=?,`age`=?,`email`=?,`deleted_at`=?,`deleted_by`=?,`version`=? WHERE version = ? AND `id` = ?")).
+					WithArgs("Dario", "Lopez", 26, "jane.doe@gmail.com", nil, nil, 4, 3, 1).
+					WillReturnError(errDuplicateEmail)
+				mock.ExpectRollback()
+			},
+			expectedError: ErrDuplicateEmail,
+		},
+		{
+			name:       "should_return_error_student_modified",
+			student_id: "1",
+			newStudent: model.Student{
+				FirstName: "Dario",
+				LastName:  "Lopez",
+				Age:       26,
+				Email:     "daropl12@gmail.com",
+			},
+			version: 2,
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(
+					regexp.QuoteMeta("SELECT * FROM `students` WHERE deleted_at IS NULL AND `students`.`id` = ? ORDER BY `students`.`id` LIMIT 1")).
+					WithArgs("1").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "first_name", "last_name", "email", "age", "version"}).
+						AddRow("1", "John", "Doe", "john.doe@gmail.com", "33", 3))
+				mock.ExpectRollback()
+			},
+			expectedError: ErrStudentModified,
+		},
+		{
+			name:       "should_return_error_student_modified_concurrently",
+			student_id: "1",
+			newStudent: model.Student{
+				FirstName: "Dario",
+				LastName:  "Lopez",
+				Age:       26,
+				Email:     "daropl12@gmail.com",
+			},
+			version: 3,
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(
+					regexp.QuoteMeta("SELECT * FROM `students` WHERE deleted_at IS NULL AND `students`.`id` = ? ORDER BY `students`.`id` LIMIT 1")).
+					WithArgs("1").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "first_name", "last_name", "email", "age", "version"}).
+						AddRow("1", "John", "Doe", "john.doe@gmail.com", "33", 3))
+				mock.ExpectExec(
+					regexp.QuoteMeta("UPDATE `students` SET `first_name`=?,`last_name`=?,`age`=?,`email`=?,`deleted_at`=?,`deleted_by`=?,`version`=? WHERE version = ? AND `id` = ?")).
+					WithArgs("Dario", "Lopez", 26, "daropl12@gmail.com", nil, nil, 4, 3, 1).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+			},
+			expectedError: ErrStudentModified,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.setMock(mock)
-			got, err := s.UpdateStudent(tt.student_id, tt.newStudent)
+			got, err := s.UpdateStudent(tt.student_id, tt.newStudent, tt.version, nil)
 			if tt.expectedError != nil {
 				require.Error(t, err)
 				require.True(t, errors.Is(err, tt.expectedError))
@@ -491,7 +665,7 @@ func Test_databaseService_CreateUser(t *testing.T) {
 			setMock: func(mock sqlmock.Sqlmock) {
 				mock.ExpectBegin()
 				mock.ExpectExec("INSERT INTO `users`").
-					WithArgs("John", "john.doe@gmail.com", "123456", "admin").
+					WithArgs("John", "john.doe@gmail.com", "123456", "admin", nil, nil).
 					WillReturnResult(sqlmock.NewResult(1, 1))
 				mock.ExpectCommit()
 			},
@@ -514,7 +688,7 @@ func Test_databaseService_CreateUser(t *testing.T) {
 			setMock: func(mock sqlmock.Sqlmock) {
 				mock.ExpectBegin()
 				mock.ExpectExec("INSERT INTO `users`").
-					WithArgs("John", "john.doe@gmail.com", "123456", "admin").
+					WithArgs("John", "john.doe@gmail.com", "123456", "admin", nil, nil).
 					WillReturnError(errors.New("somer error"))
 				mock.ExpectRollback()
 			},
@@ -554,72 +728,157 @@ func Test_databaseService_DeleteStudent(t *testing.T) {
 		db: db,
 	}
 
+	deletedBy := 7
 	tests := []struct {
 		name          string
-		student_id    string
+		student_id    int
+		version       int
 		setMock       func(mock sqlmock.Sqlmock)
 		expectedError error
 	}{
 		{
 			name:       "should_delete_student",
-			student_id: "1",
+			student_id: 1,
 			setMock: func(mock sqlmock.Sqlmock) {
-				mock.ExpectQuery(
-					regexp.QuoteMeta("SELECT * FROM `students` WHERE `students`.`id` = ? ORDER BY `students`.`id` LIMIT 1")).
-					WithArgs("1").
-					WillReturnRows(sqlmock.NewRows([]string{"id", "first_name", "last_name", "email", "age"}).
-						AddRow("1", "John", "Doe", "john.doe@gmail.com", "33"))
 				mock.ExpectBegin()
-				mock.ExpectExec("DELETE FROM `students`").
+				mock.ExpectQuery(
+					regexp.QuoteMeta("SELECT * FROM `students` WHERE deleted_at IS NULL AND `students`.`id` = ? ORDER BY `students`.`id` LIMIT 1")).
 					WithArgs(1).
-					WillReturnResult(sqlmock.NewResult(1, 1))
+					WillReturnRows(sqlmock.NewRows([]string{"id", "first_name", "last_name", "email", "age", "version"}).
+						AddRow("1", "John", "Doe", "john.doe@gmail.com", "33", 3))
+				mock.ExpectExec(
+					regexp.QuoteMeta("UPDATE `students` SET `deleted_at`=?,`deleted_by`=?,`version`=? WHERE id = ? AND deleted_at IS NULL AND version = ?")).
+					WithArgs(sqlmock.AnyArg(), &deletedBy, 4, 1, 3).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("INSERT INTO `student_versions`").
+					WithArgs(1, 4, "delete", &deletedBy, sqlmock.AnyArg(), "John", "Doe", 33, "john.doe@gmail.com", "{}").
+					WillReturnResult(sqlmock.NewResult(4, 1))
 				mock.ExpectCommit()
 			},
 		},
 		{
 			name:       "should_return_error_student_not_found",
-			student_id: "1",
+			student_id: 1,
 			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
 				mock.ExpectQuery(
-					regexp.QuoteMeta("SELECT * FROM `students` WHERE `students`.`id` = ? ORDER BY `students`.`id` LIMIT 1")).
-					WithArgs("1").
-					WillReturnError(gorm.ErrRecordNotFound)
+					regexp.QuoteMeta("SELECT * FROM `students` WHERE deleted_at IS NULL AND `students`.`id` = ? ORDER BY `students`.`id` LIMIT 1")).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "first_name", "last_name", "email", "age"}))
+				mock.ExpectRollback()
 			},
 			expectedError: ErrStudentNotFound,
 		},
 		{
-			name:       "should_return_error_find_student",
-			student_id: "1",
+			name:       "should_return_error_delete_student",
+			student_id: 1,
 			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
 				mock.ExpectQuery(
-					regexp.QuoteMeta("SELECT * FROM `students` WHERE `students`.`id` = ? ORDER BY `students`.`id` LIMIT 1")).
-					WithArgs("1").
+					regexp.QuoteMeta("SELECT * FROM `students` WHERE deleted_at IS NULL AND `students`.`id` = ? ORDER BY `students`.`id` LIMIT 1")).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "first_name", "last_name", "email", "age", "version"}).
+						AddRow("1", "John", "Doe", "john.doe@gmail.com", "33", 3))
+				mock.ExpectExec(
+					regexp.QuoteMeta("UPDATE `students` SET `deleted_at`=?,`deleted_by`=?,`version`=? WHERE id = ? AND deleted_at IS NULL AND version = ?")).
 					WillReturnError(errors.New("somer error"))
+				mock.ExpectRollback()
 			},
-			expectedError: ErrFindStudent,
+			expectedError: ErrDeleteStudent,
 		},
 		{
-			name:       "should_return_error_delete_student",
-			student_id: "1",
+			name:       "should_return_error_student_modified",
+			student_id: 1,
+			version:    2,
 			setMock: func(mock sqlmock.Sqlmock) {
-				mock.ExpectQuery(
-					regexp.QuoteMeta("SELECT * FROM `students` WHERE `students`.`id` = ? ORDER BY `students`.`id` LIMIT 1")).
-					WithArgs("1").
-					WillReturnRows(sqlmock.NewRows([]string{"id", "first_name", "last_name", "email", "age"}).
-						AddRow("1", "John", "Doe", "john.doe@gmail.com", "33"))
 				mock.ExpectBegin()
-				mock.ExpectExec("DELETE FROM `students`").
+				mock.ExpectQuery(
+					regexp.QuoteMeta("SELECT * FROM `students` WHERE deleted_at IS NULL AND `students`.`id` = ? ORDER BY `students`.`id` LIMIT 1")).
 					WithArgs(1).
-					WillReturnError(errors.New("somer error"))
+					WillReturnRows(sqlmock.NewRows([]string{"id", "first_name", "last_name", "email", "age", "version"}).
+						AddRow("1", "John", "Doe", "john.doe@gmail.com", "33", 3))
 				mock.ExpectRollback()
 			},
-			expectedError: ErrDeleteStudent,
+			expectedError: ErrStudentModified,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setMock(mock)
+			err := s.DeleteStudent(tt.student_id, tt.version, &deletedBy)
+			if tt.expectedError != nil {
+				require.Error(t, err)
+				require.True(t, errors.Is(err, tt.expectedError))
+			} else {
+				require.NoError(t, err)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_databaseService_UpdateUserPassword(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	dialector := mysql.New(mysql.Config{
+		Conn:                      mockDB,
+		DriverName:                "mysql",
+		DSN:                       "sqlmock_db",
+		SkipInitializeWithVersion: true,
+	})
+
+	db, err := gorm.Open(dialector)
+	require.NoError(t, err)
+	s := databaseService{
+		db: db,
+	}
+
+	query := regexp.QuoteMeta("UPDATE `users` SET `password`=? WHERE id = ?")
+
+	tests := []struct {
+		name          string
+		setMock       func(mock sqlmock.Sqlmock)
+		expectedError error
+	}{
+		{
+			name: "should_update_user_password",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(query).
+					WithArgs("hash", 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "should_return_error_user_not_found",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(query).
+					WithArgs("hash", 1).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			},
+			expectedError: ErrUserNotFound,
+		},
+		{
+			name: "should_return_error_update_user",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(query).
+					WithArgs("hash", 1).
+					WillReturnError(errors.New("some error"))
+				mock.ExpectRollback()
+			},
+			expectedError: ErrUpdateUser,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.setMock(mock)
-			err := s.DeleteStudent(tt.student_id)
+			err := s.UpdateUserPassword(1, "hash")
 			if tt.expectedError != nil {
 				require.Error(t, err)
 				require.True(t, errors.Is(err, tt.expectedError))
@@ -629,3 +888,530 @@ func Test_databaseService_DeleteStudent(t *testing.T) {
 		})
 	}
 }
+
+func Test_databaseService_SeekStudents(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	dialector := mysql.New(mysql.Config{
+		Conn:                      mockDB,
+		DriverName:                "mysql",
+		DSN:                       "sqlmock_db",
+		SkipInitializeWithVersion: true,
+	})
+
+	db, err := gorm.Open(dialector)
+	require.NoError(t, err)
+	s := databaseService{
+		db: db,
+	}
+
+	sort := []model.SortField{{Column: "last_name"}, {Column: "age", Desc: true}, {Column: "id"}}
+	cursor := model.Student{ID: 2, FirstName: "Dario", LastName: "Lopez", Age: 26}.Cursor(sort).Encode()
+	rows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "first_name", "last_name", "email", "age"}).
+			AddRow("3", "Ana", "Perez", "ana@gmail.com", "20").
+			AddRow("1", "John", "Smith", "john.doe@gmail.com", "33")
+	}
+
+	tests := []struct {
+		name          string
+		query         model.StudentQuery
+		setMock       func(mock sqlmock.Sqlmock)
+		want          []model.Student
+		expectedError error
+	}{
+		{
+			name:  "should_return_first_page",
+			query: model.StudentQuery{Limit: 3, Sort: "last_name,-age"},
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(
+					regexp.QuoteMeta("SELECT * FROM `students` WHERE deleted_at IS NULL ORDER BY `last_name`,`age` DESC,`id` LIMIT 3")).
+					WillReturnRows(rows())
+			},
+			want: []model.Student{
+				{ID: 3, FirstName: "Ana", LastName: "Perez", Email: "ana@gmail.com", Age: 20},
+				{ID: 1, FirstName: "John", LastName: "Smith", Email: "john.doe@gmail.com", Age: 33},
+			},
+		},
+		{
+			name:  "should_return_page_after_cursor",
+			query: model.StudentQuery{Limit: 3, Sort: "last_name,-age", After: cursor},
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(
+					regexp.QuoteMeta("SELECT * FROM `students` WHERE deleted_at IS NULL AND ((`last_name` > ?) OR (`last_name` = ? AND `age` < ?) OR "+
+						"(`last_name` = ? AND `age` = ? AND `id` > ?)) ORDER BY `last_name`,`age` DESC,`id` LIMIT 3")).
+					WithArgs("Lopez", "Lopez", 26, "Lopez", 26, 2).
+					WillReturnRows(rows())
+			},
+			want: []model.Student{
+				{ID: 3, FirstName: "Ana", LastName: "Perez", Email: "ana@gmail.com", Age: 20},
+				{ID: 1, FirstName: "John", LastName: "Smith", Email: "john.doe@gmail.com", Age: 33},
+			},
+		},
+		{
+			name:  "should_return_page_before_cursor",
+			query: model.StudentQuery{Limit: 3, Sort: "last_name,-age", Before: cursor, MinAge: new(int)},
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(
+					regexp.QuoteMeta("SELECT * FROM `students` WHERE deleted_at IS NULL AND age >= ? AND ((`last_name` < ?) OR (`last_name` = ? AND `age` > ?) OR "+
+						"(`last_name` = ? AND `age` = ? AND `id` < ?)) ORDER BY `last_name` DESC,`age`,`id` DESC LIMIT 3")).
+					WithArgs(0, "Lopez", "Lopez", 26, "Lopez", 26, 2).
+					WillReturnRows(rows())
+			},
+			want: []model.Student{
+				{ID: 1, FirstName: "John", LastName: "Smith", Email: "john.doe@gmail.com", Age: 33},
+				{ID: 3, FirstName: "Ana", LastName: "Perez", Email: "ana@gmail.com", Age: 20},
+			},
+		},
+		{
+			name:          "should_return_error_cursor_of_other_sort",
+			query:         model.StudentQuery{Limit: 3, Sort: "age", After: cursor},
+			setMock:       func(mock sqlmock.Sqlmock) {},
+			expectedError: model.ErrInvalidCursor,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setMock(mock)
+			got, err := s.SeekStudents(tt.query)
+			if tt.expectedError != nil {
+				require.Error(t, err)
+				require.True(t, errors.Is(err, tt.expectedError))
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.want, got)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_databaseService_SearchStudents(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	dialector := mysql.New(mysql.Config{
+		Conn:                      mockDB,
+		DriverName:                "mysql",
+		DSN:                       "sqlmock_db",
+		SkipInitializeWithVersion: true,
+	})
+
+	db, err := gorm.Open(dialector)
+	require.NoError(t, err)
+	s := databaseService{
+		db: db,
+	}
+
+	fullTextQuery := regexp.QuoteMeta("SELECT * FROM `students` WHERE deleted_at IS NULL AND MATCH(first_name, last_name, email) AGAINST (? IN BOOLEAN MODE) " +
+		"ORDER BY MATCH(first_name, last_name, email) AGAINST (? IN BOOLEAN MODE) DESC LIMIT 50")
+	soundsLikeQuery := regexp.QuoteMeta("SELECT * FROM `students` WHERE deleted_at IS NULL AND " +
+		"(first_name SOUNDS LIKE ? OR last_name SOUNDS LIKE ? OR first_name SOUNDS LIKE ? OR last_name SOUNDS LIKE ?) LIMIT 50")
+	columns := []string{"id", "first_name", "last_name", "email", "age"}
+
+	tests := []struct {
+		name          string
+		terms         []string
+		setMock       func(mock sqlmock.Sqlmock)
+		want          []model.Student
+		expectedError error
+	}{
+		{
+			name:  "should_return_full_text_candidates",
+			terms: []string{"john", "smith"},
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(fullTextQuery).
+					WithArgs("john* smith*", "john* smith*").
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow("1", "John", "Smith", "john.doe@gmail.com", "33"))
+				mock.ExpectQuery(soundsLikeQuery).
+					WithArgs("john", "john", "smith", "smith").
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow("1", "John", "Smith", "john.doe@gmail.com", "33"))
+			},
+			want: []model.Student{
+				{ID: 1, FirstName: "John", LastName: "Smith", Email: "john.doe@gmail.com", Age: 33},
+			},
+		},
+		{
+			name:  "should_add_names_sounding_alike_to_full_text_candidates",
+			terms: []string{"john", "smiht"},
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(fullTextQuery).
+					WithArgs("john* smiht*", "john* smiht*").
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow("2", "John", "Doe", "jdoe@gmail.com", "20").
+						AddRow("1", "John", "Smith", "john.doe@gmail.com", "33"))
+				mock.ExpectQuery(soundsLikeQuery).
+					WithArgs("john", "john", "smiht", "smiht").
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow("1", "John", "Smith", "john.doe@gmail.com", "33").
+						AddRow("3", "Jon", "Smith", "jon@gmail.com", "40"))
+			},
+			want: []model.Student{
+				{ID: 2, FirstName: "John", LastName: "Doe", Email: "jdoe@gmail.com", Age: 20},
+				{ID: 1, FirstName: "John", LastName: "Smith", Email: "john.doe@gmail.com", Age: 33},
+				{ID: 3, FirstName: "Jon", LastName: "Smith", Email: "jon@gmail.com", Age: 40},
+			},
+		},
+		{
+			name:  "should_return_names_sounding_alike",
+			terms: []string{"jhon", "smiht"},
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(fullTextQuery).
+					WithArgs("jhon* smiht*", "jhon* smiht*").
+					WillReturnRows(sqlmock.NewRows(columns))
+				mock.ExpectQuery(soundsLikeQuery).
+					WithArgs("jhon", "jhon", "smiht", "smiht").
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow("1", "John", "Smith", "john.doe@gmail.com", "33"))
+			},
+			want: []model.Student{
+				{ID: 1, FirstName: "John", LastName: "Smith", Email: "john.doe@gmail.com", Age: 33},
+			},
+		},
+		{
+			name:  "should_return_error",
+			terms: []string{"jhon", "smith"},
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(fullTextQuery).WillReturnError(errors.New("error"))
+			},
+			expectedError: ErrSearchStudents,
+		},
+		{
+			name:  "should_return_error_sounds_like",
+			terms: []string{"jhon"},
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(fullTextQuery).WillReturnRows(sqlmock.NewRows(columns))
+				mock.ExpectQuery(regexp.QuoteMeta("first_name SOUNDS LIKE ?")).WillReturnError(errors.New("error"))
+			},
+			expectedError: ErrSearchStudents,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setMock(mock)
+			got, err := s.SearchStudents(tt.terms, 50)
+			if tt.expectedError != nil {
+				require.Error(t, err)
+				require.True(t, errors.Is(err, tt.expectedError))
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.want, got)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_databaseService_EachStudent(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	dialector := mysql.New(mysql.Config{
+		Conn:                      mockDB,
+		DriverName:                "mysql",
+		DSN:                       "sqlmock_db",
+		SkipInitializeWithVersion: true,
+	})
+
+	db, err := gorm.Open(dialector)
+	require.NoError(t, err)
+	s := databaseService{
+		db: db,
+	}
+
+	errStop := errors.New("stop")
+	rows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "first_name", "last_name", "email", "age"}).
+			AddRow("3", "Ana", "Perez", "ana@gmail.com", "20").
+			AddRow("1", "John", "Smith", "john.doe@gmail.com", "33")
+	}
+
+	tests := []struct {
+		name          string
+		query         model.StudentQuery
+		fn            func(student model.Student) error
+		setMock       func(mock sqlmock.Sqlmock)
+		want          []model.Student
+		expectedError error
+	}{
+		{
+			name:  "should_visit_every_student",
+			query: model.StudentQuery{Name: "a", Sort: "-age", Limit: 10, Offset: 10},
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(
+					regexp.QuoteMeta("SELECT * FROM `students` WHERE deleted_at IS NULL AND (first_name LIKE ? OR last_name LIKE ?) ORDER BY `age` DESC,`id`")).
+					WithArgs("a%", "a%").
+					WillReturnRows(rows())
+			},
+			want: []model.Student{
+				{ID: 3, FirstName: "Ana", LastName: "Perez", Email: "ana@gmail.com", Age: 20},
+				{ID: 1, FirstName: "John", LastName: "Smith", Email: "john.doe@gmail.com", Age: 33},
+			},
+		},
+		{
+			name: "should_stop_at_error",
+			fn:   func(student model.Student) error { return errStop },
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(
+					regexp.QuoteMeta("SELECT * FROM `students` WHERE deleted_at IS NULL ORDER BY `id`")).
+					WillReturnRows(rows())
+			},
+			expectedError: errStop,
+		},
+		{
+			name: "should_return_error",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(
+					regexp.QuoteMeta("SELECT * FROM `students` WHERE deleted_at IS NULL ORDER BY `id`")).
+					WillReturnError(errors.New("error"))
+			},
+			expectedError: ErrListStudents,
+		},
+		{
+			name:          "should_return_error_invalid_sort",
+			query:         model.StudentQuery{Sort: "password"},
+			setMock:       func(mock sqlmock.Sqlmock) {},
+			expectedError: model.ErrInvalidSort,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setMock(mock)
+			var got []model.Student
+			fn := tt.fn
+			if fn == nil {
+				fn = func(student model.Student) error {
+					got = append(got, student)
+					return nil
+				}
+			}
+			err := s.EachStudent(tt.query, fn)
+			if tt.expectedError != nil {
+				require.Error(t, err)
+				require.True(t, errors.Is(err, tt.expectedError))
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.want, got)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_databaseService_ListDeletedStudents(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	dialector := mysql.New(mysql.Config{
+		Conn:                      mockDB,
+		DriverName:                "mysql",
+		DSN:                       "sqlmock_db",
+		SkipInitializeWithVersion: true,
+	})
+
+	db, err := gorm.Open(dialector)
+	require.NoError(t, err)
+	s := databaseService{
+		db: db,
+	}
+
+	deletedAt := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+	deletedBy := 7
+
+	tests := []struct {
+		name          string
+		query         model.DeletedStudentQuery
+		setMock       func(mock sqlmock.Sqlmock)
+		want          []model.Student
+		wantTotal     int64
+		expectedError error
+	}{
+		{
+			name:  "should_return_deleted_students",
+			query: model.DeletedStudentQuery{Offset: 10, Limit: 5},
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(
+					regexp.QuoteMeta("SELECT count(*) FROM `students` WHERE deleted_at IS NOT NULL")).
+					WillReturnRows(sqlmock.NewRows([]string{"count(*)"}).AddRow(11))
+				mock.ExpectQuery(
+					regexp.QuoteMeta("SELECT * FROM `students` WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, id DESC LIMIT 5 OFFSET 10")).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "first_name", "last_name", "email", "age", "deleted_at", "deleted_by"}).
+						AddRow("1", "John", "Doe", "john.doe@gmail.com", "33", deletedAt, deletedBy))
+			},
+			want: []model.Student{
+				{ID: 1, FirstName: "John", LastName: "Doe", Email: "john.doe@gmail.com", Age: 33, DeletedAt: &deletedAt, DeletedBy: &deletedBy},
+			},
+			wantTotal: 11,
+		},
+		{
+			name:  "should_return_error",
+			query: model.DeletedStudentQuery{Limit: 5},
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(
+					regexp.QuoteMeta("SELECT count(*) FROM `students` WHERE deleted_at IS NOT NULL")).
+					WillReturnError(errors.New("error"))
+			},
+			expectedError: ErrListStudents,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setMock(mock)
+			got, total, err := s.ListDeletedStudents(tt.query)
+			if tt.expectedError != nil {
+				require.Error(t, err)
+				require.True(t, errors.Is(err, tt.expectedError))
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.want, got)
+				require.Equal(t, tt.wantTotal, total)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_databaseService_RestoreStudent(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	dialector := mysql.New(mysql.Config{
+		Conn:                      mockDB,
+		DriverName:                "mysql",
+		DSN:                       "sqlmock_db",
+		SkipInitializeWithVersion: true,
+	})
+
+	db, err := gorm.Open(dialector)
+	require.NoError(t, err)
+	s := databaseService{
+		db: db,
+	}
+
+	find := regexp.QuoteMeta("SELECT * FROM `students` WHERE deleted_at IS NOT NULL AND `students`.`id` = ? ORDER BY `students`.`id` LIMIT 1")
+	restore := regexp.QuoteMeta("UPDATE `students` SET `deleted_at`=?,`deleted_by`=?,`version`=? WHERE id = ? AND deleted_at IS NOT NULL AND version = ?")
+	deleted := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "first_name", "last_name", "email", "age", "deleted_at", "deleted_by", "version"}).
+			AddRow("1", "John", "Doe", "john.doe@gmail.com", "33", time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC), 7, 3)
+	}
+	restoredBy := 3
+
+	tests := []struct {
+		name          string
+		setMock       func(mock sqlmock.Sqlmock)
+		want          model.Student
+		expectedError error
+	}{
+		{
+			name: "should_restore_student",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(find).
+					WithArgs(1).
+					WillReturnRows(deleted())
+				mock.ExpectExec(restore).
+					WithArgs(nil, nil, 4, 1, 3).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("INSERT INTO `student_versions`").
+					WithArgs(1, 4, "restore", &restoredBy, sqlmock.AnyArg(), "John", "Doe", 33, "john.doe@gmail.com", "{}").
+					WillReturnResult(sqlmock.NewResult(3, 1))
+				mock.ExpectCommit()
+			},
+			want: model.Student{ID: 1, FirstName: "John", LastName: "Doe", Email: "john.doe@gmail.com", Age: 33, Version: 4},
+		},
+		{
+			name: "should_return_error_student_not_deleted",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(find).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				mock.ExpectRollback()
+			},
+			expectedError: ErrStudentNotFound,
+		},
+		{
+			name: "should_return_error_restore_student",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(find).
+					WithArgs(1).
+					WillReturnRows(deleted())
+				mock.ExpectExec(restore).
+					WillReturnError(errors.New("error"))
+				mock.ExpectRollback()
+			},
+			expectedError: ErrRestoreStudent,
+		},
+		{
+			name: "should_return_error_duplicate_email",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(find).
+					WithArgs(1).
+					WillReturnRows(deleted())
+				mock.ExpectExec(restore).
+					WithArgs(nil, nil, 4, 1, 3).
+					WillReturnError(errDuplicateEmail)
+				mock.ExpectRollback()
+			},
+			expectedError: ErrDuplicateEmail,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setMock(mock)
+			got, err := s.RestoreStudent(1, &restoredBy)
+			if tt.expectedError != nil {
+				require.Error(t, err)
+				require.True(t, errors.Is(err, tt.expectedError))
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.want, got)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_databaseService_PurgeDeletedStudents(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	dialector := mysql.New(mysql.Config{
+		Conn:                      mockDB,
+		DriverName:                "mysql",
+		DSN:                       "sqlmock_db",
+		SkipInitializeWithVersion: true,
+	})
+
+	db, err := gorm.Open(dialector)
+	require.NoError(t, err)
+	s := databaseService{
+		db: db,
+	}
+
+	before := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectExec(
+		regexp.QuoteMeta("DELETE FROM `student_versions` WHERE student_id IN (SELECT `id` FROM `students` WHERE deleted_at < ?)")).
+		WithArgs(before).
+		WillReturnResult(sqlmock.NewResult(0, 12))
+	mock.ExpectExec(
+		regexp.QuoteMeta("DELETE FROM `students` WHERE deleted_at < ?")).
+		WithArgs(before).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	require.NoError(t, s.PurgeDeletedStudents(before))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/darolpz/students/internal/model"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvitationNotFound = errors.New("invitation not found")
+	ErrInvitationUsed     = errors.New("invitation already used")
+	ErrFindInvitation     = errors.New("couldn't find invitation")
+	ErrListInvitations    = errors.New("couldn't list invitations")
+	ErrCreateInvitation   = errors.New("couldn't create invitation")
+	ErrUseInvitation      = errors.New("couldn't use invitation")
+	ErrDeleteInvitation   = errors.New("couldn't delete invitation")
+)
+
+func (s databaseService) CreateInvitation(invitation model.Invitation) (model.Invitation, error) {
+	if err := s.db.Create(&invitation).Error; err != nil {
+		return invitation, fmt.Errorf("%w: %s", ErrCreateInvitation, err)
+	}
+	return invitation, nil
+}
+
+func (s databaseService) ListInvitations() ([]model.Invitation, error) {
+	var invitations []model.Invitation
+	if err := s.db.Order("id").Find(&invitations).Error; err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrListInvitations, err)
+	}
+	return invitations, nil
+}
+
+func (s databaseService) FindInvitation(hash string) (model.Invitation, error) {
+	var invitation model.Invitation
+	if err := s.db.First(&invitation, "token_hash = ?", hash).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return invitation, fmt.Errorf("%w: %s", ErrInvitationNotFound, err)
+		}
+		return invitation, fmt.Errorf("%w: %s", ErrFindInvitation, err)
+	}
+	return invitation, nil
+}
+
+// RedeemInvitation marks an invitation as used and creates the user it was
+// issued for. Like UsePasswordReset it only matches unused invitations, so an
+// invitation cannot open two accounts.
+func (s databaseService) RedeemInvitation(id int, user model.User) (model.User, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.Invitation{}).
+			Where("id = ? AND used_at IS NULL", id).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return fmt.Errorf("%w: %s", ErrUseInvitation, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvitationUsed
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			return fmt.Errorf("%w: %s", ErrCreateUser, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return user, err
+	}
+	return user, nil
+}
+
+// DeleteInvitation withdraws an invitation that was not used yet. Deleting a
+// missing or used invitation fails with ErrInvitationNotFound.
+func (s databaseService) DeleteInvitation(id int) error {
+	result := s.db.Where("used_at IS NULL").Delete(&model.Invitation{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("%w: %s", ErrDeleteInvitation, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvitationNotFound
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package database
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/darolpz/students/internal/model"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func Test_databaseService_RedeemInvitation(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	dialector := mysql.New(mysql.Config{
+		Conn:                      mockDB,
+		DriverName:                "mysql",
+		DSN:                       "sqlmock_db",
+		SkipInitializeWithVersion: true,
+	})
+
+	db, err := gorm.Open(dialector)
+	require.NoError(t, err)
+	s := databaseService{
+		db: db,
+	}
+
+	query := regexp.QuoteMeta("UPDATE `invitations` SET `used_at`=? WHERE id = ? AND used_at IS NULL")
+	user := model.User{Name: "John", Email: "john.doe@gmail.com", Password: "123456", Role: "admin"}
+
+	tests := []struct {
+		name          string
+		setMock       func(mock sqlmock.Sqlmock)
+		want          model.User
+		expectedError error
+	}{
+		{
+			name: "should_redeem_invitation",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(query).
+					WithArgs(sqlmock.AnyArg(), 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("INSERT INTO `users`").
+					WithArgs("John", "john.doe@gmail.com", "123456", "admin", nil, nil).
+					WillReturnResult(sqlmock.NewResult(7, 1))
+				mock.ExpectCommit()
+			},
+			want: model.User{ID: 7, Name: "John", Email: "john.doe@gmail.com", Password: "123456", Role: "admin"},
+		},
+		{
+			name: "should_return_error_invitation_used",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(query).
+					WithArgs(sqlmock.AnyArg(), 1).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+			},
+			expectedError: ErrInvitationUsed,
+		},
+		{
+			name: "should_return_error_create_user",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(query).
+					WithArgs(sqlmock.AnyArg(), 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("INSERT INTO `users`").
+					WithArgs("John", "john.doe@gmail.com", "123456", "admin", nil, nil).
+					WillReturnError(errors.New("some error"))
+				mock.ExpectRollback()
+			},
+			expectedError: ErrCreateUser,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setMock(mock)
+			got, err := s.RedeemInvitation(1, user)
+			if tt.expectedError != nil {
+				require.Error(t, err)
+				require.True(t, errors.Is(err, tt.expectedError))
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.want, got)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_databaseService_DeleteInvitation(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	dialector := mysql.New(mysql.Config{
+		Conn:                      mockDB,
+		DriverName:                "mysql",
+		DSN:                       "sqlmock_db",
+		SkipInitializeWithVersion: true,
+	})
+
+	db, err := gorm.Open(dialector)
+	require.NoError(t, err)
+	s := databaseService{
+		db: db,
+	}
+
+	query := regexp.QuoteMeta("DELETE FROM `invitations` WHERE used_at IS NULL AND `invitations`.`id` = ?")
+
+	tests := []struct {
+		name          string
+		setMock       func(mock sqlmock.Sqlmock)
+		expectedError error
+	}{
+		{
+			name: "should_delete_invitation",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(query).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "should_return_error_invitation_not_found",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(query).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			},
+			expectedError: ErrInvitationNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setMock(mock)
+			err := s.DeleteInvitation(1)
+			if tt.expectedError != nil {
+				require.Error(t, err)
+				require.True(t, errors.Is(err, tt.expectedError))
+			} else {
+				require.NoError(t, err)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/darolpz/students/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// orderBy sorts tx by fields.
+func orderBy(tx *gorm.DB, fields []model.SortField) *gorm.DB {
+	for _, field := range fields {
+		tx = tx.Order(clause.OrderByColumn{Column: clause.Column{Name: field.Column}, Desc: field.Desc})
+	}
+	return tx
+}
+
+// seek restricts tx to the rows that come after the sort key values in the
+// order of fields. Row value comparisons cannot mix directions, so the
+// condition is spelled out: (a > x) OR (a = x AND b < y) OR ... The columns
+// come from a whitelist and are safe to interpolate.
+func seek(tx *gorm.DB, fields []model.SortField, values []interface{}) *gorm.DB {
+	conditions := make([]string, len(fields))
+	var args []interface{}
+	for i, field := range fields {
+		parts := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			parts = append(parts, fmt.Sprintf("`%s` = ?", fields[j].Column))
+			args = append(args, values[j])
+		}
+		operator := ">"
+		if field.Desc {
+			operator = "<"
+		}
+		parts = append(parts, fmt.Sprintf("`%s` %s ?", field.Column, operator))
+		args = append(args, values[i])
+		conditions[i] = "(" + strings.Join(parts, " AND ") + ")"
+	}
+	return tx.Where(strings.Join(conditions, " OR "), args...)
+}
+
+// reverseSort returns fields with every direction flipped.
+func reverseSort(fields []model.SortField) []model.SortField {
+	reversed := make([]model.SortField, len(fields))
+	for i, field := range fields {
+		reversed[i] = model.SortField{Column: field.Column, Desc: !field.Desc}
+	}
+	return reversed
+}
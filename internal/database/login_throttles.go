@@ -0,0 +1,57 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/darolpz/students/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrFindLoginThrottles  = errors.New("couldn't find login throttles")
+	ErrRecordLoginFailure  = errors.New("couldn't record login failure")
+	ErrDeleteLoginThrottle = errors.New("couldn't delete login throttle")
+)
+
+func (s databaseService) FindLoginThrottles(ids []string) ([]model.LoginThrottle, error) {
+	var throttles []model.LoginThrottle
+	if err := s.db.Where("id IN ?", ids).Find(&throttles).Error; err != nil {
+		return throttles, fmt.Errorf("%w: %s", ErrFindLoginThrottles, err)
+	}
+	return throttles, nil
+}
+
+// RecordLoginFailure counts one more failure for id. Failures recorded before
+// resetBefore are discarded first. The counter is updated in a single
+// statement so that concurrent failures are never lost.
+func (s databaseService) RecordLoginFailure(id string, resetBefore time.Time) error {
+	now := time.Now()
+	err := s.db.Clauses(clause.OnConflict{
+		DoUpdates: clause.Set{
+			{Column: clause.Column{Name: "failures"}, Value: gorm.Expr("IF(last_failure_at < ?, 1, failures + 1)", resetBefore)},
+			{Column: clause.Column{Name: "last_failure_at"}, Value: now},
+		},
+	}).Create(&model.LoginThrottle{ID: id, Failures: 1, LastFailureAt: now}).Error
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrRecordLoginFailure, err)
+	}
+	return nil
+}
+
+func (s databaseService) DeleteLoginThrottle(id string) error {
+	if err := s.db.Delete(&model.LoginThrottle{ID: id}).Error; err != nil {
+		return fmt.Errorf("%w: %s", ErrDeleteLoginThrottle, err)
+	}
+	return nil
+}
+
+// DeleteStaleLoginThrottles removes throttles without failures since before.
+func (s databaseService) DeleteStaleLoginThrottles(before time.Time) error {
+	if err := s.db.Where("last_failure_at < ?", before).Delete(&model.LoginThrottle{}).Error; err != nil {
+		return fmt.Errorf("%w: %s", ErrDeleteLoginThrottle, err)
+	}
+	return nil
+}
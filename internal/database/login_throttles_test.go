@@ -0,0 +1,76 @@
+package database
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func Test_databaseService_RecordLoginFailure(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	dialector := mysql.New(mysql.Config{
+		Conn:                      mockDB,
+		DriverName:                "mysql",
+		DSN:                       "sqlmock_db",
+		SkipInitializeWithVersion: true,
+	})
+
+	db, err := gorm.Open(dialector)
+	require.NoError(t, err)
+	s := databaseService{
+		db: db,
+	}
+
+	query := regexp.QuoteMeta("INSERT INTO `login_throttles` (`id`,`failures`,`last_failure_at`) VALUES (?,?,?) " +
+		"ON DUPLICATE KEY UPDATE `failures`=IF(last_failure_at < ?, 1, failures + 1),`last_failure_at`=?")
+	resetBefore := time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		setMock       func(mock sqlmock.Sqlmock)
+		expectedError error
+	}{
+		{
+			name: "should_record_login_failure",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(query).
+					WithArgs("email:john.doe@gmail.com", 1, sqlmock.AnyArg(), resetBefore, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "should_return_error_record_login_failure",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(query).
+					WithArgs("email:john.doe@gmail.com", 1, sqlmock.AnyArg(), resetBefore, sqlmock.AnyArg()).
+					WillReturnError(errors.New("some error"))
+				mock.ExpectRollback()
+			},
+			expectedError: ErrRecordLoginFailure,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setMock(mock)
+			err := s.RecordLoginFailure("email:john.doe@gmail.com", resetBefore)
+			if tt.expectedError != nil {
+				require.Error(t, err)
+				require.True(t, errors.Is(err, tt.expectedError))
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/darolpz/students/internal/model"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrMFAEnrollmentNotFound   = errors.New("mfa enrollment not found")
+	ErrMFACodeReused           = errors.New("mfa code already used")
+	ErrMFARecoveryCodeNotFound = errors.New("mfa recovery code not found")
+	ErrFindMFAEnrollment       = errors.New("couldn't find mfa enrollment")
+	ErrCreateMFAEnrollment     = errors.New("couldn't create mfa enrollment")
+	ErrUpdateMFAEnrollment     = errors.New("couldn't update mfa enrollment")
+	ErrDeleteMFAEnrollment     = errors.New("couldn't delete mfa enrollment")
+)
+
+func (s databaseService) FindMFAEnrollment(userID int) (model.MFAEnrollment, error) {
+	var enrollment model.MFAEnrollment
+	if err := s.db.First(&enrollment, "user_id = ?", userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return enrollment, fmt.Errorf("%w: %s", ErrMFAEnrollmentNotFound, err)
+		}
+		return enrollment, fmt.Errorf("%w: %s", ErrFindMFAEnrollment, err)
+	}
+	return enrollment, nil
+}
+
+// CreateMFAEnrollment stores a pending enrollment, replacing any previous
+// pending one of the same user. Enabled enrollments are never replaced.
+func (s databaseService) CreateMFAEnrollment(enrollment model.MFAEnrollment) (model.MFAEnrollment, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND enabled_at IS NULL", enrollment.UserID).Delete(&model.MFAEnrollment{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&enrollment).Error
+	})
+	if err != nil {
+		return enrollment, fmt.Errorf("%w: %s", ErrCreateMFAEnrollment, err)
+	}
+	return enrollment, nil
+}
+
+// EnableMFAEnrollment activates the enrollment of a user, recording step as
+// used, and replaces its recovery codes.
+func (s databaseService) EnableMFAEnrollment(userID int, step int64, recoveryCodeHashes []string) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.MFAEnrollment{}).
+			Where("user_id = ? AND enabled_at IS NULL", userID).
+			Updates(map[string]interface{}{"enabled_at": time.Now(), "last_used_step": step})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrMFAEnrollmentNotFound
+		}
+		return replaceMFARecoveryCodes(tx, userID, recoveryCodeHashes)
+	})
+	if err != nil {
+		if errors.Is(err, ErrMFAEnrollmentNotFound) {
+			return err
+		}
+		return fmt.Errorf("%w: %s", ErrUpdateMFAEnrollment, err)
+	}
+	return nil
+}
+
+func replaceMFARecoveryCodes(tx *gorm.DB, userID int, hashes []string) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&model.MFARecoveryCode{}).Error; err != nil {
+		return err
+	}
+	codes := make([]model.MFARecoveryCode, len(hashes))
+	for i, hash := range hashes {
+		codes[i] = model.MFARecoveryCode{UserID: userID, CodeHash: hash}
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	return tx.Create(&codes).Error
+}
+
+// UseMFAStep records step as the last accepted TOTP step of a user. It fails
+// with ErrMFACodeReused unless step is newer than the last one, so each code
+// works only once.
+func (s databaseService) UseMFAStep(userID int, step int64) error {
+	result := s.db.Model(&model.MFAEnrollment{}).
+		Where("user_id = ? AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return fmt.Errorf("%w: %s", ErrUpdateMFAEnrollment, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrMFACodeReused
+	}
+	return nil
+}
+
+// UseMFARecoveryCode marks an unused recovery code of a user as used.
+func (s databaseService) UseMFARecoveryCode(userID int, hash string) error {
+	result := s.db.Model(&model.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("%w: %s", ErrUpdateMFAEnrollment, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrMFARecoveryCodeNotFound
+	}
+	return nil
+}
+
+// DeleteMFAEnrollment removes the enrollment and the recovery codes of a user.
+func (s databaseService) DeleteMFAEnrollment(userID int) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&model.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&model.MFAEnrollment{}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrDeleteMFAEnrollment, err)
+	}
+	return nil
+}
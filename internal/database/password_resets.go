@@ -0,0 +1,51 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/darolpz/students/internal/model"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPasswordResetNotFound = errors.New("password reset not found")
+	ErrPasswordResetUsed     = errors.New("password reset already used")
+	ErrFindPasswordReset     = errors.New("couldn't find password reset")
+	ErrCreatePasswordReset   = errors.New("couldn't create password reset")
+	ErrUsePasswordReset      = errors.New("couldn't use password reset")
+)
+
+func (s databaseService) CreatePasswordReset(reset model.PasswordReset) (model.PasswordReset, error) {
+	if err := s.db.Create(&reset).Error; err != nil {
+		return reset, fmt.Errorf("%w: %s", ErrCreatePasswordReset, err)
+	}
+	return reset, nil
+}
+
+func (s databaseService) FindPasswordReset(hash string) (model.PasswordReset, error) {
+	var reset model.PasswordReset
+	if err := s.db.First(&reset, "token_hash = ?", hash).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return reset, fmt.Errorf("%w: %s", ErrPasswordResetNotFound, err)
+		}
+		return reset, fmt.Errorf("%w: %s", ErrFindPasswordReset, err)
+	}
+	return reset, nil
+}
+
+// UsePasswordReset marks a reset token as used. Like RotateRefreshToken it
+// only matches unused tokens, so a token cannot be redeemed twice.
+func (s databaseService) UsePasswordReset(id int) error {
+	result := s.db.Model(&model.PasswordReset{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("%w: %s", ErrUsePasswordReset, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrPasswordResetUsed
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/darolpz/students/internal/model"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	ErrRefreshTokenRotated  = errors.New("refresh token already rotated")
+	ErrFindRefreshToken     = errors.New("couldn't find refresh token")
+	ErrCreateRefreshToken   = errors.New("couldn't create refresh token")
+	ErrRotateRefreshToken   = errors.New("couldn't rotate refresh token")
+	ErrRevokeRefreshToken   = errors.New("couldn't revoke refresh token")
+)
+
+func (s databaseService) CreateRefreshToken(token model.RefreshToken) (model.RefreshToken, error) {
+	if err := s.db.Create(&token).Error; err != nil {
+		return token, fmt.Errorf("%w: %s", ErrCreateRefreshToken, err)
+	}
+	return token, nil
+}
+
+func (s databaseService) FindRefreshToken(hash string) (model.RefreshToken, error) {
+	var token model.RefreshToken
+	if err := s.db.First(&token, "token_hash = ?", hash).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return token, fmt.Errorf("%w: %s", ErrRefreshTokenNotFound, err)
+		}
+		return token, fmt.Errorf("%w: %s", ErrFindRefreshToken, err)
+	}
+	return token, nil
+}
+
+// RotateRefreshToken marks a refresh token as used. The update only matches
+// tokens that are still active, so two concurrent refreshes with the same
+// token cannot both succeed.
+func (s databaseService) RotateRefreshToken(id int) error {
+	result := s.db.Model(&model.RefreshToken{}).
+		Where("id = ? AND rotated_at IS NULL AND revoked_at IS NULL", id).
+		Update("rotated_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("%w: %s", ErrRotateRefreshToken, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrRefreshTokenRotated
+	}
+	return nil
+}
+
+func (s databaseService) RevokeRefreshTokenFamily(familyID string) error {
+	err := s.db.Model(&model.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrRevokeRefreshToken, err)
+	}
+	return nil
+}
+
+func (s databaseService) RevokeUserRefreshTokens(userID int) error {
+	err := s.db.Model(&model.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrRevokeRefreshToken, err)
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package database
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/darolpz/students/internal/model"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func Test_databaseService_FindRefreshToken(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	dialector := mysql.New(mysql.Config{
+		Conn:                      mockDB,
+		DriverName:                "mysql",
+		DSN:                       "sqlmock_db",
+		SkipInitializeWithVersion: true,
+	})
+
+	db, err := gorm.Open(dialector)
+	require.NoError(t, err)
+	s := databaseService{
+		db: db,
+	}
+
+	expiresAt := time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)
+	createdAt := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		hash          string
+		setMock       func(mock sqlmock.Sqlmock)
+		want          model.RefreshToken
+		expectedError error
+	}{
+		{
+			name: "should_find_refresh_token",
+			hash: "abc",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(
+					regexp.QuoteMeta("SELECT * FROM `refresh_tokens` WHERE token_hash = ? ORDER BY `refresh_tokens`.`id` LIMIT 1")).
+					WithArgs("abc").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "family_id", "token_hash", "expires_at", "rotated_at", "revoked_at", "created_at"}).
+						AddRow(1, 2, "family", "abc", expiresAt, nil, nil, createdAt))
+			},
+			want: model.RefreshToken{
+				ID:        1,
+				UserID:    2,
+				FamilyID:  "family",
+				TokenHash: "abc",
+				ExpiresAt: expiresAt,
+				CreatedAt: createdAt,
+			},
+		},
+		{
+			name: "should_return_error_refresh_token_not_found",
+			hash: "abc",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(
+					regexp.QuoteMeta("SELECT * FROM `refresh_tokens` WHERE token_hash = ? ORDER BY `refresh_tokens`.`id` LIMIT 1")).
+					WithArgs("abc").
+					WillReturnError(gorm.ErrRecordNotFound)
+			},
+			expectedError: ErrRefreshTokenNotFound,
+		},
+		{
+			name: "should_return_error_find_refresh_token",
+			hash: "abc",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(
+					regexp.QuoteMeta("SELECT * FROM `refresh_tokens` WHERE token_hash = ? ORDER BY `refresh_tokens`.`id` LIMIT 1")).
+					WithArgs("abc").
+					WillReturnError(errors.New("some error"))
+			},
+			expectedError: ErrFindRefreshToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setMock(mock)
+			got, err := s.FindRefreshToken(tt.hash)
+			if tt.expectedError != nil {
+				require.Error(t, err)
+				require.True(t, errors.Is(err, tt.expectedError))
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_databaseService_RotateRefreshToken(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	dialector := mysql.New(mysql.Config{
+		Conn:                      mockDB,
+		DriverName:                "mysql",
+		DSN:                       "sqlmock_db",
+		SkipInitializeWithVersion: true,
+	})
+
+	db, err := gorm.Open(dialector)
+	require.NoError(t, err)
+	s := databaseService{
+		db: db,
+	}
+
+	query := regexp.QuoteMeta("UPDATE `refresh_tokens` SET `rotated_at`=? WHERE id = ? AND rotated_at IS NULL AND revoked_at IS NULL")
+
+	tests := []struct {
+		name          string
+		id            int
+		setMock       func(mock sqlmock.Sqlmock)
+		expectedError error
+	}{
+		{
+			name: "should_rotate_refresh_token",
+			id:   1,
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(query).
+					WithArgs(sqlmock.AnyArg(), 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "should_return_error_refresh_token_rotated",
+			id:   1,
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(query).
+					WithArgs(sqlmock.AnyArg(), 1).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			},
+			expectedError: ErrRefreshTokenRotated,
+		},
+		{
+			name: "should_return_error_rotate_refresh_token",
+			id:   1,
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(query).
+					WithArgs(sqlmock.AnyArg(), 1).
+					WillReturnError(errors.New("some error"))
+				mock.ExpectRollback()
+			},
+			expectedError: ErrRotateRefreshToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setMock(mock)
+			err := s.RotateRefreshToken(tt.id)
+			if tt.expectedError != nil {
+				require.Error(t, err)
+				require.True(t, errors.Is(err, tt.expectedError))
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_databaseService_RevokeRefreshTokenFamily(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	dialector := mysql.New(mysql.Config{
+		Conn:                      mockDB,
+		DriverName:                "mysql",
+		DSN:                       "sqlmock_db",
+		SkipInitializeWithVersion: true,
+	})
+
+	db, err := gorm.Open(dialector)
+	require.NoError(t, err)
+	s := databaseService{
+		db: db,
+	}
+
+	query := regexp.QuoteMeta("UPDATE `refresh_tokens` SET `revoked_at`=? WHERE family_id = ? AND revoked_at IS NULL")
+
+	tests := []struct {
+		name          string
+		familyID      string
+		setMock       func(mock sqlmock.Sqlmock)
+		expectedError error
+	}{
+		{
+			name:     "should_revoke_refresh_token_family",
+			familyID: "family",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(query).
+					WithArgs(sqlmock.AnyArg(), "family").
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name:     "should_return_error_revoke_refresh_token",
+			familyID: "family",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(query).
+					WithArgs(sqlmock.AnyArg(), "family").
+					WillReturnError(errors.New("some error"))
+				mock.ExpectRollback()
+			},
+			expectedError: ErrRevokeRefreshToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setMock(mock)
+			err := s.RevokeRefreshTokenFamily(tt.familyID)
+			if tt.expectedError != nil {
+				require.Error(t, err)
+				require.True(t, errors.Is(err, tt.expectedError))
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/darolpz/students/internal/model"
+)
+
+var (
+	ErrCreateRevokedToken = errors.New("couldn't revoke token")
+	ErrCheckRevokedToken  = errors.New("couldn't check token revocation")
+	ErrPurgeRevokedTokens = errors.New("couldn't purge revoked tokens")
+)
+
+func (s databaseService) CreateRevokedToken(token model.RevokedToken) (model.RevokedToken, error) {
+	if err := s.db.Create(&token).Error; err != nil {
+		return token, fmt.Errorf("%w: %s", ErrCreateRevokedToken, err)
+	}
+	return token, nil
+}
+
+// IsTokenRevoked reports whether the token jti of userID, issued at issuedAt
+// in sessionID, is on the revocation list, either by itself or through a
+// session or user wide entry.
+func (s databaseService) IsTokenRevoked(jti, sessionID string, userID int, issuedAt time.Time) (bool, error) {
+	var count int64
+	err := s.db.Model(&model.RevokedToken{}).
+		Where("(jti = ? AND jti <> '') OR (session_id = ? AND session_id <> '') OR (user_id = ? AND issued_before > ?)",
+			jti, sessionID, userID, issuedAt).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("%w: %s", ErrCheckRevokedToken, err)
+	}
+	return count > 0, nil
+}
+
+// DeleteExpiredRevokedTokens removes entries whose tokens have expired.
+func (s databaseService) DeleteExpiredRevokedTokens() error {
+	if err := s.db.Where("expires_at < ?", time.Now()).Delete(&model.RevokedToken{}).Error; err != nil {
+		return fmt.Errorf("%w: %s", ErrPurgeRevokedTokens, err)
+	}
+	return nil
+}
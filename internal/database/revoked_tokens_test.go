@@ -0,0 +1,83 @@
+package database
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func Test_databaseService_IsTokenRevoked(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	dialector := mysql.New(mysql.Config{
+		Conn:                      mockDB,
+		DriverName:                "mysql",
+		DSN:                       "sqlmock_db",
+		SkipInitializeWithVersion: true,
+	})
+
+	db, err := gorm.Open(dialector)
+	require.NoError(t, err)
+	s := databaseService{
+		db: db,
+	}
+
+	query := regexp.QuoteMeta("SELECT count(*) FROM `revoked_tokens` WHERE (jti = ? AND jti <> '') OR (session_id = ? AND session_id <> '') OR (user_id = ? AND issued_before > ?)")
+	issuedAt := time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		setMock       func(mock sqlmock.Sqlmock)
+		want          bool
+		expectedError error
+	}{
+		{
+			name: "should_return_token_revoked",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).
+					WithArgs("jti", "session", 1, issuedAt).
+					WillReturnRows(sqlmock.NewRows([]string{"count(*)"}).AddRow(1))
+			},
+			want: true,
+		},
+		{
+			name: "should_return_token_not_revoked",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).
+					WithArgs("jti", "session", 1, issuedAt).
+					WillReturnRows(sqlmock.NewRows([]string{"count(*)"}).AddRow(0))
+			},
+			want: false,
+		},
+		{
+			name: "should_return_error_check_revoked_token",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).
+					WithArgs("jti", "session", 1, issuedAt).
+					WillReturnError(errors.New("some error"))
+			},
+			expectedError: ErrCheckRevokedToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setMock(mock)
+			got, err := s.IsTokenRevoked("jti", "session", 1, issuedAt)
+			if tt.expectedError != nil {
+				require.Error(t, err)
+				require.True(t, errors.Is(err, tt.expectedError))
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
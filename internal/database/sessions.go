@@ -0,0 +1,73 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/darolpz/students/internal/model"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrFindSession     = errors.New("couldn't find session")
+	ErrListSessions    = errors.New("couldn't list sessions")
+	ErrCreateSession   = errors.New("couldn't create session")
+	ErrTouchSession    = errors.New("couldn't record session activity")
+	ErrPurgeSessions   = errors.New("couldn't purge sessions")
+)
+
+func (s databaseService) CreateSession(session model.Session) (model.Session, error) {
+	if err := s.db.Create(&session).Error; err != nil {
+		return session, fmt.Errorf("%w: %s", ErrCreateSession, err)
+	}
+	return session, nil
+}
+
+func (s databaseService) FindSession(id string) (model.Session, error) {
+	var session model.Session
+	if err := s.db.First(&session, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return session, fmt.Errorf("%w: %s", ErrSessionNotFound, err)
+		}
+		return session, fmt.Errorf("%w: %s", ErrFindSession, err)
+	}
+	return session, nil
+}
+
+// ListSessions returns the sessions of userID that can still refresh their
+// tokens, most recently seen first. Sessions whose refresh tokens were all
+// revoked, by a logout or otherwise, are left out.
+func (s databaseService) ListSessions(userID int) ([]model.Session, error) {
+	var sessions []model.Session
+	err := s.db.
+		Where("user_id = ?", userID).
+		Where("EXISTS (SELECT 1 FROM refresh_tokens WHERE refresh_tokens.family_id = sessions.id "+
+			"AND refresh_tokens.rotated_at IS NULL AND refresh_tokens.revoked_at IS NULL AND refresh_tokens.expires_at > ?)", time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrListSessions, err)
+	}
+	return sessions, nil
+}
+
+// TouchSession records that a session was used now.
+func (s databaseService) TouchSession(id string) error {
+	err := s.db.Model(&model.Session{}).
+		Where("id = ?", id).
+		Update("last_seen_at", time.Now()).Error
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrTouchSession, err)
+	}
+	return nil
+}
+
+// DeleteStaleSessions removes sessions that were not seen since before.
+func (s databaseService) DeleteStaleSessions(before time.Time) error {
+	if err := s.db.Where("last_seen_at < ?", before).Delete(&model.Session{}).Error; err != nil {
+		return fmt.Errorf("%w: %s", ErrPurgeSessions, err)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package database
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/darolpz/students/internal/model"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func Test_databaseService_ListSessions(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	dialector := mysql.New(mysql.Config{
+		Conn:                      mockDB,
+		DriverName:                "mysql",
+		DSN:                       "sqlmock_db",
+		SkipInitializeWithVersion: true,
+	})
+
+	db, err := gorm.Open(dialector)
+	require.NoError(t, err)
+	s := databaseService{
+		db: db,
+	}
+
+	query := regexp.QuoteMeta("SELECT * FROM `sessions` WHERE user_id = ? AND (EXISTS (SELECT 1 FROM refresh_tokens WHERE refresh_tokens.family_id = sessions.id " +
+		"AND refresh_tokens.rotated_at IS NULL AND refresh_tokens.revoked_at IS NULL AND refresh_tokens.expires_at > ?)) ORDER BY last_seen_at DESC")
+	createdAt := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	lastSeenAt := time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		setMock       func(mock sqlmock.Sqlmock)
+		want          []model.Session
+		expectedError error
+	}{
+		{
+			name: "should_list_sessions",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).
+					WithArgs(1, sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "user_agent", "ip", "created_at", "last_seen_at"}).
+						AddRow("session", 1, "curl/7.79.1", "127.0.0.1", createdAt, lastSeenAt))
+			},
+			want: []model.Session{{
+				ID:         "session",
+				UserID:     1,
+				UserAgent:  "curl/7.79.1",
+				IP:         "127.0.0.1",
+				CreatedAt:  createdAt,
+				LastSeenAt: lastSeenAt,
+			}},
+		},
+		{
+			name: "should_return_error_list_sessions",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).
+					WithArgs(1, sqlmock.AnyArg()).
+					WillReturnError(errors.New("some error"))
+			},
+			expectedError: ErrListSessions,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setMock(mock)
+			got, err := s.ListSessions(1)
+			if tt.expectedError != nil {
+				require.Error(t, err)
+				require.True(t, errors.Is(err, tt.expectedError))
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.want, got)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_databaseService_FindSession(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	dialector := mysql.New(mysql.Config{
+		Conn:                      mockDB,
+		DriverName:                "mysql",
+		DSN:                       "sqlmock_db",
+		SkipInitializeWithVersion: true,
+	})
+
+	db, err := gorm.Open(dialector)
+	require.NoError(t, err)
+	s := databaseService{
+		db: db,
+	}
+
+	query := regexp.QuoteMeta("SELECT * FROM `sessions` WHERE id = ? ORDER BY `sessions`.`id` LIMIT 1")
+
+	tests := []struct {
+		name          string
+		setMock       func(mock sqlmock.Sqlmock)
+		expectedError error
+	}{
+		{
+			name: "should_find_session",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).
+					WithArgs("session").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow("session", 1))
+			},
+		},
+		{
+			name: "should_return_error_session_not_found",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).
+					WithArgs("session").
+					WillReturnError(gorm.ErrRecordNotFound)
+			},
+			expectedError: ErrSessionNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setMock(mock)
+			got, err := s.FindSession("session")
+			if tt.expectedError != nil {
+				require.Error(t, err)
+				require.True(t, errors.Is(err, tt.expectedError))
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, model.Session{ID: "session", UserID: 1}, got)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/darolpz/students/internal/model"
+	"gorm.io/gorm"
+)
+
+var ErrSaveStudents = errors.New("couldn't save students")
+
+// SaveStudentsError is why SaveStudents failed: Err, the failure to save the
+// student at Index. It is an ErrSaveStudents.
+type SaveStudentsError struct {
+	Index int
+	Err   error
+}
+
+func (e SaveStudentsError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrSaveStudents, e.Err)
+}
+
+func (e SaveStudentsError) Is(target error) bool {
+	return target == ErrSaveStudents
+}
+
+func (e SaveStudentsError) Unwrap() error {
+	return e.Err
+}
+
+// FindStudentsByEmail returns the students with any of emails, including
+// deleted ones.
+func (s databaseService) FindStudentsByEmail(emails []string) ([]model.Student, error) {
+	var students []model.Student
+	if len(emails) == 0 {
+		return students, nil
+	}
+	if err := s.db.Where("email IN ?", emails).Find(&students).Error; err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrFindStudent, err)
+	}
+	return students, nil
+}
+
+// SaveStudents writes students in a single transaction, creating the ones
+// without an ID and replacing the others, which fails with
+// ErrStudentNotFound for deleted ones. Nothing is written when any of them
+// fails, and the error is a SaveStudentsError naming it.
+func (s databaseService) SaveStudents(students []model.Student, changedBy *int) ([]model.Student, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		for i := range students {
+			if err := saveImportedStudent(tx, &students[i], changedBy); err != nil {
+				return SaveStudentsError{Index: i, Err: fmt.Errorf("%s: %w", students[i].Email, err)}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return students, nil
+}
+
+// saveImportedStudent creates or replaces student within tx, as SaveStudents
+// does with each of its students.
+func saveImportedStudent(tx *gorm.DB, student *model.Student, changedBy *int) error {
+	if student.ID == 0 {
+		student.Version = 1
+		if err := tx.Create(student).Error; err != nil {
+			return studentWriteError(ErrCreateStudent, err)
+		}
+		return recordVersion(tx, model.StudentCreated, nil, *student, changedBy)
+	}
+
+	var before model.Student
+	if err := tx.Where("deleted_at IS NULL").First(&before, student.ID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: %s", ErrStudentNotFound, err)
+		}
+		return fmt.Errorf("%w: %s", ErrFindStudent, err)
+	}
+	student.Version = before.Version
+	if len(model.DiffStudents(&before, *student)) == 0 {
+		return nil
+	}
+	if err := saveStudent(tx, student); err != nil {
+		return err
+	}
+	return recordVersion(tx, model.StudentUpdated, &before, *student, changedBy)
+}
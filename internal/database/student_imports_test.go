@@ -0,0 +1,213 @@
+package database
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/darolpz/students/internal/model"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func Test_databaseService_FindStudentsByEmail(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	dialector := mysql.New(mysql.Config{
+		Conn:                      mockDB,
+		DriverName:                "mysql",
+		DSN:                       "sqlmock_db",
+		SkipInitializeWithVersion: true,
+	})
+
+	db, err := gorm.Open(dialector)
+	require.NoError(t, err)
+	s := databaseService{
+		db: db,
+	}
+
+	tests := []struct {
+		name          string
+		emails        []string
+		setMock       func(mock sqlmock.Sqlmock)
+		want          []model.Student
+		expectedError error
+	}{
+		{
+			name:   "should_find_students",
+			emails: []string{"john.doe@gmail.com", "ana@gmail.com"},
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(
+					regexp.QuoteMeta("SELECT * FROM `students` WHERE email IN (?,?)")).
+					WithArgs("john.doe@gmail.com", "ana@gmail.com").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "first_name", "last_name", "email", "age"}).
+						AddRow("1", "John", "Doe", "john.doe@gmail.com", "33"))
+			},
+			want: []model.Student{
+				{ID: 1, FirstName: "John", LastName: "Doe", Email: "john.doe@gmail.com", Age: 33},
+			},
+		},
+		{
+			name:    "should_not_query_without_emails",
+			setMock: func(mock sqlmock.Sqlmock) {},
+			want:    []model.Student(nil),
+		},
+		{
+			name:   "should_return_error",
+			emails: []string{"john.doe@gmail.com"},
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(
+					regexp.QuoteMeta("SELECT * FROM `students` WHERE email IN (?)")).
+					WillReturnError(errors.New("error"))
+			},
+			expectedError: ErrFindStudent,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setMock(mock)
+			got, err := s.FindStudentsByEmail(tt.emails)
+			if tt.expectedError != nil {
+				require.Error(t, err)
+				require.True(t, errors.Is(err, tt.expectedError))
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.want, got)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_databaseService_SaveStudents(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	dialector := mysql.New(mysql.Config{
+		Conn:                      mockDB,
+		DriverName:                "mysql",
+		DSN:                       "sqlmock_db",
+		SkipInitializeWithVersion: true,
+	})
+
+	db, err := gorm.Open(dialector)
+	require.NoError(t, err)
+	s := databaseService{
+		db: db,
+	}
+
+	students := func() []model.Student {
+		return []model.Student{
+			{FirstName: "Ana", LastName: "Perez", Email: "ana@gmail.com", Age: 20},
+			{ID: 1, FirstName: "John", LastName: "Smith", Email: "john.doe@gmail.com", Age: 34},
+		}
+	}
+
+	find := regexp.QuoteMeta("SELECT * FROM `students` WHERE deleted_at IS NULL AND `students`.`id` = ? ORDER BY `students`.`id` LIMIT 1")
+
+	tests := []struct {
+		name          string
+		setMock       func(mock sqlmock.Sqlmock)
+		want          []model.Student
+		expectedError error
+	}{
+		{
+			name: "should_create_and_update_students",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(
+					regexp.QuoteMeta("INSERT INTO `students` (`first_name`,`last_name`,`age`,`email`,`deleted_at`,`deleted_by`,`version`) VALUES (?,?,?,?,?,?,?)")).
+					WithArgs("Ana", "Perez", 20, "ana@gmail.com", nil, nil, 1).
+					WillReturnResult(sqlmock.NewResult(2, 1))
+				mock.ExpectExec("INSERT INTO `student_versions`").
+					WithArgs(2, 1, "create", nil, sqlmock.AnyArg(), "Ana", "Perez", 20, "ana@gmail.com", sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectQuery(find).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "first_name", "last_name", "email", "age", "version"}).
+						AddRow("1", "John", "Doe", "john.doe@gmail.com", "33", 3))
+				mock.ExpectExec(
+					regexp.QuoteMeta("UPDATE `students` SET `first_name`=?,`last_name`=?,`age`=?,`email`=?,`deleted_at`=?,`deleted_by`=?,`version`=? WHERE version = ? AND `id` = ?")).
+					WithArgs("John", "Smith", 34, "john.doe@gmail.com", nil, nil, 4, 3, 1).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("INSERT INTO `student_versions`").
+					WithArgs(1, 4, "update", nil, sqlmock.AnyArg(), "John", "Smith", 34, "john.doe@gmail.com",
+						`{"age":{"from":33,"to":34},"last_name":{"from":"Doe","to":"Smith"}}`).
+					WillReturnResult(sqlmock.NewResult(2, 1))
+				mock.ExpectCommit()
+			},
+			want: []model.Student{
+				{ID: 2, FirstName: "Ana", LastName: "Perez", Email: "ana@gmail.com", Age: 20, Version: 1},
+				{ID: 1, FirstName: "John", LastName: "Smith", Email: "john.doe@gmail.com", Age: 34, Version: 4},
+			},
+		},
+		{
+			name: "should_roll_back_on_error",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(
+					regexp.QuoteMeta("INSERT INTO `students` (`first_name`,`last_name`,`age`,`email`,`deleted_at`,`deleted_by`,`version`) VALUES (?,?,?,?,?,?,?)")).
+					WillReturnResult(sqlmock.NewResult(2, 1))
+				mock.ExpectExec("INSERT INTO `student_versions`").
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectQuery(find).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "first_name", "last_name", "email", "age", "version"}).
+						AddRow("1", "John", "Doe", "john.doe@gmail.com", "33", 3))
+				mock.ExpectExec(
+					regexp.QuoteMeta("UPDATE `students` SET `first_name`=?,`last_name`=?,`age`=?,`email`=?,`deleted_at`=?,`deleted_by`=?,`version`=? WHERE version = ? AND `id` = ?")).
+					WillReturnError(errors.New("error"))
+				mock.ExpectRollback()
+			},
+			expectedError: ErrSaveStudents,
+		},
+		{
+			name: "should_return_error_student_deleted",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(
+					regexp.QuoteMeta("INSERT INTO `students` (`first_name`,`last_name`,`age`,`email`,`deleted_at`,`deleted_by`,`version`) VALUES (?,?,?,?,?,?,?)")).
+					WillReturnResult(sqlmock.NewResult(2, 1))
+				mock.ExpectExec("INSERT INTO `student_versions`").
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectQuery(find).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				mock.ExpectRollback()
+			},
+			expectedError: ErrStudentNotFound,
+		},
+		{
+			name: "should_return_error_duplicate_email",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(
+					regexp.QuoteMeta("INSERT INTO `students` (`first_name`,`last_name`,`age`,`email`,`deleted_at`,`deleted_by`,`version`) VALUES (?,?,?,?,?,?,?)")).
+					WithArgs("Ana", "Perez", 20, "ana@gmail.com", nil, nil, 1).
+					WillReturnError(errDuplicateEmail)
+				mock.ExpectRollback()
+			},
+			expectedError: ErrDuplicateEmail,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setMock(mock)
+			got, err := s.SaveStudents(students(), nil)
+			if tt.expectedError != nil {
+				require.Error(t, err)
+				require.True(t, errors.Is(err, tt.expectedError))
+				require.True(t, errors.Is(err, ErrSaveStudents))
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.want, got)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
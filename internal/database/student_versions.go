@@ -0,0 +1,105 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/darolpz/students/internal/model"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRecordStudentVersion   = errors.New("couldn't record student history")
+	ErrFindStudentVersion     = errors.New("couldn't find student version")
+	ErrStudentVersionNotFound = errors.New("student version not found")
+)
+
+// recordVersion appends an entry to the history of student, which action
+// left as it is now, within the transaction tx that made the change. before
+// is the student as it was, or nil when it was just created. The entry takes
+// the version of student, which the change bumped while holding the lock on
+// its row, so concurrent changes never number two entries alike. Every
+// version of a student has an entry, as updates that change nothing do not
+// bump the version.
+func recordVersion(tx *gorm.DB, action string, before *model.Student, student model.Student, changedBy *int) error {
+	version := model.StudentVersion{
+		StudentID: student.ID,
+		Version:   student.Version,
+		Action:    action,
+		ChangedBy: changedBy,
+		ChangedAt: time.Now(),
+		FirstName: student.FirstName,
+		LastName:  student.LastName,
+		Age:       student.Age,
+		Email:     student.Email,
+		Changes:   model.DiffStudents(before, student),
+	}
+	if err := tx.Create(&version).Error; err != nil {
+		return fmt.Errorf("%w: %s", ErrRecordStudentVersion, err)
+	}
+	return nil
+}
+
+// ListStudentVersions returns the history of the student with id, oldest
+// first. The history outlives deletion until the student is purged.
+func (s databaseService) ListStudentVersions(id int) ([]model.StudentVersion, error) {
+	var versions []model.StudentVersion
+	if err := s.db.Where("student_id = ?", id).Order("version").Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrFindStudentVersion, err)
+	}
+	return versions, nil
+}
+
+func (s databaseService) FindStudentVersion(id int, version int) (model.StudentVersion, error) {
+	return findStudentVersion(s.db, id, version)
+}
+
+func findStudentVersion(tx *gorm.DB, id int, version int) (model.StudentVersion, error) {
+	var v model.StudentVersion
+	if err := tx.Where("student_id = ? AND version = ?", id, version).First(&v).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return v, fmt.Errorf("%w: %s", ErrStudentVersionNotFound, err)
+		}
+		return v, fmt.Errorf("%w: %s", ErrFindStudentVersion, err)
+	}
+	return v, nil
+}
+
+// RevertStudent sets the fields of the student with id back to the values
+// version left them with, recording it as a new version. Deleted students
+// must be restored first. A current version other than 0 is the one the
+// caller read, as in UpdateStudent. It fails with ErrDuplicateEmail when
+// another student has taken the email of version since.
+func (s databaseService) RevertStudent(id int, version int, current int, changedBy *int) (model.Student, error) {
+	var student model.Student
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("deleted_at IS NULL").First(&student, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: %s", ErrStudentNotFound, err)
+			}
+			return fmt.Errorf("%w: %s", ErrFindStudent, err)
+		}
+		if current != 0 && student.Version != current {
+			return ErrStudentModified
+		}
+		v, err := findStudentVersion(tx, id, version)
+		if err != nil {
+			return err
+		}
+
+		before := student
+		student.FirstName = v.FirstName
+		student.LastName = v.LastName
+		student.Age = v.Age
+		student.Email = v.Email
+		if err := saveStudent(tx, &student); err != nil {
+			return err
+		}
+		return recordVersion(tx, model.StudentReverted, &before, student, changedBy)
+	})
+	if err != nil {
+		return model.Student{}, err
+	}
+	return student, nil
+}
@@ -0,0 +1,311 @@
+package database
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/darolpz/students/internal/model"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+var studentVersionColumns = []string{"id", "student_id", "version", "action", "changed_by", "changed_at", "first_name", "last_name", "age", "email", "changes"}
+
+func Test_databaseService_ListStudentVersions(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	dialector := mysql.New(mysql.Config{
+		Conn:                      mockDB,
+		DriverName:                "mysql",
+		DSN:                       "sqlmock_db",
+		SkipInitializeWithVersion: true,
+	})
+
+	db, err := gorm.Open(dialector)
+	require.NoError(t, err)
+	s := databaseService{
+		db: db,
+	}
+
+	changedAt := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+	changedBy := 7
+	query := regexp.QuoteMeta("SELECT * FROM `student_versions` WHERE student_id = ? ORDER BY version")
+
+	tests := []struct {
+		name          string
+		setMock       func(mock sqlmock.Sqlmock)
+		want          []model.StudentVersion
+		expectedError error
+	}{
+		{
+			name: "should_list_student_versions",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows(studentVersionColumns).
+						AddRow(1, 1, 1, "create", 7, changedAt, "John", "Doe", 33, "john.doe@gmail.com", `{"age":{"from":null,"to":33}}`).
+						AddRow(2, 1, 2, "update", nil, changedAt, "John", "Smith", 33, "john.doe@gmail.com", `{"last_name":{"from":"Doe","to":"Smith"}}`))
+			},
+			want: []model.StudentVersion{
+				{ID: 1, StudentID: 1, Version: 1, Action: "create", ChangedBy: &changedBy, ChangedAt: changedAt,
+					FirstName: "John", LastName: "Doe", Age: 33, Email: "john.doe@gmail.com",
+					Changes: model.FieldChanges{"age": {To: float64(33)}}},
+				{ID: 2, StudentID: 1, Version: 2, Action: "update", ChangedAt: changedAt,
+					FirstName: "John", LastName: "Smith", Age: 33, Email: "john.doe@gmail.com",
+					Changes: model.FieldChanges{"last_name": {From: "Doe", To: "Smith"}}},
+			},
+		},
+		{
+			name: "should_return_error_find_student_version",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).
+					WithArgs(1).
+					WillReturnError(errors.New("error"))
+			},
+			expectedError: ErrFindStudentVersion,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setMock(mock)
+			got, err := s.ListStudentVersions(1)
+			if tt.expectedError != nil {
+				require.Error(t, err)
+				require.True(t, errors.Is(err, tt.expectedError))
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.want, got)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_databaseService_FindStudentVersion(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	dialector := mysql.New(mysql.Config{
+		Conn:                      mockDB,
+		DriverName:                "mysql",
+		DSN:                       "sqlmock_db",
+		SkipInitializeWithVersion: true,
+	})
+
+	db, err := gorm.Open(dialector)
+	require.NoError(t, err)
+	s := databaseService{
+		db: db,
+	}
+
+	changedAt := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+	query := regexp.QuoteMeta("SELECT * FROM `student_versions` WHERE student_id = ? AND version = ? ORDER BY `student_versions`.`id` LIMIT 1")
+
+	tests := []struct {
+		name          string
+		setMock       func(mock sqlmock.Sqlmock)
+		want          model.StudentVersion
+		expectedError error
+	}{
+		{
+			name: "should_find_student_version",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).
+					WithArgs(1, 2).
+					WillReturnRows(sqlmock.NewRows(studentVersionColumns).
+						AddRow(2, 1, 2, "delete", nil, changedAt, "John", "Doe", 33, "john.doe@gmail.com", `{}`))
+			},
+			want: model.StudentVersion{ID: 2, StudentID: 1, Version: 2, Action: "delete", ChangedAt: changedAt,
+				FirstName: "John", LastName: "Doe", Age: 33, Email: "john.doe@gmail.com", Changes: model.FieldChanges{}},
+		},
+		{
+			name: "should_return_error_student_version_not_found",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).
+					WithArgs(1, 2).
+					WillReturnRows(sqlmock.NewRows(studentVersionColumns))
+			},
+			expectedError: ErrStudentVersionNotFound,
+		},
+		{
+			name: "should_return_error_find_student_version",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).
+					WithArgs(1, 2).
+					WillReturnError(errors.New("error"))
+			},
+			expectedError: ErrFindStudentVersion,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setMock(mock)
+			got, err := s.FindStudentVersion(1, 2)
+			if tt.expectedError != nil {
+				require.Error(t, err)
+				require.True(t, errors.Is(err, tt.expectedError))
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.want, got)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_databaseService_RevertStudent(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	dialector := mysql.New(mysql.Config{
+		Conn:                      mockDB,
+		DriverName:                "mysql",
+		DSN:                       "sqlmock_db",
+		SkipInitializeWithVersion: true,
+	})
+
+	db, err := gorm.Open(dialector)
+	require.NoError(t, err)
+	s := databaseService{
+		db: db,
+	}
+
+	changedAt := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+	changedBy := 7
+	findStudent := regexp.QuoteMeta("SELECT * FROM `students` WHERE deleted_at IS NULL AND `students`.`id` = ? ORDER BY `students`.`id` LIMIT 1")
+	findVersion := regexp.QuoteMeta("SELECT * FROM `student_versions` WHERE student_id = ? AND version = ? ORDER BY `student_versions`.`id` LIMIT 1")
+	student := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "first_name", "last_name", "email", "age", "version"}).
+			AddRow("1", "John", "Smith", "john.smith@gmail.com", "34", 3)
+	}
+
+	tests := []struct {
+		name          string
+		current       int
+		setMock       func(mock sqlmock.Sqlmock)
+		want          model.Student
+		expectedError error
+	}{
+		{
+			name: "should_revert_student",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(findStudent).
+					WithArgs(1).
+					WillReturnRows(student())
+				mock.ExpectQuery(findVersion).
+					WithArgs(1, 1).
+					WillReturnRows(sqlmock.NewRows(studentVersionColumns).
+						AddRow(1, 1, 1, "create", 7, changedAt, "John", "Doe", 33, "john.doe@gmail.com", `{}`))
+				mock.ExpectExec(
+					regexp.QuoteMeta("UPDATE `students` SET `first_name`=?,`last_name`=?,`age`=?,`email`=?,`deleted_at`=?,`deleted_by`=?,`version`=? WHERE version = ? AND `id` = ?")).
+					WithArgs("John", "Doe", 33, "john.doe@gmail.com", nil, nil, 4, 3, 1).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("INSERT INTO `student_versions`").
+					WithArgs(1, 4, "revert", &changedBy, sqlmock.AnyArg(), "John", "Doe", 33, "john.doe@gmail.com",
+						`{"age":{"from":34,"to":33},"email":{"from":"john.smith@gmail.com","to":"john.doe@gmail.com"},"last_name":{"from":"Smith","to":"Doe"}}`).
+					WillReturnResult(sqlmock.NewResult(3, 1))
+				mock.ExpectCommit()
+			},
+			want: model.Student{ID: 1, FirstName: "John", LastName: "Doe", Email: "john.doe@gmail.com", Age: 33, Version: 4},
+		},
+		{
+			name: "should_return_error_student_not_found",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(findStudent).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				mock.ExpectRollback()
+			},
+			expectedError: ErrStudentNotFound,
+		},
+		{
+			name:    "should_return_error_student_modified",
+			current: 2,
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(findStudent).
+					WithArgs(1).
+					WillReturnRows(student())
+				mock.ExpectRollback()
+			},
+			expectedError: ErrStudentModified,
+		},
+		{
+			name: "should_return_error_student_version_not_found",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(findStudent).
+					WithArgs(1).
+					WillReturnRows(student())
+				mock.ExpectQuery(findVersion).
+					WithArgs(1, 1).
+					WillReturnRows(sqlmock.NewRows(studentVersionColumns))
+				mock.ExpectRollback()
+			},
+			expectedError: ErrStudentVersionNotFound,
+		},
+		{
+			name: "should_return_error_duplicate_email",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(findStudent).
+					WithArgs(1).
+					WillReturnRows(student())
+				mock.ExpectQuery(findVersion).
+					WithArgs(1, 1).
+					WillReturnRows(sqlmock.NewRows(studentVersionColumns).
+						AddRow(1, 1, 1, "create", 7, changedAt, "John", "Doe", 33, "john.doe@gmail.com", `{}`))
+				mock.ExpectExec(
+					regexp.QuoteMeta("UPDATE `students` SET `first_name`=?,`last_name`=?,`age`=?,`email`=?,`deleted_at`=?,`deleted_by`=?,`version`=? WHERE version = ? AND `id` = ?")).
+					WithArgs("John", "Doe", 33, "john.doe@gmail.com", nil, nil, 4, 3, 1).
+					WillReturnError(errDuplicateEmail)
+				mock.ExpectRollback()
+			},
+			expectedError: ErrDuplicateEmail,
+		},
+		{
+			name: "should_return_error_record_student_version",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(findStudent).
+					WithArgs(1).
+					WillReturnRows(student())
+				mock.ExpectQuery(findVersion).
+					WithArgs(1, 1).
+					WillReturnRows(sqlmock.NewRows(studentVersionColumns).
+						AddRow(1, 1, 1, "create", 7, changedAt, "John", "Doe", 33, "john.doe@gmail.com", `{}`))
+				mock.ExpectExec(
+					regexp.QuoteMeta("UPDATE `students` SET `first_name`=?,`last_name`=?,`age`=?,`email`=?,`deleted_at`=?,`deleted_by`=?,`version`=? WHERE version = ? AND `id` = ?")).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("INSERT INTO `student_versions`").
+					WillReturnError(errors.New("error"))
+				mock.ExpectRollback()
+			},
+			expectedError: ErrRecordStudentVersion,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setMock(mock)
+			got, err := s.RevertStudent(1, 1, tt.current, &changedBy)
+			if tt.expectedError != nil {
+				require.Error(t, err)
+				require.True(t, errors.Is(err, tt.expectedError))
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.want, got)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
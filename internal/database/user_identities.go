@@ -0,0 +1,52 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/darolpz/students/internal/model"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUserIdentityNotFound = errors.New("user identity not found")
+	ErrFindUserIdentity     = errors.New("couldn't find user identity")
+	ErrCreateUserIdentity   = errors.New("couldn't create user identity")
+)
+
+func (s databaseService) FindUserIdentity(issuer, subject string) (model.UserIdentity, error) {
+	var identity model.UserIdentity
+	if err := s.db.First(&identity, "issuer = ? AND subject = ?", issuer, subject).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return identity, fmt.Errorf("%w: %s", ErrUserIdentityNotFound, err)
+		}
+		return identity, fmt.Errorf("%w: %s", ErrFindUserIdentity, err)
+	}
+	return identity, nil
+}
+
+func (s databaseService) CreateUserIdentity(identity model.UserIdentity) (model.UserIdentity, error) {
+	if err := s.db.Create(&identity).Error; err != nil {
+		return identity, fmt.Errorf("%w: %s", ErrCreateUserIdentity, err)
+	}
+	return identity, nil
+}
+
+// ProvisionUser creates a user together with its link to an external
+// identity, so that neither exists without the other.
+func (s databaseService) ProvisionUser(user model.User, identity model.UserIdentity) (model.User, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return fmt.Errorf("%w: %s", ErrCreateUser, err)
+		}
+		identity.UserID = user.ID
+		if err := tx.Create(&identity).Error; err != nil {
+			return fmt.Errorf("%w: %s", ErrCreateUserIdentity, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return user, err
+	}
+	return user, nil
+}
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/darolpz/students/internal/model"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrListUsers  = errors.New("couldn't list users")
+	ErrDeleteUser = errors.New("couldn't delete user")
+)
+
+// ListUsers returns a page of the users matching query and how many match in
+// total.
+func (s databaseService) ListUsers(query model.UserQuery) ([]model.User, int64, error) {
+	tx := s.db.Model(&model.User{})
+	if query.Role != "" {
+		tx = tx.Where("role = ?", query.Role)
+	}
+	if query.Email != "" {
+		tx = tx.Where("email LIKE ?", escapeLike(query.Email)+"%")
+	}
+	if query.Name != "" {
+		tx = tx.Where("name LIKE ?", escapeLike(query.Name)+"%")
+	}
+	if query.Disabled != nil {
+		if *query.Disabled {
+			tx = tx.Where("disabled_at IS NOT NULL")
+		} else {
+			tx = tx.Where("disabled_at IS NULL")
+		}
+	}
+	if query.Verified != nil {
+		if *query.Verified {
+			tx = tx.Where("verified_at IS NOT NULL")
+		} else {
+			tx = tx.Where("verified_at IS NULL")
+		}
+	}
+
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("%w: %s", ErrListUsers, err)
+	}
+
+	var users []model.User
+	if err := tx.Order("id").Limit(query.Limit).Offset(query.Offset).Find(&users).Error; err != nil {
+		return nil, 0, fmt.Errorf("%w: %s", ErrListUsers, err)
+	}
+	return users, total, nil
+}
+
+// UpdateUser changes the fields of update that are set and returns the
+// updated user.
+func (s databaseService) UpdateUser(id int, update model.UserUpdate) (model.User, error) {
+	user, err := s.FindUserByID(id)
+	if err != nil {
+		return user, err
+	}
+
+	fields := map[string]interface{}{}
+	if update.Name != nil {
+		fields["name"] = *update.Name
+	}
+	if update.Role != nil {
+		fields["role"] = *update.Role
+	}
+	if len(fields) == 0 {
+		return user, nil
+	}
+
+	if err := s.db.Model(&user).Updates(fields).Error; err != nil {
+		return user, fmt.Errorf("%w: %s", ErrUpdateUser, err)
+	}
+	return user, nil
+}
+
+// SetUserDisabled disables or enables a user. Disabled users keep their
+// original disabling time.
+func (s databaseService) SetUserDisabled(id int, disabled bool) error {
+	if _, err := s.FindUserByID(id); err != nil {
+		return err
+	}
+
+	tx := s.db.Model(&model.User{})
+	if disabled {
+		tx = tx.Where("id = ? AND disabled_at IS NULL", id).Update("disabled_at", time.Now())
+	} else {
+		tx = tx.Where("id = ?", id).Update("disabled_at", nil)
+	}
+	if tx.Error != nil {
+		return fmt.Errorf("%w: %s", ErrUpdateUser, tx.Error)
+	}
+	return nil
+}
+
+// DeleteUser removes a user together with its sessions, refresh tokens,
+// password resets, second factor and external identities.
+func (s databaseService) DeleteUser(id int) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&model.User{}, id)
+		if result.Error != nil {
+			return fmt.Errorf("%w: %s", ErrDeleteUser, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrUserNotFound
+		}
+
+		for _, related := range []interface{}{
+			&model.Session{},
+			&model.RefreshToken{},
+			&model.PasswordReset{},
+			&model.MFAEnrollment{},
+			&model.MFARecoveryCode{},
+			&model.UserIdentity{},
+		} {
+			if err := tx.Where("user_id = ?", id).Delete(related).Error; err != nil {
+				return fmt.Errorf("%w: %s", ErrDeleteUser, err)
+			}
+		}
+		return nil
+	})
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
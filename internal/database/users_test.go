@@ -0,0 +1,218 @@
+package database
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/darolpz/students/internal/model"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func Test_databaseService_ListUsers(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	dialector := mysql.New(mysql.Config{
+		Conn:                      mockDB,
+		DriverName:                "mysql",
+		DSN:                       "sqlmock_db",
+		SkipInitializeWithVersion: true,
+	})
+
+	db, err := gorm.Open(dialector)
+	require.NoError(t, err)
+	s := databaseService{
+		db: db,
+	}
+
+	disabled := false
+	query := model.UserQuery{Offset: 10, Limit: 5, Role: "admin", Email: "john_", Disabled: &disabled}
+	where := "WHERE role = ? AND email LIKE ? AND disabled_at IS NULL"
+
+	tests := []struct {
+		name          string
+		setMock       func(mock sqlmock.Sqlmock)
+		want          []model.User
+		wantTotal     int64
+		expectedError error
+	}{
+		{
+			name: "should_list_users",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM `users` "+where)).
+					WithArgs("admin", `john\_%`).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(11))
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `users` "+where+" ORDER BY id LIMIT 5 OFFSET 10")).
+					WithArgs("admin", `john\_%`).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "role"}).
+						AddRow(11, "John", "john_doe@gmail.com", "admin"))
+			},
+			want:      []model.User{{ID: 11, Name: "John", Email: "john_doe@gmail.com", Role: "admin"}},
+			wantTotal: 11,
+		},
+		{
+			name: "should_return_error_list_users",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM `users` "+where)).
+					WithArgs("admin", `john\_%`).
+					WillReturnError(errors.New("some error"))
+			},
+			expectedError: ErrListUsers,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setMock(mock)
+			got, total, err := s.ListUsers(query)
+			if tt.expectedError != nil {
+				require.Error(t, err)
+				require.True(t, errors.Is(err, tt.expectedError))
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.want, got)
+				require.Equal(t, tt.wantTotal, total)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_databaseService_UpdateUser(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	dialector := mysql.New(mysql.Config{
+		Conn:                      mockDB,
+		DriverName:                "mysql",
+		DSN:                       "sqlmock_db",
+		SkipInitializeWithVersion: true,
+	})
+
+	db, err := gorm.Open(dialector)
+	require.NoError(t, err)
+	s := databaseService{
+		db: db,
+	}
+
+	role := "admin"
+	selectQuery := regexp.QuoteMeta("SELECT * FROM `users` WHERE `users`.`id` = ? ORDER BY `users`.`id` LIMIT 1")
+
+	tests := []struct {
+		name          string
+		setMock       func(mock sqlmock.Sqlmock)
+		want          model.User
+		expectedError error
+	}{
+		{
+			name: "should_update_user",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(selectQuery).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "role"}).
+						AddRow(1, "John", "john.doe@gmail.com", "user"))
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE `users` SET `role`=? WHERE `id` = ?")).
+					WithArgs("admin", 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			want: model.User{ID: 1, Name: "John", Email: "john.doe@gmail.com", Role: "admin"},
+		},
+		{
+			name: "should_return_error_user_not_found",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(selectQuery).
+					WithArgs(1).
+					WillReturnError(gorm.ErrRecordNotFound)
+			},
+			expectedError: ErrUserNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setMock(mock)
+			got, err := s.UpdateUser(1, model.UserUpdate{Role: &role})
+			if tt.expectedError != nil {
+				require.Error(t, err)
+				require.True(t, errors.Is(err, tt.expectedError))
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.want, got)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_databaseService_DeleteUser(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	dialector := mysql.New(mysql.Config{
+		Conn:                      mockDB,
+		DriverName:                "mysql",
+		DSN:                       "sqlmock_db",
+		SkipInitializeWithVersion: true,
+	})
+
+	db, err := gorm.Open(dialector)
+	require.NoError(t, err)
+	s := databaseService{
+		db: db,
+	}
+
+	deleteQuery := regexp.QuoteMeta("DELETE FROM `users` WHERE `users`.`id` = ?")
+
+	tests := []struct {
+		name          string
+		setMock       func(mock sqlmock.Sqlmock)
+		expectedError error
+	}{
+		{
+			name: "should_delete_user",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(deleteQuery).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				for _, table := range []string{"sessions", "refresh_tokens", "password_resets", "mfa_enrollments", "mfa_recovery_codes", "user_identities"} {
+					mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `" + table + "` WHERE user_id = ?")).
+						WithArgs(1).
+						WillReturnResult(sqlmock.NewResult(0, 1))
+				}
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "should_return_error_user_not_found",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(deleteQuery).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+			},
+			expectedError: ErrUserNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setMock(mock)
+			err := s.DeleteUser(1)
+			if tt.expectedError != nil {
+				require.Error(t, err)
+				require.True(t, errors.Is(err, tt.expectedError))
+			} else {
+				require.NoError(t, err)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/darolpz/students/internal/model"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("export format must be csv, ndjson or xlsx")
+	ErrWriteExport       = errors.New("couldn't write export")
+)
+
+// Format is a file format students can be exported in.
+type Format struct {
+	Name        string
+	ContentType string
+	Extension   string
+	newWriter   func(w io.Writer) IWriter
+}
+
+// Formats lists the export formats by name.
+var Formats = map[string]Format{
+	"csv":    {Name: "csv", ContentType: "text/csv; charset=utf-8", Extension: "csv", newWriter: newCSVWriter},
+	"ndjson": {Name: "ndjson", ContentType: "application/x-ndjson", Extension: "ndjson", newWriter: newNDJSONWriter},
+	"xlsx": {
+		Name:        "xlsx",
+		ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		Extension:   "xlsx",
+		newWriter:   newXLSXWriter,
+	},
+}
+
+// columns are the headers of the tabular formats, named like the fields the
+// import reads.
+var columns = []string{"id", "first_name", "last_name", "age", "email"}
+
+// IWriter writes students one at a time as they are read, so that exports
+// never hold more than one student in memory.
+type IWriter interface {
+	Write(student model.Student) error
+	// Close writes whatever the format needs after the last student. The
+	// export is incomplete until it is called.
+	Close() error
+}
+
+// NewWriter returns a writer of students to w in the format named format.
+func NewWriter(w io.Writer, format string) (IWriter, error) {
+	f, ok := Formats[format]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+	return f.newWriter(w), nil
+}
+
+type csvWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+func newCSVWriter(w io.Writer) IWriter {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) Write(student model.Student) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	record := []string{strconv.Itoa(student.ID), EscapeCell(student.FirstName), EscapeCell(student.LastName), strconv.Itoa(student.Age), EscapeCell(student.Email)}
+	if err := c.w.Write(record); err != nil {
+		return fmt.Errorf("%w: %s", ErrWriteExport, err)
+	}
+	return nil
+}
+
+// formulaPrefixes are the characters that make spreadsheets read a CSV cell
+// starting with them as a formula.
+const formulaPrefixes = "=+-@\t\r"
+
+// EscapeCell keeps spreadsheets opening a CSV export from running cell as a
+// formula, by prefixing it with a quote when it starts like one.
+// Spreadsheets read the quote as marking the cell as text.
+func EscapeCell(cell string) string {
+	if cell != "" && strings.ContainsRune(formulaPrefixes, rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+// UnescapeCell undoes EscapeCell, so that exports can be imported back.
+func UnescapeCell(cell string) string {
+	if len(cell) > 1 && cell[0] == '\'' && strings.ContainsRune(formulaPrefixes, rune(cell[1])) {
+		return cell[1:]
+	}
+	return cell
+}
+
+// writeHeader writes the header before the first student, or on Close when
+// there is none.
+func (c *csvWriter) writeHeader() error {
+	if c.header {
+		return nil
+	}
+	c.header = true
+	if err := c.w.Write(columns); err != nil {
+		return fmt.Errorf("%w: %s", ErrWriteExport, err)
+	}
+	return nil
+}
+
+func (c *csvWriter) Close() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.w.Flush()
+	if err := c.w.Error(); err != nil {
+		return fmt.Errorf("%w: %s", ErrWriteExport, err)
+	}
+	return nil
+}
+
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+func newNDJSONWriter(w io.Writer) IWriter {
+	encoder := json.NewEncoder(w)
+	// The export is data, not markup meant for a browser
+	encoder.SetEscapeHTML(false)
+	return ndjsonWriter{encoder: encoder}
+}
+
+func (n ndjsonWriter) Write(student model.Student) error {
+	if err := n.encoder.Encode(student); err != nil {
+		return fmt.Errorf("%w: %s", ErrWriteExport, err)
+	}
+	return nil
+}
+
+func (n ndjsonWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,96 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/darolpz/students/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+var students = []model.Student{
+	{ID: 1, FirstName: "John", LastName: "Smith", Age: 33, Email: "john.doe@gmail.com"},
+	{ID: 2, FirstName: "Ana, Jr", LastName: "O'Brien <&>", Age: 20, Email: "ana@gmail.com"},
+}
+
+func export(t *testing.T, format string, students []model.Student) []byte {
+	var buf bytes.Buffer
+	writer, err := NewWriter(&buf, format)
+	require.NoError(t, err)
+	for _, student := range students {
+		require.NoError(t, writer.Write(student))
+	}
+	require.NoError(t, writer.Close())
+	return buf.Bytes()
+}
+
+func TestNewWriter_CSV(t *testing.T) {
+	require.Equal(t,
+		"id,first_name,last_name,age,email\n"+
+			"1,John,Smith,33,john.doe@gmail.com\n"+
+			"2,\"Ana, Jr\",O'Brien <&>,20,ana@gmail.com\n",
+		string(export(t, "csv", students)))
+
+	require.Equal(t, "id,first_name,last_name,age,email\n", string(export(t, "csv", nil)))
+
+	// Cells starting like formulas are kept as text
+	require.Equal(t,
+		"id,first_name,last_name,age,email\n"+
+			"3,\"'=HYPERLINK(\"\"x\"\")\",'+1,40,'-x@gmail.com\n"+
+			"4,'@SUM(A1),'\tTab,41,d=e@gmail.com\n",
+		string(export(t, "csv", []model.Student{
+			{ID: 3, FirstName: `=HYPERLINK("x")`, LastName: "+1", Age: 40, Email: "-x@gmail.com"},
+			{ID: 4, FirstName: "@SUM(A1)", LastName: "\tTab", Age: 41, Email: "d=e@gmail.com"},
+		})))
+}
+
+func TestUnescapeCell(t *testing.T) {
+	for _, cell := range []string{"=1+2", "+1", "-x", "@SUM(A1)", "'quoted", "'", "John", ""} {
+		require.Equal(t, cell, UnescapeCell(EscapeCell(cell)))
+	}
+}
+
+func TestNewWriter_NDJSON(t *testing.T) {
+	require.Equal(t,
+		`{"id":1,"first_name":"John","last_name":"Smith","age":33,"email":"john.doe@gmail.com"}`+"\n"+
+			`{"id":2,"first_name":"Ana, Jr","last_name":"O'Brien <&>","age":20,"email":"ana@gmail.com"}`+"\n",
+		string(export(t, "ndjson", students)))
+}
+
+func TestNewWriter_XLSX(t *testing.T) {
+	content := export(t, "xlsx", students)
+
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	require.NoError(t, err)
+	var names []string
+	var sheet string
+	for _, f := range archive.File {
+		names = append(names, f.Name)
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			r, err := f.Open()
+			require.NoError(t, err)
+			b, err := io.ReadAll(r)
+			require.NoError(t, err)
+			sheet = string(b)
+		}
+	}
+	require.Equal(t, []string{
+		"[Content_Types].xml",
+		"_rels/.rels",
+		"xl/workbook.xml",
+		"xl/_rels/workbook.xml.rels",
+		"xl/worksheets/sheet1.xml",
+	}, names)
+
+	require.Contains(t, sheet, `<row r="1"><c r="A1" t="inlineStr"><is><t xml:space="preserve">id</t></is></c>`)
+	require.Contains(t, sheet, `<row r="2"><c r="A2"><v>1</v></c><c r="B2" t="inlineStr"><is><t xml:space="preserve">John</t></is></c>`)
+	require.Contains(t, sheet, `<c r="C3" t="inlineStr"><is><t xml:space="preserve">O&#39;Brien &lt;&amp;&gt;</t></is></c><c r="D3"><v>20</v></c>`)
+	require.Contains(t, sheet, `</row></sheetData></worksheet>`)
+}
+
+func TestNewWriter_UnsupportedFormat(t *testing.T) {
+	_, err := NewWriter(io.Discard, "pdf")
+	require.ErrorIs(t, err, ErrUnsupportedFormat)
+}
@@ -0,0 +1,131 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/darolpz/students/internal/model"
+)
+
+// The parts of a workbook with a single worksheet, apart from the worksheet
+// itself.
+var xlsxParts = []struct {
+	name    string
+	content string
+}{
+	{
+		name: "[Content_Types].xml",
+		content: xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`</Types>`,
+	},
+	{
+		name: "_rels/.rels",
+		content: xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`,
+	},
+	{
+		name: "xl/workbook.xml",
+		content: xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+			`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="Students" sheetId="1" r:id="rId1"/></sheets>` +
+			`</workbook>`,
+	},
+	{
+		name: "xl/_rels/workbook.xml.rels",
+		content: xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`</Relationships>`,
+	},
+}
+
+// xlsxWriter writes a workbook whose worksheet is streamed into the zip
+// archive row by row. Strings are stored inline rather than in a shared
+// strings table, which would have to be kept in memory until the end.
+type xlsxWriter struct {
+	zip   *zip.Writer
+	sheet *bufio.Writer
+	row   int
+	err   error
+}
+
+func newXLSXWriter(w io.Writer) IWriter {
+	x := &xlsxWriter{zip: zip.NewWriter(w)}
+	for _, part := range xlsxParts {
+		if x.err != nil {
+			break
+		}
+		var f io.Writer
+		if f, x.err = x.zip.Create(part.name); x.err == nil {
+			_, x.err = io.WriteString(f, part.content)
+		}
+	}
+	if x.err == nil {
+		var f io.Writer
+		if f, x.err = x.zip.Create("xl/worksheets/sheet1.xml"); x.err == nil {
+			x.sheet = bufio.NewWriter(f)
+			x.writeString(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+			x.writeRow(columns[0], columns[1], columns[2], columns[3], columns[4])
+		}
+	}
+	return x
+}
+
+func (x *xlsxWriter) Write(student model.Student) error {
+	x.writeRow(student.ID, student.FirstName, student.LastName, student.Age, student.Email)
+	return x.error()
+}
+
+func (x *xlsxWriter) Close() error {
+	x.writeString(`</sheetData></worksheet>`)
+	if x.err == nil {
+		x.err = x.sheet.Flush()
+	}
+	if x.err == nil {
+		x.err = x.zip.Close()
+	}
+	return x.error()
+}
+
+// writeRow writes the next row of the worksheet with a cell per value,
+// which is either an int or a string.
+func (x *xlsxWriter) writeRow(values ...interface{}) {
+	x.row++
+	x.writeString(fmt.Sprintf(`<row r="%d">`, x.row))
+	for i, value := range values {
+		ref := string(rune('A'+i)) + strconv.Itoa(x.row)
+		switch value := value.(type) {
+		case int:
+			x.writeString(fmt.Sprintf(`<c r="%s"><v>%d</v></c>`, ref, value))
+		case string:
+			x.writeString(fmt.Sprintf(`<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref))
+			if x.err == nil {
+				x.err = xml.EscapeText(x.sheet, []byte(value))
+			}
+			x.writeString(`</t></is></c>`)
+		}
+	}
+	x.writeString(`</row>`)
+}
+
+// writeString writes s to the worksheet unless a previous write failed.
+func (x *xlsxWriter) writeString(s string) {
+	if x.err == nil {
+		_, x.err = x.sheet.WriteString(s)
+	}
+}
+
+func (x *xlsxWriter) error() error {
+	if x.err != nil {
+		return fmt.Errorf("%w: %s", ErrWriteExport, x.err)
+	}
+	return nil
+}
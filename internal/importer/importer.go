@@ -0,0 +1,232 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/darolpz/students/internal/export"
+	"github.com/darolpz/students/internal/model"
+	"github.com/darolpz/students/internal/validation"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("import must be text/csv or application/x-ndjson")
+	ErrInvalidMapping    = errors.New("header mapping must map to first_name, last_name, age or email")
+	ErrMissingColumns    = errors.New("import is missing columns")
+	ErrTooManyRows       = errors.New("import has too many rows")
+	ErrReadImport        = errors.New("couldn't read import")
+)
+
+// Formats of an import, by media type.
+const (
+	FormatCSV    = "text/csv"
+	FormatNDJSON = "application/x-ndjson"
+)
+
+// MaxRows is the most students a single import may hold.
+const MaxRows = 5000
+
+// Fields are the student fields an import fills, which are all required.
+var Fields = []string{"first_name", "last_name", "age", "email"}
+
+// Row is a student read from an import. Line is where the row starts in the
+// file, and Errors lists why the row is invalid, if it is.
+type Row struct {
+	Line    int
+	Student model.Student
+	Errors  []string
+}
+
+// Read parses an import in format. Headers, or NDJSON keys, name the field
+// of their column, spelt in any case and with spaces or dashes instead of
+// underscores, unless mapping maps them to another field. Rows that do not
+// form a valid student are returned with their errors, while a malformed
+// file fails as a whole.
+func Read(r io.Reader, format string, mapping map[string]string) ([]Row, error) {
+	fields := map[string]string{}
+	for header, field := range mapping {
+		field = normalize(field)
+		if !isField(field) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMapping, field)
+		}
+		fields[normalize(header)] = field
+	}
+
+	switch format {
+	case FormatCSV:
+		return readCSV(r, fields)
+	case FormatNDJSON:
+		return readNDJSON(r, fields)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+func readCSV(r io.Reader, mapping map[string]string) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrReadImport, err)
+	}
+	// Spreadsheets often start UTF-8 files with a byte order mark
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+
+	columns := map[string]int{}
+	for i, name := range header {
+		if field := fieldOf(name, mapping); field != "" {
+			columns[field] = i
+		}
+	}
+	var missing []string
+	for _, field := range Fields {
+		if _, ok := columns[field]; !ok {
+			missing = append(missing, field)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrMissingColumns, strings.Join(missing, ", "))
+	}
+
+	var rows []Row
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrReadImport, err)
+		}
+		if len(rows) == MaxRows {
+			return nil, fmt.Errorf("%w: at most %d", ErrTooManyRows, MaxRows)
+		}
+
+		line, _ := reader.FieldPos(0)
+		values := map[string]string{}
+		for field, column := range columns {
+			if column < len(record) {
+				values[field] = export.UnescapeCell(record[column])
+			}
+		}
+		rows = append(rows, parseRow(line, values))
+	}
+	return rows, nil
+}
+
+func readNDJSON(r io.Reader, mapping map[string]string) ([]Row, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var rows []Row
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		if len(rows) == MaxRows {
+			return nil, fmt.Errorf("%w: at most %d", ErrTooManyRows, MaxRows)
+		}
+
+		var object map[string]interface{}
+		decoder := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		decoder.UseNumber()
+		if err := decoder.Decode(&object); err != nil {
+			rows = append(rows, Row{Line: line, Errors: []string{"must be a JSON object"}})
+			continue
+		}
+
+		values := map[string]string{}
+		var errs []string
+		for key, value := range object {
+			field := fieldOf(key, mapping)
+			if field == "" {
+				continue
+			}
+			switch value := value.(type) {
+			case string:
+				values[field] = value
+			case json.Number:
+				values[field] = value.String()
+			case nil:
+			default:
+				errs = append(errs, field+": must be a string or a number")
+			}
+		}
+		row := parseRow(line, values)
+		row.Errors = append(errs, row.Errors...)
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrReadImport, err)
+	}
+	return rows, nil
+}
+
+// parseRow builds the student of a row from the values of its fields and
+// checks it against the rules of students, listing the errors by field.
+func parseRow(line int, values map[string]string) Row {
+	row := Row{Line: line}
+	row.Student.FirstName = strings.TrimSpace(values["first_name"])
+	row.Student.LastName = strings.TrimSpace(values["last_name"])
+	row.Student.Email = strings.TrimSpace(values["email"])
+
+	problems := map[string][]string{}
+	if age := strings.TrimSpace(values["age"]); age == "" {
+		problems["age"] = []string{"is required"}
+	} else if n, err := strconv.Atoi(age); err != nil {
+		problems["age"] = []string{"must be a whole number"}
+	} else {
+		row.Student.Age = n
+	}
+
+	err := validation.Struct(row.Student)
+	var fields validation.Errors
+	if errors.As(err, &fields) {
+		for _, f := range fields {
+			problems[f.Field] = append(problems[f.Field], f.Message)
+		}
+	} else if err != nil {
+		row.Errors = append(row.Errors, err.Error())
+	}
+	for _, field := range Fields {
+		for _, problem := range problems[field] {
+			row.Errors = append(row.Errors, field+": "+problem)
+		}
+	}
+	return row
+}
+
+// fieldOf returns the field a header stands for, or an empty string when it
+// is none.
+func fieldOf(header string, mapping map[string]string) string {
+	header = normalize(header)
+	if field, ok := mapping[header]; ok {
+		return field
+	}
+	if isField(header) {
+		return header
+	}
+	return ""
+}
+
+func normalize(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(name)
+}
+
+func isField(name string) bool {
+	for _, field := range Fields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,355 @@
+package importer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/darolpz/students/internal/model"
+	"github.com/darolpz/students/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name          string
+		format        string
+		mapping       map[string]string
+		input         string
+		want          []Row
+		expectedError error
+	}{
+		{
+			name:   "should_read_csv",
+			format: FormatCSV,
+			input: "\ufeffFirst Name,last-name,AGE,email,notes\n" +
+				"John,Smith,33,john.doe@gmail.com,\"multi\nline\"\n" +
+				"Ana,Perez,20,ana@gmail.com,\n",
+			want: []Row{
+				{Line: 2, Student: model.Student{FirstName: "John", LastName: "Smith", Age: 33, Email: "john.doe@gmail.com"}},
+				{Line: 4, Student: model.Student{FirstName: "Ana", LastName: "Perez", Age: 20, Email: "ana@gmail.com"}},
+			},
+		},
+		{
+			name:    "should_map_headers",
+			format:  FormatCSV,
+			mapping: map[string]string{"Given Name": "first_name", "surname": "Last Name", "mail": "email"},
+			input:   "given name,surname,age,mail\nJohn,Smith,33,john.doe@gmail.com\n",
+			want: []Row{
+				{Line: 2, Student: model.Student{FirstName: "John", LastName: "Smith", Age: 33, Email: "john.doe@gmail.com"}},
+			},
+		},
+		{
+			name:   "should_read_exported_csv",
+			format: FormatCSV,
+			input:  "id,first_name,last_name,age,email\n1,'=John,'quoted,33,'-john@gmail.com\n",
+			want: []Row{
+				{Line: 2, Student: model.Student{FirstName: "=John", LastName: "'quoted", Age: 33, Email: "-john@gmail.com"}},
+			},
+		},
+		{
+			name:   "should_report_invalid_rows",
+			format: FormatCSV,
+			input: "first_name,last_name,age,email\n" +
+				",Smithsonian Institution Smith,-1,john.doe\n" +
+				"John,Smith\n",
+			want: []Row{
+				{Line: 2, Student: model.Student{LastName: "Smithsonian Institution Smith", Age: -1, Email: "john.doe"}, Errors: []string{
+					"first_name: is required",
+					"last_name: must be at most 20 characters",
+					"age: must be at least 0",
+					"email: must be an email address",
+				}},
+				{Line: 3, Student: model.Student{FirstName: "John", LastName: "Smith"}, Errors: []string{
+					"age: is required",
+					"email: is required",
+				}},
+			},
+		},
+		{
+			name:          "should_return_error_missing_columns",
+			format:        FormatCSV,
+			input:         "first_name,last_name\nJohn,Smith\n",
+			expectedError: ErrMissingColumns,
+		},
+		{
+			name:          "should_return_error_invalid_mapping",
+			format:        FormatCSV,
+			mapping:       map[string]string{"name": "full_name"},
+			input:         "first_name,last_name,age,email\n",
+			expectedError: ErrInvalidMapping,
+		},
+		{
+			name:   "should_read_ndjson",
+			format: FormatNDJSON,
+			input: `{"first_name":"John","last_name":"Smith","age":33,"email":"john.doe@gmail.com"}` + "\n\n" +
+				`{"first_name":"Ana","last_name":"Perez","age":"20","email":"ana@gmail.com","notes":[1]}` + "\n" +
+				`[1]` + "\n" +
+				`{"first_name":["Ana"],"last_name":"Perez","age":20.5,"email":"ana@gmail.com"}`,
+			want: []Row{
+				{Line: 1, Student: model.Student{FirstName: "John", LastName: "Smith", Age: 33, Email: "john.doe@gmail.com"}},
+				{Line: 3, Student: model.Student{FirstName: "Ana", LastName: "Perez", Age: 20, Email: "ana@gmail.com"}},
+				{Line: 4, Errors: []string{"must be a JSON object"}},
+				{Line: 5, Student: model.Student{LastName: "Perez", Email: "ana@gmail.com"}, Errors: []string{
+					"first_name: must be a string or a number",
+					"first_name: is required",
+					"age: must be a whole number",
+				}},
+			},
+		},
+		{
+			name:          "should_return_error_unsupported_format",
+			format:        "application/json",
+			expectedError: ErrUnsupportedFormat,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Read(strings.NewReader(tt.input), tt.format, tt.mapping)
+			if tt.expectedError != nil {
+				require.ErrorIs(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// fakeStudents stands in for the students repository, failing to save the
+// students with the email in fail, with failErr when it is set, and to find
+// any with findErr.
+type fakeStudents struct {
+	repository.IStudentsRepository
+	existing []model.Student
+	findErr  error
+	fail     string
+	failErr  error
+	saved    [][]model.Student
+	nextID   int
+}
+
+func (f *fakeStudents) FindStudentsByEmail(emails []string) ([]model.Student, error) {
+	return f.existing, f.findErr
+}
+
+func (f *fakeStudents) SaveStudents(students []model.Student, changedBy *int) ([]model.Student, error) {
+	for i, student := range students {
+		if student.Email == f.fail {
+			if f.failErr != nil {
+				return nil, repository.SaveStudentsError{Index: i, Err: f.failErr}
+			}
+			return nil, errors.New("couldn't save students: " + student.Email)
+		}
+	}
+	saved := make([]model.Student, len(students))
+	for i, student := range students {
+		if student.ID == 0 {
+			f.nextID++
+			student.ID = f.nextID
+		}
+		saved[i] = student
+	}
+	f.saved = append(f.saved, saved)
+	return saved, nil
+}
+
+func TestImport(t *testing.T) {
+	rows := []Row{
+		{Line: 2, Student: model.Student{FirstName: "John", LastName: "Smith", Age: 34, Email: "John.Doe@gmail.com"}},
+		{Line: 3, Student: model.Student{FirstName: "Ana", LastName: "Perez", Age: 20, Email: "ana@gmail.com"}},
+		{Line: 4, Errors: []string{"email: is required"}},
+		{Line: 5, Student: model.Student{FirstName: "Ana", LastName: "Lopez", Age: 21, Email: "ANA@gmail.com"}},
+	}
+	existing := []model.Student{{ID: 1, FirstName: "John", LastName: "Doe", Age: 33, Email: "john.doe@gmail.com"}}
+
+	tests := []struct {
+		name      string
+		options   Options
+		fail      string
+		failErr   error
+		want      Report
+		wantSaved [][]model.Student
+	}{
+		{
+			name:    "should_report_dry_run",
+			options: Options{DryRun: true},
+			want: Report{DryRun: true, Created: 1, Updated: 1, Failed: 2, Rows: []RowResult{
+				{Line: 2, Email: "John.Doe@gmail.com", Action: ActionUpdate, ID: 1},
+				{Line: 3, Email: "ana@gmail.com", Action: ActionCreate},
+				{Line: 4, Errors: []string{"email: is required"}},
+				{Line: 5, Email: "ANA@gmail.com", Errors: []string{"email: repeats line 3"}},
+			}},
+		},
+		{
+			name: "should_import_valid_rows",
+			want: Report{Applied: true, Created: 1, Updated: 1, Failed: 2, Rows: []RowResult{
+				{Line: 2, Email: "John.Doe@gmail.com", Action: ActionUpdate, ID: 1},
+				{Line: 3, Email: "ana@gmail.com", Action: ActionCreate, ID: 2},
+				{Line: 4, Errors: []string{"email: is required"}},
+				{Line: 5, Email: "ANA@gmail.com", Errors: []string{"email: repeats line 3"}},
+			}},
+			wantSaved: [][]model.Student{
+				{{ID: 1, FirstName: "John", LastName: "Smith", Age: 34, Email: "John.Doe@gmail.com"}},
+				{{ID: 2, FirstName: "Ana", LastName: "Perez", Age: 20, Email: "ana@gmail.com"}},
+			},
+		},
+		{
+			name: "should_report_rows_failing_to_save",
+			fail: "ana@gmail.com",
+			want: Report{Applied: true, Updated: 1, Failed: 3, Rows: []RowResult{
+				{Line: 2, Email: "John.Doe@gmail.com", Action: ActionUpdate, ID: 1},
+				{Line: 3, Email: "ana@gmail.com", Errors: []string{"couldn't save students: ana@gmail.com"}},
+				{Line: 4, Errors: []string{"email: is required"}},
+				{Line: 5, Email: "ANA@gmail.com", Errors: []string{"email: repeats line 3"}},
+			}},
+			wantSaved: [][]model.Student{
+				{{ID: 1, FirstName: "John", LastName: "Smith", Age: 34, Email: "John.Doe@gmail.com"}},
+			},
+		},
+		{
+			name:    "should_report_rows_with_taken_email",
+			fail:    "ana@gmail.com",
+			failErr: repository.ErrDuplicateEmail,
+			want: Report{Applied: true, Updated: 1, Failed: 3, Rows: []RowResult{
+				{Line: 2, Email: "John.Doe@gmail.com", Action: ActionUpdate, ID: 1},
+				{Line: 3, Email: "ana@gmail.com", Errors: []string{"email: already belongs to another student"}},
+				{Line: 4, Errors: []string{"email: is required"}},
+				{Line: 5, Email: "ANA@gmail.com", Errors: []string{"email: repeats line 3"}},
+			}},
+			wantSaved: [][]model.Student{
+				{{ID: 1, FirstName: "John", LastName: "Smith", Age: 34, Email: "John.Doe@gmail.com"}},
+			},
+		},
+		{
+			name:    "should_not_apply_atomic_import_with_errors",
+			options: Options{Atomic: true},
+			want: Report{Atomic: true, Created: 1, Updated: 1, Failed: 2, Rows: []RowResult{
+				{Line: 2, Email: "John.Doe@gmail.com", Action: ActionUpdate, ID: 1},
+				{Line: 3, Email: "ana@gmail.com", Action: ActionCreate},
+				{Line: 4, Errors: []string{"email: is required"}},
+				{Line: 5, Email: "ANA@gmail.com", Errors: []string{"email: repeats line 3"}},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			students := &fakeStudents{existing: existing, fail: tt.fail, failErr: tt.failErr, nextID: 1}
+			got, err := Import(students, rows, tt.options, nil)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+			require.Equal(t, tt.wantSaved, students.saved)
+		})
+	}
+}
+
+func TestImport_Atomic(t *testing.T) {
+	rows := []Row{
+		{Line: 2, Student: model.Student{FirstName: "John", LastName: "Smith", Age: 34, Email: "john.doe@gmail.com"}},
+		{Line: 3, Student: model.Student{FirstName: "Ana", LastName: "Perez", Age: 20, Email: "ana@gmail.com"}},
+	}
+
+	students := &fakeStudents{existing: []model.Student{{ID: 1, Email: "john.doe@gmail.com"}}, nextID: 1}
+	got, err := Import(students, rows, Options{Atomic: true}, nil)
+	require.NoError(t, err)
+	require.Equal(t, Report{Atomic: true, Applied: true, Created: 1, Updated: 1, Rows: []RowResult{
+		{Line: 2, Email: "john.doe@gmail.com", Action: ActionUpdate, ID: 1},
+		{Line: 3, Email: "ana@gmail.com", Action: ActionCreate, ID: 2},
+	}}, got)
+	require.Len(t, students.saved, 1)
+
+	// A failure to save is reported along with the rows
+	students = &fakeStudents{fail: "ana@gmail.com"}
+	got, err = Import(students, rows, Options{Atomic: true}, nil)
+	require.Error(t, err)
+	require.Equal(t, Report{Atomic: true, Created: 2, Error: "couldn't save students: ana@gmail.com", Rows: []RowResult{
+		{Line: 2, Email: "john.doe@gmail.com", Action: ActionCreate},
+		{Line: 3, Email: "ana@gmail.com", Action: ActionCreate},
+	}}, got)
+	require.Empty(t, students.saved)
+
+	// An email taken meanwhile fails its row
+	students = &fakeStudents{fail: "ana@gmail.com", failErr: repository.ErrDuplicateEmail}
+	got, err = Import(students, rows, Options{Atomic: true}, nil)
+	require.NoError(t, err)
+	require.Equal(t, Report{Atomic: true, Created: 1, Failed: 1, Rows: []RowResult{
+		{Line: 2, Email: "john.doe@gmail.com", Action: ActionCreate},
+		{Line: 3, Email: "ana@gmail.com", Errors: []string{"email: already belongs to another student"}},
+	}}, got)
+	require.Empty(t, students.saved)
+}
+
+func TestImport_FindError(t *testing.T) {
+	rows := []Row{
+		{Line: 2, Student: model.Student{FirstName: "John", LastName: "Smith", Age: 34, Email: "john.doe@gmail.com"}},
+		{Line: 3, Errors: []string{"email: is required"}},
+	}
+
+	students := &fakeStudents{findErr: errors.New("couldn't find student")}
+	got, err := Import(students, rows, Options{}, nil)
+	require.Error(t, err)
+	require.Equal(t, Report{Failed: 1, Error: "couldn't find student", Rows: []RowResult{
+		{Line: 2, Email: "john.doe@gmail.com"},
+		{Line: 3, Errors: []string{"email: is required"}},
+	}}, got)
+	require.Empty(t, students.saved)
+}
+
+func TestImport_ActiveStudentFirst(t *testing.T) {
+	deletedAt := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+	rows := []Row{
+		{Line: 2, Student: model.Student{FirstName: "John", LastName: "Smith", Age: 34, Email: "john.doe@gmail.com"}},
+	}
+
+	// A trashed student keeps its email, which an active student can reuse
+	students := &fakeStudents{existing: []model.Student{
+		{ID: 1, Email: "john.doe@gmail.com"},
+		{ID: 5, Email: "John.Doe@gmail.com", DeletedAt: &deletedAt},
+	}}
+	got, err := Import(students, rows, Options{DryRun: true}, nil)
+	require.NoError(t, err)
+	require.Equal(t, []RowResult{{Line: 2, Email: "john.doe@gmail.com", Action: ActionUpdate, ID: 1}}, got.Rows)
+}
+
+func TestImport_DeletedStudent(t *testing.T) {
+	deletedAt := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+	rows := []Row{
+		{Line: 2, Student: model.Student{FirstName: "John", LastName: "Smith", Age: 34, Email: "john.doe@gmail.com"}},
+		{Line: 3, Student: model.Student{FirstName: "Ana", LastName: "Perez", Age: 20, Email: "ana@gmail.com"}},
+	}
+	existing := []model.Student{{ID: 5, Email: "John.Doe@gmail.com", DeletedAt: &deletedAt}}
+
+	tests := []struct {
+		name      string
+		options   Options
+		want      Report
+		wantSaved [][]model.Student
+	}{
+		{
+			name: "should_skip_deleted_student",
+			want: Report{Applied: true, Created: 1, Failed: 1, Rows: []RowResult{
+				{Line: 2, Email: "john.doe@gmail.com", Errors: []string{errEmailDeleted}},
+				{Line: 3, Email: "ana@gmail.com", Action: ActionCreate, ID: 1},
+			}},
+			wantSaved: [][]model.Student{{{ID: 1, FirstName: "Ana", LastName: "Perez", Age: 20, Email: "ana@gmail.com"}}},
+		},
+		{
+			name:    "should_abort_atomic_import",
+			options: Options{Atomic: true},
+			want: Report{Atomic: true, Created: 1, Failed: 1, Rows: []RowResult{
+				{Line: 2, Email: "john.doe@gmail.com", Errors: []string{errEmailDeleted}},
+				{Line: 3, Email: "ana@gmail.com", Action: ActionCreate},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			students := &fakeStudents{existing: existing}
+			got, err := Import(students, rows, tt.options, nil)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+			require.Equal(t, tt.wantSaved, students.saved)
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/darolpz/students/internal/model"
+	"github.com/darolpz/students/internal/repository"
+)
+
+// Actions an import takes on a row.
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+)
+
+// Options of an import. A dry run only reports what the import would do. An
+// atomic import writes every row or, when any row fails, none of them.
+type Options struct {
+	DryRun bool `form:"dry_run"`
+	Atomic bool `form:"atomic"`
+}
+
+// RowResult is what an import did with a row. Rows with errors have no
+// action.
+type RowResult struct {
+	Line   int      `json:"line"`
+	Email  string   `json:"email,omitempty"`
+	Action string   `json:"action,omitempty"`
+	ID     int      `json:"id,omitempty"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Report is the outcome of an import. Applied tells whether any of it was
+// written; the counts are of the rows that were, or would have been when it
+// was not, created, updated or rejected. Error is why the import stopped,
+// when it could not go through all of its rows.
+type Report struct {
+	DryRun  bool        `json:"dry_run"`
+	Atomic  bool        `json:"atomic"`
+	Applied bool        `json:"applied"`
+	Created int         `json:"created"`
+	Updated int         `json:"updated"`
+	Failed  int         `json:"failed"`
+	Error   string      `json:"error,omitempty"`
+	Rows    []RowResult `json:"rows"`
+}
+
+// Errors of rows that cannot be saved for their email: another student took
+// it while the import ran, or it belongs to a deleted student, which an
+// import must not restore.
+const (
+	errEmailTaken   = "email: already belongs to another student"
+	errEmailDeleted = "email: belongs to a deleted student, which must be restored first"
+)
+
+// Import upserts the students of rows by email: students whose email is
+// already taken replace the student that has it, and the others are created.
+// Rows with errors, repeating the email of an earlier row or with the email
+// of a deleted student are skipped, or abort an atomic import. changedBy
+// is recorded in the history of the students saved. When the import fails
+// it still returns the report of the rows, with the failure in its Error.
+func Import(students repository.IStudentsRepository, rows []Row, options Options, changedBy *int) (Report, error) {
+	report := Report{DryRun: options.DryRun, Atomic: options.Atomic, Rows: make([]RowResult, len(rows))}
+
+	// Emails are compared the way the unique index of active emails does
+	lines := map[string]int{}
+	var emails []string
+	invalid := 0
+	for i, row := range rows {
+		result := RowResult{Line: row.Line, Email: row.Student.Email, Errors: row.Errors}
+		if len(row.Errors) == 0 {
+			email := strings.ToLower(row.Student.Email)
+			if line, ok := lines[email]; ok {
+				result.Errors = []string{fmt.Sprintf("email: repeats line %d", line)}
+			} else {
+				lines[email] = row.Line
+				emails = append(emails, row.Student.Email)
+			}
+		}
+		if len(result.Errors) > 0 {
+			invalid++
+		}
+		report.Rows[i] = result
+	}
+
+	existing, err := students.FindStudentsByEmail(emails)
+	if err != nil {
+		return fail(report, err)
+	}
+	ids := map[string]int{}
+	deleted := map[string]bool{}
+	for _, student := range existing {
+		// Trashed students are only told apart, as restoring them is not up to
+		// an import, and active ones with their email take precedence
+		email := strings.ToLower(student.Email)
+		if student.DeletedAt != nil {
+			deleted[email] = true
+			continue
+		}
+		ids[email] = student.ID
+	}
+
+	var pending []model.Student
+	var indexes []int
+	for i := range report.Rows {
+		result := &report.Rows[i]
+		if len(result.Errors) > 0 {
+			continue
+		}
+		student := rows[i].Student
+		email := strings.ToLower(student.Email)
+		student.ID = ids[email]
+		if student.ID == 0 && deleted[email] {
+			result.Errors = []string{errEmailDeleted}
+			invalid++
+			continue
+		}
+		if student.ID == 0 {
+			result.Action = ActionCreate
+		} else {
+			result.Action = ActionUpdate
+			result.ID = student.ID
+		}
+		pending = append(pending, student)
+		indexes = append(indexes, i)
+	}
+
+	if options.DryRun || len(pending) == 0 || (options.Atomic && invalid > 0) {
+		count(&report)
+		return report, nil
+	}
+
+	if options.Atomic {
+		saved, err := students.SaveStudents(pending, changedBy)
+		if err != nil {
+			// A taken email fails its row, and with it the import, as any
+			// invalid row would
+			var saveErr repository.SaveStudentsError
+			if !errors.Is(err, repository.ErrDuplicateEmail) || !errors.As(err, &saveErr) {
+				return fail(report, err)
+			}
+			rejectRow(&report.Rows[indexes[saveErr.Index]], err)
+			count(&report)
+			return report, nil
+		}
+		for j, student := range saved {
+			report.Rows[indexes[j]].ID = student.ID
+		}
+	} else {
+		for j, student := range pending {
+			saved, err := students.SaveStudents([]model.Student{student}, changedBy)
+			if err != nil {
+				rejectRow(&report.Rows[indexes[j]], err)
+				continue
+			}
+			report.Rows[indexes[j]].ID = saved[0].ID
+		}
+	}
+	report.Applied = true
+	count(&report)
+	return report, nil
+}
+
+// rejectRow records err, the failure to save the student of result.
+func rejectRow(result *RowResult, err error) {
+	result.Action = ""
+	if errors.Is(err, repository.ErrDuplicateEmail) {
+		result.Errors = []string{errEmailTaken}
+		return
+	}
+	result.Errors = []string{err.Error()}
+}
+
+// fail returns report, which nothing of was written, along with err.
+func fail(report Report, err error) (Report, error) {
+	report.Error = err.Error()
+	count(&report)
+	return report, err
+}
+
+// count tallies the actions and the failures of the rows of report.
+func count(report *Report) {
+	for _, result := range report.Rows {
+		if len(result.Errors) > 0 {
+			report.Failed++
+		}
+		switch result.Action {
+		case ActionCreate:
+			report.Created++
+		case ActionUpdate:
+			report.Updated++
+		}
+	}
+}
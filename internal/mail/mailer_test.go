@@ -0,0 +1,28 @@
+package mail
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutboxMailer_Send(t *testing.T) {
+	dir := t.TempDir()
+	mailer := NewOutboxMailer(dir, "students@localhost")
+
+	err := mailer.Send(Message{To: "john.doe@gmail.com", Subject: "Hello", Body: "line 1\nline 2"})
+	require.NoError(t, err)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	content, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	require.Contains(t, string(content), "From: students@localhost\r\n")
+	require.Contains(t, string(content), "To: john.doe@gmail.com\r\n")
+	require.Contains(t, string(content), "Subject: Hello\r\n")
+	require.Contains(t, string(content), "\r\n\r\nline 1\r\nline 2")
+}
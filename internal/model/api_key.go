@@ -0,0 +1,36 @@
+package model
+
+import "time"
+
+// APIKey is a credential for services and batch jobs. Only the hash of the
+// key is stored; Prefix keeps its first characters so admins can tell keys
+// apart. Scopes is the space separated list of permissions the key grants.
+type APIKey struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Scopes     string     `json:"scopes"`
+	CreatedBy  int        `json:"created_by"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// NewAPIKey model info
+// @Description NewAPIKey information
+// @Description with the name, scopes and optional expiry of a key
+type NewAPIKey struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// CreatedAPIKey model info
+// @Description CreatedAPIKey information
+// @Description with the key itself, which is only shown once
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
@@ -7,3 +7,57 @@ type Authentication struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
 }
+
+// TokenPair model info
+// @Description access and refresh tokens
+// @Description returned by login and refresh
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Refresh model info
+// @Description Refresh information
+// @Description with the refresh token to exchange
+type Refresh struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// ForgotPassword model info
+// @Description ForgotPassword information
+// @Description with the email of the account to recover
+type ForgotPassword struct {
+	Email string `json:"email"`
+}
+
+// ResetPassword model info
+// @Description ResetPassword information
+// @Description with the reset token and the new password
+type ResetPassword struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// ResendVerification model info
+// @Description ResendVerification information
+// @Description with the email address to verify
+type ResendVerification struct {
+	Email string `json:"email"`
+}
+
+// ChangePassword model info
+// @Description ChangePassword information
+// @Description with the current and the new password
+type ChangePassword struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// Registration model info
+// @Description Registration information
+// @Description with name, email and password of a new account
+type Registration struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
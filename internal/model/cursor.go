@@ -0,0 +1,76 @@
+package model
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is a position in a sorted listing: the sort it was taken from and
+// the sort key of the row it points at. Clients get it encoded and must not
+// rely on its contents.
+type Cursor struct {
+	Sort   string        `json:"s"`
+	Values []interface{} `json:"v"`
+}
+
+// Encode returns the opaque form of the cursor.
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a cursor returned by Encode. Numbers are decoded as
+// int64, as every numeric sort key is an integer.
+func DecodeCursor(value string) (Cursor, error) {
+	var cursor Cursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor, fmt.Errorf("%w: %s", ErrInvalidCursor, err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&cursor); err != nil {
+		return cursor, fmt.Errorf("%w: %s", ErrInvalidCursor, err)
+	}
+	for i, v := range cursor.Values {
+		switch v := v.(type) {
+		case json.Number:
+			n, err := v.Int64()
+			if err != nil {
+				return cursor, fmt.Errorf("%w: %s", ErrInvalidCursor, err)
+			}
+			cursor.Values[i] = n
+		case string:
+		default:
+			return cursor, fmt.Errorf("%w: unexpected value %v", ErrInvalidCursor, v)
+		}
+	}
+	return cursor, nil
+}
+
+// Keyset is where a keyset paginated listing starts: right after the row
+// with sort key Values or, when Backward is set, right before it. Nil Values
+// start at the first row.
+type Keyset struct {
+	Sort     []SortField
+	Values   []interface{}
+	Backward bool
+}
+
+// FormatSort is the inverse of ParseSort, with columns as field names.
+func FormatSort(fields []SortField) string {
+	items := make([]string, len(fields))
+	for i, field := range fields {
+		items[i] = field.Column
+		if field.Desc {
+			items[i] = "-" + field.Column
+		}
+	}
+	return strings.Join(items, ",")
+}
@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// Invitation lets an admin open an account with a given role to a single
+// email address. Only the hash of the invitation token is stored.
+type Invitation struct {
+	ID        int        `json:"id"`
+	Email     string     `json:"email"`
+	Role      string     `json:"role"`
+	TokenHash string     `json:"-"`
+	CreatedBy int        `json:"created_by"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// NewInvitation model info
+// @Description NewInvitation information
+// @Description with the email, role and optional expiry of an invitation
+type NewInvitation struct {
+	Email     string     `json:"email"`
+	Role      string     `json:"role"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// CreatedInvitation model info
+// @Description CreatedInvitation information
+// @Description with the invitation token, which is only shown once
+type CreatedInvitation struct {
+	Invitation
+	Token string `json:"token"`
+}
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// LoginThrottle counts the recent failed logins for one key. ID is either
+// "email:<hash of the address>", "ip:<address>" or "mfa:<user id>", so
+// accounts and clients are throttled independently.
+type LoginThrottle struct {
+	ID            string    `json:"id"`
+	Failures      int       `json:"failures"`
+	LastFailureAt time.Time `json:"last_failure_at"`
+}
@@ -0,0 +1,66 @@
+package model
+
+import "time"
+
+// MFAEnrollment holds the TOTP secret of a user. It is pending until the user
+// proves the authenticator works, at which point EnabledAt is set.
+// LastUsedStep is the most recent accepted TOTP time step, so that a code
+// cannot be replayed.
+type MFAEnrollment struct {
+	ID           int        `json:"id"`
+	UserID       int        `json:"user_id"`
+	Secret       string     `json:"-"`
+	EnabledAt    *time.Time `json:"enabled_at"`
+	LastUsedStep int64      `json:"-"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// MFARecoveryCode is a hashed single use code that replaces a TOTP code when
+// the authenticator is lost.
+type MFARecoveryCode struct {
+	ID       int        `json:"id"`
+	UserID   int        `json:"user_id"`
+	CodeHash string     `json:"-"`
+	UsedAt   *time.Time `json:"used_at"`
+}
+
+// MFAChallenge model info
+// @Description MFAChallenge information
+// @Description returned by login when a second factor is needed
+type MFAChallenge struct {
+	MFARequired           bool   `json:"mfa_required,omitempty"`
+	MFAEnrollmentRequired bool   `json:"mfa_enrollment_required,omitempty"`
+	MFAToken              string `json:"mfa_token"`
+}
+
+// MFAVerification model info
+// @Description MFAVerification information
+// @Description with the challenge token and either a TOTP or a recovery code
+type MFAVerification struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// MFACode model info
+// @Description MFACode information
+// @Description with a TOTP code
+type MFACode struct {
+	Code string `json:"code"`
+}
+
+// MFASetup model info
+// @Description MFASetup information
+// @Description with the secret to add to an authenticator app
+type MFASetup struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// MFAActivation model info
+// @Description MFAActivation information
+// @Description with the recovery codes, and tokens when it completed a login
+type MFAActivation struct {
+	RecoveryCodes []string   `json:"recovery_codes"`
+	Tokens        *TokenPair `json:"tokens,omitempty"`
+}
@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// PasswordReset is a single use token allowing a user to choose a new
+// password. Only the hash of the token is stored.
+type PasswordReset struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"user_id"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
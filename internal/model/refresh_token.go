@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// RefreshToken is the server side record of an issued refresh token.
+// Tokens issued from the same login share a FamilyID, so that reusing an
+// already rotated token can revoke every descendant of it.
+type RefreshToken struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"user_id"`
+	FamilyID  string     `json:"family_id"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RotatedAt *time.Time `json:"rotated_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
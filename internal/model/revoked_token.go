@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// RevokedToken is an entry of the access token revocation list. An entry
+// revokes the single token identified by JTI, every token of the session
+// SessionID, or, when IssuedBefore is set, every token of UserID issued
+// before that instant. Entries are only needed until the tokens they revoke
+// would have expired anyway.
+type RevokedToken struct {
+	ID           int        `json:"id"`
+	JTI          string     `json:"jti"`
+	SessionID    string     `json:"session_id"`
+	UserID       int        `json:"user_id"`
+	IssuedBefore *time.Time `json:"issued_before"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// Session is a login of a user on a device. Its ID is the family of the
+// refresh tokens issued to the device and the sid claim of its access
+// tokens. IP is the address the device logged in from, as forwarded by a
+// trusted proxy when there is one. Current is only set when listing the
+// sessions of the caller.
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     int       `json:"user_id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current" gorm:"-"`
+}
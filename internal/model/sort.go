@@ -0,0 +1,41 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidSort = errors.New("invalid sort")
+
+// SortField is one key of a multi-field sort.
+type SortField struct {
+	Column string
+	Desc   bool
+}
+
+// ParseSort parses a comma separated list of fields, each optionally
+// prefixed with - for descending order. Only the fields of columns are
+// accepted, and each at most once.
+func ParseSort(value string, columns map[string]string) ([]SortField, error) {
+	var fields []SortField
+	seen := map[string]bool{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		desc := strings.HasPrefix(item, "-")
+		name := strings.TrimPrefix(item, "-")
+		column, ok := columns[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidSort, name)
+		}
+		if seen[column] {
+			return nil, fmt.Errorf("%w: field %q given twice", ErrInvalidSort, name)
+		}
+		seen[column] = true
+		fields = append(fields, SortField{Column: column, Desc: desc})
+	}
+	return fields, nil
+}
@@ -1,12 +1,130 @@
 package model
 
+import (
+	"fmt"
+	"time"
+)
+
 // Student model info
 // @Description student information
-// @Description with student_id,first name, last name, age and email
+// @Description with student_id,first name, last name, age and email. Deleted
+// @Description students also have when and by which user they were deleted.
 type Student struct {
-	ID        int    `json:"id"`
-	FirstName string `json:"first_name"`
-	LastName  string `json:"last_name"`
-	Age       int    `json:"age"`
-	Email     string `json:"email"`
+	ID        int        `json:"id"`
+	FirstName string     `json:"first_name" validate:"notblank,max=20"`
+	LastName  string     `json:"last_name" validate:"notblank,max=20"`
+	Age       int        `json:"age" validate:"min=0,max=150"`
+	Email     string     `json:"email" validate:"notblank,max=50,email"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy *int       `json:"deleted_by,omitempty"`
+	// Version counts the writes to the student. It is sent as the ETag of
+	// the student rather than in the body.
+	Version int `json:"-"`
+}
+
+// StudentQuery holds the pagination, filters and sorting of a student
+// listing. Name matches the first or the last name by prefix; nil MinAge and
+// MaxAge leave the age unbounded. Sort is a comma separated list of fields,
+// each optionally prefixed with - for descending order. After and Before are
+// cursors that replace Offset with keyset pagination.
+type StudentQuery struct {
+	Offset int    `form:"offset"`
+	Limit  int    `form:"limit"`
+	Name   string `form:"name"`
+	Email  string `form:"email"`
+	MinAge *int   `form:"min_age"`
+	MaxAge *int   `form:"max_age"`
+	Sort   string `form:"sort"`
+	After  string `form:"after"`
+	Before string `form:"before"`
+}
+
+// DeletedStudentQuery holds the pagination of the trash.
+type DeletedStudentQuery struct {
+	Offset int `form:"offset"`
+	Limit  int `form:"limit"`
+}
+
+// StudentSearchQuery is a full-text search of students: Q holds the words
+// to look for and Limit caps how many of the best matches are returned.
+type StudentSearchQuery struct {
+	Q     string `form:"q"`
+	Limit int    `form:"limit"`
+}
+
+// StudentSortColumns maps the fields students can be sorted by to their
+// columns.
+var StudentSortColumns = map[string]string{
+	"id":         "id",
+	"first_name": "first_name",
+	"last_name":  "last_name",
+	"age":        "age",
+	"email":      "email",
+}
+
+// SortFields parses Sort. The id is always the last key, so that students
+// that compare equal keep a stable order.
+func (q StudentQuery) SortFields() ([]SortField, error) {
+	fields, err := ParseSort(q.Sort, StudentSortColumns)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range fields {
+		if field.Column == "id" {
+			return fields, nil
+		}
+	}
+	return append(fields, SortField{Column: "id"}), nil
+}
+
+// Keyset returns where a keyset paginated listing of the query starts. A
+// cursor is only accepted with the sort it was taken from.
+func (q StudentQuery) Keyset() (Keyset, error) {
+	sort, err := q.SortFields()
+	if err != nil {
+		return Keyset{}, err
+	}
+	keyset := Keyset{Sort: sort}
+
+	value := q.After
+	if q.Before != "" {
+		if q.After != "" {
+			return keyset, fmt.Errorf("%w: after and before cannot be combined", ErrInvalidCursor)
+		}
+		value = q.Before
+		keyset.Backward = true
+	}
+	if value == "" {
+		return keyset, nil
+	}
+
+	cursor, err := DecodeCursor(value)
+	if err != nil {
+		return keyset, err
+	}
+	if cursor.Sort != FormatSort(sort) || len(cursor.Values) != len(sort) {
+		return keyset, fmt.Errorf("%w: cursor belongs to another sort", ErrInvalidCursor)
+	}
+	keyset.Values = cursor.Values
+	return keyset, nil
+}
+
+// Cursor returns the cursor pointing at s in a listing sorted by sort.
+func (s Student) Cursor(sort []SortField) Cursor {
+	values := make([]interface{}, len(sort))
+	for i, field := range sort {
+		switch field.Column {
+		case "id":
+			values[i] = s.ID
+		case "first_name":
+			values[i] = s.FirstName
+		case "last_name":
+			values[i] = s.LastName
+		case "age":
+			values[i] = s.Age
+		case "email":
+			values[i] = s.Email
+		}
+	}
+	return Cursor{Sort: FormatSort(sort), Values: values}
 }
@@ -0,0 +1,98 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Actions recorded in the history of a student.
+const (
+	StudentCreated  = "create"
+	StudentUpdated  = "update"
+	StudentDeleted  = "delete"
+	StudentRestored = "restore"
+	StudentReverted = "revert"
+)
+
+// StudentVersion is an entry of the history of a student: what was done to
+// it, by whom and when, the fields it changed and the student as it was left.
+// Version is the version of the student the change left, the one its ETag
+// names. ChangedBy is nil for changes made with an API key.
+type StudentVersion struct {
+	ID        int          `json:"-"`
+	StudentID int          `json:"student_id"`
+	Version   int          `json:"version"`
+	Action    string       `json:"action"`
+	ChangedBy *int         `json:"changed_by"`
+	ChangedAt time.Time    `json:"changed_at"`
+	FirstName string       `json:"first_name"`
+	LastName  string       `json:"last_name"`
+	Age       int          `json:"age"`
+	Email     string       `json:"email"`
+	Changes   FieldChanges `json:"changes"`
+}
+
+// FieldChange is the value of a field before and after a change. From is
+// nil when the student was created.
+type FieldChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// FieldChanges holds the fields a change touched, by name. It is stored as
+// JSON.
+type FieldChanges map[string]FieldChange
+
+func (f FieldChanges) Value() (driver.Value, error) {
+	if f == nil {
+		f = FieldChanges{}
+	}
+	b, err := json.Marshal(f)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (f *FieldChanges) Scan(value interface{}) error {
+	switch value := value.(type) {
+	case []byte:
+		return json.Unmarshal(value, f)
+	case string:
+		return json.Unmarshal([]byte(value), f)
+	case nil:
+		*f = FieldChanges{}
+		return nil
+	default:
+		return fmt.Errorf("unsupported field changes %T", value)
+	}
+}
+
+// DiffStudents returns the fields that differ between before and after.
+// Every field is changed when before is nil.
+func DiffStudents(before *Student, after Student) FieldChanges {
+	changes := FieldChanges{}
+	diff := func(field string, from, to interface{}, changed bool) {
+		if before == nil {
+			changes[field] = FieldChange{To: to}
+		} else if changed {
+			changes[field] = FieldChange{From: from, To: to}
+		}
+	}
+	var old Student
+	if before != nil {
+		old = *before
+	}
+	diff("first_name", old.FirstName, after.FirstName, old.FirstName != after.FirstName)
+	diff("last_name", old.LastName, after.LastName, old.LastName != after.LastName)
+	diff("age", old.Age, after.Age, old.Age != after.Age)
+	diff("email", old.Email, after.Email, old.Email != after.Email)
+	return changes
+}
+
+// Student returns the student as the version left it.
+func (v StudentVersion) Student() Student {
+	return Student{ID: v.StudentID, FirstName: v.FirstName, LastName: v.LastName, Age: v.Age, Email: v.Email}
+}
@@ -1,12 +1,50 @@
 package model
 
+import "time"
+
 // User model info
 // @Description user information
 // @Description with user_id, name, email, password and role
 type User struct {
-	ID       int    `json:"id"`
-	Name     string `json:"name"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
-	Role     string `json:"role"`
+	ID         int        `json:"id"`
+	Name       string     `json:"name" validate:"notblank,max=20"`
+	Email      string     `json:"email" validate:"notblank,max=50,email"`
+	Password   string     `json:"password,omitempty"`
+	Role       string     `json:"role" validate:"oneof=admin user"`
+	VerifiedAt *time.Time `json:"verified_at"`
+	DisabledAt *time.Time `json:"disabled_at"`
+}
+
+// Public returns the user without its password hash, ready to be sent to a
+// client.
+func (u User) Public() User {
+	u.Password = ""
+	return u
+}
+
+// UserQuery holds the pagination and filters of a user listing. Email and
+// Name match by prefix; nil Disabled and Verified match any user.
+type UserQuery struct {
+	Offset   int    `form:"offset"`
+	Limit    int    `form:"limit"`
+	Role     string `form:"role"`
+	Email    string `form:"email"`
+	Name     string `form:"name"`
+	Disabled *bool  `form:"disabled"`
+	Verified *bool  `form:"verified"`
+}
+
+// UserUpdate model info
+// @Description UserUpdate information
+// @Description with the fields to change, omitted fields are kept
+type UserUpdate struct {
+	Name *string `json:"name" validate:"omitempty,notblank,max=20"`
+	Role *string `json:"role" validate:"omitempty,oneof=admin user"`
+}
+
+// ProfileUpdate model info
+// @Description ProfileUpdate information
+// @Description with the profile fields users can change themselves, omitted fields are kept
+type ProfileUpdate struct {
+	Name *string `json:"name" validate:"omitempty,notblank,max=20"`
 }
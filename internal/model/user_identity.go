@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// UserIdentity links a user to an account at an external identity provider,
+// identified by the issuer of the provider and the subject it assigns.
+type UserIdentity struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Issuer    string    `json:"issuer"`
+	Subject   string    `json:"subject"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,324 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/darolpz/students/internal/auth"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// keysRefreshInterval limits how often the keys of the provider are fetched
+// again when an ID token names an unknown key.
+const keysRefreshInterval = time.Minute
+
+var (
+	ErrDiscovery       = errors.New("couldn't discover identity provider")
+	ErrExchange        = errors.New("couldn't exchange authorization code")
+	ErrFetchKeys       = errors.New("couldn't fetch identity provider keys")
+	ErrInvalidIDToken  = errors.New("invalid id token")
+	ErrMissingIDToken  = errors.New("token response has no id token")
+	ErrUnknownIDKey    = errors.New("id token signed with an unknown key")
+	ErrIssuerMismatch  = errors.New("identity provider issuer mismatch")
+	ErrNonceMismatch   = errors.New("id token nonce mismatch")
+	ErrAudienceInvalid = errors.New("id token was not issued for this client")
+)
+
+// signingMethods are the algorithms accepted for ID tokens. Symmetric and
+// unsigned tokens are never accepted.
+var signingMethods = []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// Config holds the settings of the OpenID Connect client.
+type Config struct {
+	// Issuer is the issuer URL of the identity provider, under which its
+	// discovery document is published.
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is our callback, as registered at the identity provider.
+	RedirectURL string
+	// Scopes requested besides openid. Defaults to email and profile.
+	Scopes []string
+	// GroupsClaim is the ID token claim listing the groups of the user.
+	// Defaults to groups.
+	GroupsClaim string
+}
+
+// Identity is what a verified ID token tells about the user.
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Groups        []string
+}
+
+type IProvider interface {
+	AuthCodeURL(state, nonce, codeChallenge string) (string, error)
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (Identity, error)
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+type provider struct {
+	config Config
+	client *http.Client
+
+	mu            sync.Mutex
+	discovery     *discovery
+	keys          map[string]crypto.PublicKey
+	keysFetchedAt time.Time
+}
+
+// NewProvider returns a client of the identity provider at config.Issuer.
+// The discovery document is fetched on first use, so the API can start
+// while the identity provider is unreachable.
+func NewProvider(config Config, client *http.Client) IProvider {
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"email", "profile"}
+	}
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = "groups"
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &provider{config: config, client: client}
+}
+
+// AuthCodeURL returns the authorization endpoint URL the user is sent to.
+func (p *provider) AuthCodeURL(state, nonce, codeChallenge string) (string, error) {
+	d, err := p.discover(context.Background())
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(append([]string{"openid"}, p.config.Scopes...), " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return d.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange redeems an authorization code and verifies the ID token it
+// returns, which must carry nonce.
+func (p *provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (Identity, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"code_verifier": {codeVerifier},
+		"client_id":     {p.config.ClientID},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Identity{}, fmt.Errorf("%w: %s", ErrExchange, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	var token tokenResponse
+	status, err := p.do(req, &token)
+	if err != nil {
+		return Identity{}, fmt.Errorf("%w: %s", ErrExchange, err)
+	}
+	if status != http.StatusOK {
+		return Identity{}, fmt.Errorf("%w: %d %s %s", ErrExchange, status, token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return Identity{}, ErrMissingIDToken
+	}
+
+	return p.verify(ctx, token.IDToken, nonce)
+}
+
+// verify checks the signature and the claims of an ID token as required by
+// OpenID Connect Core 3.1.3.7.
+func (p *provider) verify(ctx context.Context, idToken, nonce string) (Identity, error) {
+	claims := jwt.MapClaims{}
+	parser := jwt.Parser{ValidMethods: signingMethods}
+	_, err := parser.ParseWithClaims(idToken, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.key(ctx, kid)
+	})
+	if err != nil {
+		return Identity{}, fmt.Errorf("%w: %s", ErrInvalidIDToken, err)
+	}
+
+	now := time.Now().Unix()
+	if !claims.VerifyExpiresAt(now, true) {
+		return Identity{}, fmt.Errorf("%w: missing or past exp", ErrInvalidIDToken)
+	}
+	if iss, _ := claims["iss"].(string); iss != p.config.Issuer {
+		return Identity{}, ErrIssuerMismatch
+	}
+	if !claims.VerifyAudience(p.config.ClientID, true) {
+		return Identity{}, ErrAudienceInvalid
+	}
+	if aud, ok := claims["aud"].([]interface{}); ok && len(aud) > 1 {
+		if azp, _ := claims["azp"].(string); azp != p.config.ClientID {
+			return Identity{}, ErrAudienceInvalid
+		}
+	}
+	if got, _ := claims["nonce"].(string); got == "" || got != nonce {
+		return Identity{}, ErrNonceMismatch
+	}
+
+	identity := Identity{Issuer: p.config.Issuer}
+	identity.Subject, _ = claims["sub"].(string)
+	if identity.Subject == "" {
+		return Identity{}, fmt.Errorf("%w: missing sub", ErrInvalidIDToken)
+	}
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+	// Some providers send email_verified as a string
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	}
+	switch groups := claims[p.config.GroupsClaim].(type) {
+	case []interface{}:
+		for _, group := range groups {
+			if name, ok := group.(string); ok {
+				identity.Groups = append(identity.Groups, name)
+			}
+		}
+	case string:
+		identity.Groups = strings.Fields(groups)
+	}
+	return identity, nil
+}
+
+// discover fetches the discovery document once and checks it belongs to the
+// configured issuer.
+func (p *provider) discover(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(p.config.Issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrDiscovery, err)
+	}
+	var d discovery
+	status, err := p.do(req, &d)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrDiscovery, err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("%w: status %d", ErrDiscovery, status)
+	}
+	if d.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("%w: got %s", ErrIssuerMismatch, d.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: incomplete discovery document", ErrDiscovery)
+	}
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+// key returns the public key named kid, fetching the keys of the provider
+// again when it is unknown, since the provider may have rotated them.
+func (p *provider) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetchedAt) < keysRefreshInterval {
+		return nil, ErrUnknownIDKey
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.discovery.JWKSURI, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrFetchKeys, err)
+	}
+	var jwks auth.JWKS
+	status, err := p.do(req, &jwks)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrFetchKeys, err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("%w: status %d", ErrFetchKeys, status)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		// Keys of unsupported types are skipped, not fatal
+		if key, err := jwk.PublicKey(); err == nil {
+			keys[jwk.KeyID] = key
+		}
+	}
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, ErrUnknownIDKey
+}
+
+// do sends req and decodes the JSON response into v, whatever its status.
+func (p *provider) do(req *http.Request, v interface{}) (int, error) {
+	res, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return res.StatusCode, err
+	}
+	if err := json.Unmarshal(body, v); err != nil && res.StatusCode == http.StatusOK {
+		return res.StatusCode, err
+	}
+	return res.StatusCode, nil
+}
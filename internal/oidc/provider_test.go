@@ -0,0 +1,259 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/darolpz/students/internal/auth"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/require"
+)
+
+// testIdP is an in-process stand-in for an OpenID Connect identity provider.
+// It hands out one authorization code per authorization request and signs ID
+// tokens with claims the test controls.
+type testIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	keyID  string
+
+	mu     sync.Mutex
+	codes  map[string]url.Values
+	claims jwt.MapClaims
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	idp := &testIdP{key: key, keyID: "idp-1", codes: map[string]url.Values{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+		json.NewEncoder(w).Encode(auth.JWKS{Keys: []auth.JWK{{
+			KeyType:   "RSA",
+			KeyID:     idp.keyID,
+			Use:       "sig",
+			Algorithm: "RS256",
+			N:         base64.RawURLEncoding.EncodeToString(idp.key.N.Bytes()),
+			E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(idp.key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		idp.mu.Lock()
+		request, ok := idp.codes[r.PostForm.Get("code")]
+		delete(idp.codes, r.PostForm.Get("code"))
+		idp.mu.Unlock()
+
+		clientID, secret, _ := r.BasicAuth()
+		if !ok || clientID != "students" || secret != "secret" ||
+			CodeChallenge(r.PostForm.Get("code_verifier")) != request.Get("code_challenge") ||
+			r.PostForm.Get("redirect_uri") != request.Get("redirect_uri") {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		claims := jwt.MapClaims{
+			"iss":   idp.server.URL,
+			"sub":   "248289761001",
+			"aud":   "students",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"iat":   time.Now().Unix(),
+			"nonce": request.Get("nonce"),
+		}
+		idp.mu.Lock()
+		for name, value := range idp.claims {
+			claims[name] = value
+		}
+		idp.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idp.sign(t, claims)})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (idp *testIdP) sign(t *testing.T, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	idp.mu.Lock()
+	token.Header["kid"] = idp.keyID
+	key := idp.key
+	idp.mu.Unlock()
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+// authorize plays the user logging in at the identity provider and returns
+// the authorization code it redirects back with.
+func (idp *testIdP) authorize(t *testing.T, authCodeURL string, claims jwt.MapClaims) string {
+	u, err := url.Parse(authCodeURL)
+	require.NoError(t, err)
+	code := "code-" + u.Query().Get("state")
+	idp.mu.Lock()
+	idp.codes[code] = u.Query()
+	idp.claims = claims
+	idp.mu.Unlock()
+	return code
+}
+
+func TestProvider_Exchange(t *testing.T) {
+	idp := newTestIdP(t)
+	provider := NewProvider(Config{
+		Issuer:       idp.server.URL,
+		ClientID:     "students",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost:8080/auth/oidc/callback",
+	}, idp.server.Client())
+
+	verifier, err := GenerateCodeVerifier()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name             string
+		claims           jwt.MapClaims
+		codeVerifier     string
+		nonce            string
+		expectedError    error
+		expectedIdentity Identity
+	}{
+		{
+			name: "should_return_identity",
+			claims: jwt.MapClaims{
+				"email":          "john.doe@gmail.com",
+				"email_verified": true,
+				"name":           "John Doe",
+				"groups":         []string{"teachers", "staff"},
+			},
+			codeVerifier: verifier,
+			nonce:        "nonce",
+			expectedIdentity: Identity{
+				Issuer:        idp.server.URL,
+				Subject:       "248289761001",
+				Email:         "john.doe@gmail.com",
+				EmailVerified: true,
+				Name:          "John Doe",
+				Groups:        []string{"teachers", "staff"},
+			},
+		},
+		{
+			name:          "should_reject_wrong_code_verifier",
+			codeVerifier:  "other verifier",
+			nonce:         "nonce",
+			expectedError: ErrExchange,
+		},
+		{
+			name:          "should_reject_wrong_nonce",
+			claims:        jwt.MapClaims{"nonce": "replayed"},
+			codeVerifier:  verifier,
+			nonce:         "nonce",
+			expectedError: ErrNonceMismatch,
+		},
+		{
+			name:          "should_reject_other_audience",
+			claims:        jwt.MapClaims{"aud": "other-client"},
+			codeVerifier:  verifier,
+			nonce:         "nonce",
+			expectedError: ErrAudienceInvalid,
+		},
+		{
+			name:          "should_reject_other_issuer",
+			claims:        jwt.MapClaims{"iss": "https://evil.example.com"},
+			codeVerifier:  verifier,
+			nonce:         "nonce",
+			expectedError: ErrIssuerMismatch,
+		},
+		{
+			name:          "should_reject_expired_id_token",
+			claims:        jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()},
+			codeVerifier:  verifier,
+			nonce:         "nonce",
+			expectedError: ErrInvalidIDToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authCodeURL, err := provider.AuthCodeURL(tt.name, "nonce", CodeChallenge(verifier))
+			require.NoError(t, err)
+			code := idp.authorize(t, authCodeURL, tt.claims)
+
+			identity, err := provider.Exchange(context.Background(), code, tt.codeVerifier, tt.nonce)
+			if tt.expectedError != nil {
+				require.ErrorIs(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedIdentity, identity)
+		})
+	}
+}
+
+func TestProvider_KeyRotation(t *testing.T) {
+	idp := newTestIdP(t)
+	p := NewProvider(Config{Issuer: idp.server.URL, ClientID: "students"}, idp.server.Client()).(*provider)
+	_, err := p.discover(context.Background())
+	require.NoError(t, err)
+
+	claims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":   idp.server.URL,
+			"sub":   "1",
+			"aud":   "students",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"nonce": "nonce",
+		}
+	}
+	_, err = p.verify(context.Background(), idp.sign(t, claims()), "nonce")
+	require.NoError(t, err)
+
+	// The provider rotates its key; the new key is fetched on demand
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	idp.mu.Lock()
+	idp.key, idp.keyID = key, "idp-2"
+	idp.mu.Unlock()
+	p.keysFetchedAt = time.Time{}
+
+	_, err = p.verify(context.Background(), idp.sign(t, claims()), "nonce")
+	require.NoError(t, err)
+
+	// Tokens signed with keys the provider never published are rejected
+	forged, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims())
+	token.Header["kid"] = "idp-2"
+	signed, err := token.SignedString(forged)
+	require.NoError(t, err)
+	_, err = p.verify(context.Background(), signed, "nonce")
+	require.ErrorIs(t, err, ErrInvalidIDToken)
+}
+
+func TestMapRole(t *testing.T) {
+	mapping := map[string]string{"it-admins": auth.RoleAdmin, "teachers": auth.RoleUser}
+
+	require.Equal(t, auth.RoleAdmin, MapRole([]string{"teachers", "it-admins"}, mapping))
+	require.Equal(t, auth.RoleUser, MapRole([]string{"teachers"}, mapping))
+	require.Equal(t, auth.RoleUser, MapRole([]string{"students"}, mapping))
+	require.Equal(t, auth.RoleUser, MapRole(nil, mapping))
+}
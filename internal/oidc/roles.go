@@ -0,0 +1,23 @@
+package oidc
+
+import "github.com/darolpz/students/internal/auth"
+
+// rolePrecedence orders roles from most to least privileged.
+var rolePrecedence = []string{auth.RoleAdmin, auth.RoleUser}
+
+// MapRole returns the most privileged role any of groups is mapped to, or
+// auth.RoleUser when none of them is mapped.
+func MapRole(groups []string, mapping map[string]string) string {
+	granted := map[string]bool{}
+	for _, group := range groups {
+		if role, ok := mapping[group]; ok {
+			granted[role] = true
+		}
+	}
+	for _, role := range rolePrecedence {
+		if granted[role] {
+			return role
+		}
+	}
+	return auth.RoleUser
+}
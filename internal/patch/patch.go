@@ -0,0 +1,340 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// MergePatchType is the media type of JSON Merge Patch documents, RFC
+	// 7396.
+	MergePatchType = "application/merge-patch+json"
+	// JSONPatchType is the media type of JSON Patch documents, RFC 6902.
+	JSONPatchType = "application/json-patch+json"
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported patch type, use " + MergePatchType + " or " + JSONPatchType)
+	ErrInvalidPatch    = errors.New("invalid patch")
+	ErrConflict        = errors.New("patch does not apply")
+)
+
+// Apply applies patch, a document of the media type contentType, to the JSON
+// document doc and returns the patched document. It fails with
+// ErrInvalidPatch when patch is malformed and with ErrConflict when it does
+// not apply to doc, such as when a JSON Patch test fails. doc is left as it
+// is either way.
+func Apply(contentType string, doc, patch []byte) ([]byte, error) {
+	switch contentType {
+	case MergePatchType:
+		return MergePatch(doc, patch)
+	case JSONPatchType:
+		return JSONPatch(doc, patch)
+	default:
+		return nil, ErrUnsupportedType
+	}
+}
+
+// MergePatch applies the JSON Merge Patch patch to doc: members of patch
+// objects replace those of doc, recursively, and null members remove them.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	var target, p interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPatch, err)
+	}
+	return json.Marshal(merge(target, p))
+}
+
+func merge(target, patch interface{}) interface{} {
+	members, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	object, ok := target.(map[string]interface{})
+	if !ok {
+		object = map[string]interface{}{}
+	}
+	for name, value := range members {
+		if value == nil {
+			delete(object, name)
+		} else {
+			object[name] = merge(object[name], value)
+		}
+	}
+	return object
+}
+
+// operation is a JSON Patch operation. Value is nil when the operation has
+// none, and holds null when it is null.
+type operation struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// JSONPatch applies the operations of the JSON Patch patch to doc in order.
+// Either every operation applies or doc is left as it is.
+func JSONPatch(doc, patch []byte) ([]byte, error) {
+	var target interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+	var ops []operation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPatch, err)
+	}
+
+	for i, op := range ops {
+		var err error
+		if target, err = op.apply(target); err != nil {
+			return nil, fmt.Errorf("%w: operation %d", err, i)
+		}
+	}
+	return json.Marshal(target)
+}
+
+func (op operation) apply(doc interface{}) (interface{}, error) {
+	if op.Path == nil {
+		return nil, fmt.Errorf("%w: %s without path", ErrInvalidPatch, op.Op)
+	}
+	path, err := parsePointer(*op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, fmt.Errorf("%w: %s without value", ErrInvalidPatch, op.Op)
+		}
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidPatch, err)
+		}
+	case "move", "copy":
+		if op.From == nil {
+			return nil, fmt.Errorf("%w: %s without from", ErrInvalidPatch, op.Op)
+		}
+	case "remove":
+	default:
+		return nil, fmt.Errorf("%w: unknown operation %q", ErrInvalidPatch, op.Op)
+	}
+
+	switch op.Op {
+	case "add":
+		return update(doc, path, func(parent interface{}, key string) (interface{}, error) {
+			return add(parent, key, value)
+		}, value)
+	case "remove":
+		if len(path) == 0 {
+			return nil, fmt.Errorf("%w: cannot remove the whole document", ErrConflict)
+		}
+		return update(doc, path, remove, nil)
+	case "replace":
+		return update(doc, path, func(parent interface{}, key string) (interface{}, error) {
+			return replace(parent, key, value)
+		}, value)
+	case "test":
+		current, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !equal(current, value) {
+			return nil, fmt.Errorf("%w: test of %s failed", ErrConflict, *op.Path)
+		}
+		return doc, nil
+	}
+
+	// move and copy
+	from, err := parsePointer(*op.From)
+	if err != nil {
+		return nil, err
+	}
+	value, err = get(doc, from)
+	if err != nil {
+		return nil, err
+	}
+	if op.Op == "copy" {
+		value = clone(value)
+	} else {
+		if isPrefix(from, path) {
+			return nil, fmt.Errorf("%w: cannot move %s into itself", ErrInvalidPatch, *op.From)
+		}
+		if len(from) == 0 {
+			return nil, fmt.Errorf("%w: cannot move the whole document", ErrInvalidPatch)
+		}
+		if doc, err = update(doc, from, remove, nil); err != nil {
+			return nil, err
+		}
+	}
+	return update(doc, path, func(parent interface{}, key string) (interface{}, error) {
+		return add(parent, key, value)
+	}, value)
+}
+
+// update returns doc with the value path points to changed by change, which
+// gets the container holding it and its key. An empty path replaces the
+// whole document with root instead.
+func update(doc interface{}, path []string, change func(parent interface{}, key string) (interface{}, error), root interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return root, nil
+	}
+	if len(path) == 1 {
+		return change(doc, path[0])
+	}
+	child, err := get(doc, path[:1])
+	if err != nil {
+		return nil, err
+	}
+	if child, err = update(child, path[1:], change, root); err != nil {
+		return nil, err
+	}
+	return replace(doc, path[0], child)
+}
+
+func get(doc interface{}, path []string) (interface{}, error) {
+	for _, key := range path {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[key]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s not found", ErrConflict, key)
+			}
+			doc = value
+		case []interface{}:
+			i, err := index(key, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, fmt.Errorf("%w: %s not found", ErrConflict, key)
+		}
+	}
+	return doc, nil
+}
+
+func add(parent interface{}, key string, value interface{}) (interface{}, error) {
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		node[key] = value
+		return node, nil
+	case []interface{}:
+		i := len(node)
+		if key != "-" {
+			var err error
+			if i, err = index(key, len(node)); err != nil {
+				return nil, err
+			}
+		}
+		node = append(node, nil)
+		copy(node[i+1:], node[i:])
+		node[i] = value
+		return node, nil
+	default:
+		return nil, fmt.Errorf("%w: cannot add %s to a %T", ErrConflict, key, parent)
+	}
+}
+
+func remove(parent interface{}, key string) (interface{}, error) {
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		if _, ok := node[key]; !ok {
+			return nil, fmt.Errorf("%w: %s not found", ErrConflict, key)
+		}
+		delete(node, key)
+		return node, nil
+	case []interface{}:
+		i, err := index(key, len(node)-1)
+		if err != nil {
+			return nil, err
+		}
+		return append(node[:i], node[i+1:]...), nil
+	default:
+		return nil, fmt.Errorf("%w: %s not found", ErrConflict, key)
+	}
+}
+
+func replace(parent interface{}, key string, value interface{}) (interface{}, error) {
+	if _, err := get(parent, []string{key}); err != nil {
+		return nil, err
+	}
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		node[key] = value
+	case []interface{}:
+		i, _ := index(key, len(node)-1)
+		node[i] = value
+	}
+	return parent, nil
+}
+
+// index parses the array index key, which must be at most max.
+func index(key string, max int) (int, error) {
+	if key == "" || len(key) > 1 && key[0] == '0' {
+		return 0, fmt.Errorf("%w: invalid array index %q", ErrInvalidPatch, key)
+	}
+	i := 0
+	for _, c := range key {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("%w: invalid array index %q", ErrInvalidPatch, key)
+		}
+		i = i*10 + int(c-'0')
+		if i > max {
+			return 0, fmt.Errorf("%w: array index %s out of bounds", ErrConflict, key)
+		}
+	}
+	return i, nil
+}
+
+// unescape decodes the keys of JSON Pointers, ~1 first so that ~01 stays ~1.
+var unescape = strings.NewReplacer("~1", "/", "~0", "~")
+
+// parsePointer splits the JSON Pointer p, RFC 6901, into the keys it is made
+// of.
+func parsePointer(p string) ([]string, error) {
+	if p == "" {
+		return nil, nil
+	}
+	if p[0] != '/' {
+		return nil, fmt.Errorf("%w: invalid path %q", ErrInvalidPatch, p)
+	}
+	keys := strings.Split(p[1:], "/")
+	for i, key := range keys {
+		keys[i] = unescape.Replace(key)
+	}
+	return keys, nil
+}
+
+func isPrefix(prefix, path []string) bool {
+	if len(prefix) >= len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// equal compares JSON values decoded by encoding/json.
+func equal(a, b interface{}) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return bytes.Equal(x, y)
+}
+
+func clone(value interface{}) interface{} {
+	b, _ := json.Marshal(value)
+	var c interface{}
+	_ = json.Unmarshal(b, &c)
+	return c
+}
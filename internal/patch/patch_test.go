@@ -0,0 +1,168 @@
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const student = `{"id":1,"first_name":"John","last_name":"Doe","age":33,"email":"john.doe@gmail.com"}`
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name          string
+		doc           string
+		patch         string
+		want          string
+		expectedError error
+	}{
+		{
+			name:  "should_change_given_fields_only",
+			doc:   student,
+			patch: `{"age":34}`,
+			want:  `{"age":34,"email":"john.doe@gmail.com","first_name":"John","id":1,"last_name":"Doe"}`,
+		},
+		{
+			name:  "should_remove_null_fields",
+			doc:   student,
+			patch: `{"last_name":null,"email":"john@gmail.com"}`,
+			want:  `{"age":33,"email":"john@gmail.com","first_name":"John","id":1}`,
+		},
+		{
+			name:  "should_merge_nested_objects",
+			doc:   `{"a":{"b":1,"c":2},"d":[1,2]}`,
+			patch: `{"a":{"b":null,"e":{"f":3}},"d":[3]}`,
+			want:  `{"a":{"c":2,"e":{"f":3}},"d":[3]}`,
+		},
+		{
+			name:  "should_replace_document_with_non_object_patch",
+			doc:   student,
+			patch: `["a"]`,
+			want:  `["a"]`,
+		},
+		{
+			name:          "should_return_error_invalid_patch",
+			doc:           student,
+			patch:         `{"age":`,
+			expectedError: ErrInvalidPatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+			if tt.expectedError != nil {
+				require.ErrorIs(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	tests := []struct {
+		name          string
+		doc           string
+		patch         string
+		want          string
+		expectedError error
+	}{
+		{
+			name:  "should_replace_and_test",
+			doc:   student,
+			patch: `[{"op":"test","path":"/age","value":33},{"op":"replace","path":"/age","value":34}]`,
+			want:  `{"age":34,"email":"john.doe@gmail.com","first_name":"John","id":1,"last_name":"Doe"}`,
+		},
+		{
+			name:  "should_add_remove_move_and_copy",
+			doc:   `{"a":[1,2],"b":{"c":"x"},"d~/":true}`,
+			patch: `[{"op":"add","path":"/a/1","value":9},{"op":"add","path":"/a/-","value":3},{"op":"remove","path":"/a/0"},{"op":"move","from":"/b/c","path":"/e"},{"op":"copy","from":"/d~0~1","path":"/b/f"}]`,
+			want:  `{"a":[9,2,3],"b":{"f":true},"d~/":true,"e":"x"}`,
+		},
+		{
+			name:  "should_replace_whole_document",
+			doc:   student,
+			patch: `[{"op":"replace","path":"","value":{"id":2}}]`,
+			want:  `{"id":2}`,
+		},
+		{
+			name:  "should_add_null",
+			doc:   `{}`,
+			patch: `[{"op":"add","path":"/a","value":null}]`,
+			want:  `{"a":null}`,
+		},
+		{
+			name:          "should_return_error_failed_test",
+			doc:           student,
+			patch:         `[{"op":"replace","path":"/age","value":34},{"op":"test","path":"/first_name","value":"Jane"}]`,
+			expectedError: ErrConflict,
+		},
+		{
+			name:          "should_return_error_missing_path",
+			doc:           student,
+			patch:         `[{"op":"replace","path":"/phone","value":"123"}]`,
+			expectedError: ErrConflict,
+		},
+		{
+			name:          "should_return_error_index_out_of_bounds",
+			doc:           `{"a":[1]}`,
+			patch:         `[{"op":"add","path":"/a/2","value":3}]`,
+			expectedError: ErrConflict,
+		},
+		{
+			name:          "should_return_error_unknown_operation",
+			doc:           student,
+			patch:         `[{"op":"increment","path":"/age","value":1}]`,
+			expectedError: ErrInvalidPatch,
+		},
+		{
+			name:          "should_return_error_missing_value",
+			doc:           student,
+			patch:         `[{"op":"add","path":"/age"}]`,
+			expectedError: ErrInvalidPatch,
+		},
+		{
+			name:          "should_return_error_invalid_pointer",
+			doc:           student,
+			patch:         `[{"op":"remove","path":"age"}]`,
+			expectedError: ErrInvalidPatch,
+		},
+		{
+			name:          "should_return_error_move_into_itself",
+			doc:           `{"a":{"b":1}}`,
+			patch:         `[{"op":"move","from":"/a","path":"/a/c"}]`,
+			expectedError: ErrInvalidPatch,
+		},
+		{
+			name:          "should_return_error_not_an_array",
+			doc:           student,
+			patch:         `{"op":"remove","path":"/age"}`,
+			expectedError: ErrInvalidPatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := JSONPatch([]byte(tt.doc), []byte(tt.patch))
+			if tt.expectedError != nil {
+				require.ErrorIs(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	got, err := Apply(MergePatchType, []byte(student), []byte(`{"age":34}`))
+	require.NoError(t, err)
+	require.Contains(t, string(got), `"age":34`)
+
+	got, err = Apply(JSONPatchType, []byte(student), []byte(`[{"op":"remove","path":"/age"}]`))
+	require.NoError(t, err)
+	require.NotContains(t, string(got), `"age"`)
+
+	_, err = Apply("application/xml", []byte(student), []byte(`<age>34</age>`))
+	require.ErrorIs(t, err, ErrUnsupportedType)
+}
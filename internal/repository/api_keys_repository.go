@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/darolpz/students/internal/database"
+	"github.com/darolpz/students/internal/model"
+)
+
+var ErrAPIKeyNotFound = database.ErrAPIKeyNotFound
+
+type IAPIKeysRepository interface {
+	CreateAPIKey(key model.APIKey) (model.APIKey, error)
+	ListAPIKeys() ([]model.APIKey, error)
+	FindAPIKey(hash string) (model.APIKey, error)
+	RevokeAPIKey(id int) error
+	TouchAPIKey(id int, before time.Time) error
+}
+
+type apiKeysRepository struct {
+	db database.IDatabaseService
+}
+
+func NewAPIKeysRepository(db database.IDatabaseService) IAPIKeysRepository {
+	return apiKeysRepository{db: db}
+}
+
+func (r apiKeysRepository) CreateAPIKey(key model.APIKey) (model.APIKey, error) {
+	return r.db.CreateAPIKey(key)
+}
+
+func (r apiKeysRepository) ListAPIKeys() ([]model.APIKey, error) {
+	return r.db.ListAPIKeys()
+}
+
+func (r apiKeysRepository) FindAPIKey(hash string) (model.APIKey, error) {
+	key, err := r.db.FindAPIKey(hash)
+	if err != nil {
+		if errors.Is(err, database.ErrAPIKeyNotFound) {
+			return model.APIKey{}, ErrAPIKeyNotFound
+		}
+		return model.APIKey{}, err
+	}
+	return key, nil
+}
+
+func (r apiKeysRepository) RevokeAPIKey(id int) error {
+	return r.db.RevokeAPIKey(id)
+}
+
+func (r apiKeysRepository) TouchAPIKey(id int, before time.Time) error {
+	return r.db.TouchAPIKey(id, before)
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/darolpz/students/internal/database"
+	"github.com/darolpz/students/internal/model"
+)
+
+var (
+	ErrInvitationNotFound = database.ErrInvitationNotFound
+	ErrInvitationUsed     = database.ErrInvitationUsed
+)
+
+type IInvitationsRepository interface {
+	CreateInvitation(invitation model.Invitation) (model.Invitation, error)
+	ListInvitations() ([]model.Invitation, error)
+	FindInvitation(hash string) (model.Invitation, error)
+	RedeemInvitation(id int, user model.User) (model.User, error)
+	DeleteInvitation(id int) error
+}
+
+type invitationsRepository struct {
+	db database.IDatabaseService
+}
+
+func NewInvitationsRepository(db database.IDatabaseService) IInvitationsRepository {
+	return invitationsRepository{db: db}
+}
+
+func (r invitationsRepository) CreateInvitation(invitation model.Invitation) (model.Invitation, error) {
+	return r.db.CreateInvitation(invitation)
+}
+
+func (r invitationsRepository) ListInvitations() ([]model.Invitation, error) {
+	return r.db.ListInvitations()
+}
+
+func (r invitationsRepository) FindInvitation(hash string) (model.Invitation, error) {
+	invitation, err := r.db.FindInvitation(hash)
+	if err != nil {
+		if errors.Is(err, database.ErrInvitationNotFound) {
+			return model.Invitation{}, ErrInvitationNotFound
+		}
+		return model.Invitation{}, err
+	}
+	return invitation, nil
+}
+
+func (r invitationsRepository) RedeemInvitation(id int, user model.User) (model.User, error) {
+	return r.db.RedeemInvitation(id, user)
+}
+
+func (r invitationsRepository) DeleteInvitation(id int) error {
+	return r.db.DeleteInvitation(id)
+}
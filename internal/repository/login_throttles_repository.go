@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/darolpz/students/internal/database"
+	"github.com/darolpz/students/internal/model"
+)
+
+type ILoginThrottlesRepository interface {
+	FindLoginThrottles(ids []string) ([]model.LoginThrottle, error)
+	RecordLoginFailure(id string, resetBefore time.Time) error
+	DeleteLoginThrottle(id string) error
+	DeleteStaleLoginThrottles(before time.Time) error
+}
+
+type loginThrottlesRepository struct {
+	db database.IDatabaseService
+}
+
+func NewLoginThrottlesRepository(db database.IDatabaseService) ILoginThrottlesRepository {
+	return loginThrottlesRepository{db: db}
+}
+
+func (r loginThrottlesRepository) FindLoginThrottles(ids []string) ([]model.LoginThrottle, error) {
+	return r.db.FindLoginThrottles(ids)
+}
+
+func (r loginThrottlesRepository) RecordLoginFailure(id string, resetBefore time.Time) error {
+	return r.db.RecordLoginFailure(id, resetBefore)
+}
+
+func (r loginThrottlesRepository) DeleteLoginThrottle(id string) error {
+	return r.db.DeleteLoginThrottle(id)
+}
+
+func (r loginThrottlesRepository) DeleteStaleLoginThrottles(before time.Time) error {
+	return r.db.DeleteStaleLoginThrottles(before)
+}
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/darolpz/students/internal/database"
+	"github.com/darolpz/students/internal/model"
+)
+
+var (
+	ErrMFAEnrollmentNotFound   = database.ErrMFAEnrollmentNotFound
+	ErrMFACodeReused           = database.ErrMFACodeReused
+	ErrMFARecoveryCodeNotFound = database.ErrMFARecoveryCodeNotFound
+)
+
+type IMFARepository interface {
+	FindMFAEnrollment(userID int) (model.MFAEnrollment, error)
+	CreateMFAEnrollment(enrollment model.MFAEnrollment) (model.MFAEnrollment, error)
+	EnableMFAEnrollment(userID int, step int64, recoveryCodeHashes []string) error
+	UseMFAStep(userID int, step int64) error
+	UseMFARecoveryCode(userID int, hash string) error
+	DeleteMFAEnrollment(userID int) error
+}
+
+type mfaRepository struct {
+	db database.IDatabaseService
+}
+
+func NewMFARepository(db database.IDatabaseService) IMFARepository {
+	return mfaRepository{db: db}
+}
+
+func (r mfaRepository) FindMFAEnrollment(userID int) (model.MFAEnrollment, error) {
+	enrollment, err := r.db.FindMFAEnrollment(userID)
+	if err != nil {
+		if errors.Is(err, database.ErrMFAEnrollmentNotFound) {
+			return model.MFAEnrollment{}, ErrMFAEnrollmentNotFound
+		}
+		return model.MFAEnrollment{}, err
+	}
+	return enrollment, nil
+}
+
+func (r mfaRepository) CreateMFAEnrollment(enrollment model.MFAEnrollment) (model.MFAEnrollment, error) {
+	return r.db.CreateMFAEnrollment(enrollment)
+}
+
+func (r mfaRepository) EnableMFAEnrollment(userID int, step int64, recoveryCodeHashes []string) error {
+	return r.db.EnableMFAEnrollment(userID, step, recoveryCodeHashes)
+}
+
+func (r mfaRepository) UseMFAStep(userID int, step int64) error {
+	return r.db.UseMFAStep(userID, step)
+}
+
+func (r mfaRepository) UseMFARecoveryCode(userID int, hash string) error {
+	return r.db.UseMFARecoveryCode(userID, hash)
+}
+
+func (r mfaRepository) DeleteMFAEnrollment(userID int) error {
+	return r.db.DeleteMFAEnrollment(userID)
+}
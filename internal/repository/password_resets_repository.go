@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/darolpz/students/internal/database"
+	"github.com/darolpz/students/internal/model"
+)
+
+var (
+	ErrPasswordResetNotFound = database.ErrPasswordResetNotFound
+	ErrPasswordResetUsed     = database.ErrPasswordResetUsed
+)
+
+type IPasswordResetsRepository interface {
+	CreatePasswordReset(reset model.PasswordReset) (model.PasswordReset, error)
+	FindPasswordReset(hash string) (model.PasswordReset, error)
+	UsePasswordReset(id int) error
+}
+
+type passwordResetsRepository struct {
+	db database.IDatabaseService
+}
+
+func NewPasswordResetsRepository(db database.IDatabaseService) IPasswordResetsRepository {
+	return passwordResetsRepository{db: db}
+}
+
+func (r passwordResetsRepository) CreatePasswordReset(reset model.PasswordReset) (model.PasswordReset, error) {
+	return r.db.CreatePasswordReset(reset)
+}
+
+func (r passwordResetsRepository) FindPasswordReset(hash string) (model.PasswordReset, error) {
+	reset, err := r.db.FindPasswordReset(hash)
+	if err != nil {
+		if errors.Is(err, database.ErrPasswordResetNotFound) {
+			return model.PasswordReset{}, ErrPasswordResetNotFound
+		}
+		return model.PasswordReset{}, err
+	}
+	return reset, nil
+}
+
+func (r passwordResetsRepository) UsePasswordReset(id int) error {
+	return r.db.UsePasswordReset(id)
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/darolpz/students/internal/database"
+	"github.com/darolpz/students/internal/model"
+)
+
+var (
+	ErrRefreshTokenNotFound = database.ErrRefreshTokenNotFound
+	ErrRefreshTokenRotated  = database.ErrRefreshTokenRotated
+)
+
+type IRefreshTokensRepository interface {
+	CreateRefreshToken(token model.RefreshToken) (model.RefreshToken, error)
+	FindRefreshToken(hash string) (model.RefreshToken, error)
+	RotateRefreshToken(id int) error
+	RevokeRefreshTokenFamily(familyID string) error
+	RevokeUserRefreshTokens(userID int) error
+}
+
+type refreshTokensRepository struct {
+	db database.IDatabaseService
+}
+
+func NewRefreshTokensRepository(db database.IDatabaseService) IRefreshTokensRepository {
+	return refreshTokensRepository{db: db}
+}
+
+func (r refreshTokensRepository) CreateRefreshToken(token model.RefreshToken) (model.RefreshToken, error) {
+	return r.db.CreateRefreshToken(token)
+}
+
+func (r refreshTokensRepository) FindRefreshToken(hash string) (model.RefreshToken, error) {
+	token, err := r.db.FindRefreshToken(hash)
+	if err != nil {
+		if errors.Is(err, database.ErrRefreshTokenNotFound) {
+			return model.RefreshToken{}, ErrRefreshTokenNotFound
+		}
+		return model.RefreshToken{}, err
+	}
+	return token, nil
+}
+
+func (r refreshTokensRepository) RotateRefreshToken(id int) error {
+	return r.db.RotateRefreshToken(id)
+}
+
+func (r refreshTokensRepository) RevokeRefreshTokenFamily(familyID string) error {
+	return r.db.RevokeRefreshTokenFamily(familyID)
+}
+
+func (r refreshTokensRepository) RevokeUserRefreshTokens(userID int) error {
+	return r.db.RevokeUserRefreshTokens(userID)
+}
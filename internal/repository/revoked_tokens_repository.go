@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/darolpz/students/internal/database"
+	"github.com/darolpz/students/internal/model"
+)
+
+type IRevokedTokensRepository interface {
+	CreateRevokedToken(token model.RevokedToken) (model.RevokedToken, error)
+	IsTokenRevoked(jti, sessionID string, userID int, issuedAt time.Time) (bool, error)
+	DeleteExpiredRevokedTokens() error
+}
+
+type revokedTokensRepository struct {
+	db database.IDatabaseService
+}
+
+func NewRevokedTokensRepository(db database.IDatabaseService) IRevokedTokensRepository {
+	return revokedTokensRepository{db: db}
+}
+
+func (r revokedTokensRepository) CreateRevokedToken(token model.RevokedToken) (model.RevokedToken, error) {
+	return r.db.CreateRevokedToken(token)
+}
+
+func (r revokedTokensRepository) IsTokenRevoked(jti, sessionID string, userID int, issuedAt time.Time) (bool, error) {
+	return r.db.IsTokenRevoked(jti, sessionID, userID, issuedAt)
+}
+
+func (r revokedTokensRepository) DeleteExpiredRevokedTokens() error {
+	return r.db.DeleteExpiredRevokedTokens()
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/darolpz/students/internal/database"
+	"github.com/darolpz/students/internal/model"
+)
+
+var ErrSessionNotFound = database.ErrSessionNotFound
+
+type ISessionsRepository interface {
+	CreateSession(session model.Session) (model.Session, error)
+	FindSession(id string) (model.Session, error)
+	ListSessions(userID int) ([]model.Session, error)
+	TouchSession(id string) error
+	DeleteStaleSessions(before time.Time) error
+}
+
+type sessionsRepository struct {
+	db database.IDatabaseService
+}
+
+func NewSessionsRepository(db database.IDatabaseService) ISessionsRepository {
+	return sessionsRepository{db: db}
+}
+
+func (r sessionsRepository) CreateSession(session model.Session) (model.Session, error) {
+	return r.db.CreateSession(session)
+}
+
+func (r sessionsRepository) FindSession(id string) (model.Session, error) {
+	session, err := r.db.FindSession(id)
+	if err != nil {
+		if errors.Is(err, database.ErrSessionNotFound) {
+			return model.Session{}, ErrSessionNotFound
+		}
+		return model.Session{}, err
+	}
+	return session, nil
+}
+
+func (r sessionsRepository) ListSessions(userID int) ([]model.Session, error) {
+	return r.db.ListSessions(userID)
+}
+
+func (r sessionsRepository) TouchSession(id string) error {
+	return r.db.TouchSession(id)
+}
+
+func (r sessionsRepository) DeleteStaleSessions(before time.Time) error {
+	return r.db.DeleteStaleSessions(before)
+}
@@ -2,19 +2,40 @@ package repository
 
 import (
 	"errors"
+	"time"
 
 	"github.com/darolpz/students/internal/database"
 	"github.com/darolpz/students/internal/model"
 )
 
-var ErrStudentNotFound = database.ErrStudentNotFound
+var (
+	ErrStudentNotFound        = database.ErrStudentNotFound
+	ErrStudentVersionNotFound = database.ErrStudentVersionNotFound
+	ErrStudentModified        = database.ErrStudentModified
+	ErrDuplicateEmail         = database.ErrDuplicateEmail
+)
+
+// SaveStudentsError is why SaveStudents failed, naming the student it failed
+// on.
+type SaveStudentsError = database.SaveStudentsError
 
 type IStudentsRepository interface {
 	FindStudent(id string) (model.Student, error)
-	ListStudents(offset, limit int) ([]model.Student, error)
-	CreateStudent(student model.Student) (model.Student, error)
-	UpdateStudent(id string, student model.Student) (model.Student, error)
-	DeleteStudent(id string) error
+	ListStudents(query model.StudentQuery) ([]model.Student, int64, error)
+	SeekStudents(query model.StudentQuery) ([]model.Student, error)
+	SearchStudents(terms []string, limit int) ([]model.Student, error)
+	EachStudent(query model.StudentQuery, fn func(student model.Student) error) error
+	FindStudentsByEmail(emails []string) ([]model.Student, error)
+	SaveStudents(students []model.Student, changedBy *int) ([]model.Student, error)
+	CreateStudent(student model.Student, changedBy *int) (model.Student, error)
+	UpdateStudent(id string, student model.Student, version int, changedBy *int) (model.Student, error)
+	DeleteStudent(id int, version int, deletedBy *int) error
+	ListDeletedStudents(query model.DeletedStudentQuery) ([]model.Student, int64, error)
+	RestoreStudent(id int, restoredBy *int) (model.Student, error)
+	PurgeDeletedStudents(before time.Time) error
+	ListStudentVersions(id int) ([]model.StudentVersion, error)
+	FindStudentVersion(id int, version int) (model.StudentVersion, error)
+	RevertStudent(id int, version int, current int, changedBy *int) (model.Student, error)
 }
 
 type studentsRepo struct {
@@ -36,18 +57,92 @@ func (s studentsRepo) FindStudent(id string) (model.Student, error) {
 	return student, nil
 }
 
-func (s studentsRepo) ListStudents(offset, limit int) ([]model.Student, error) {
-	return s.db.ListStudents(offset, limit)
+func (s studentsRepo) ListStudents(query model.StudentQuery) ([]model.Student, int64, error) {
+	return s.db.ListStudents(query)
+}
+
+func (s studentsRepo) SeekStudents(query model.StudentQuery) ([]model.Student, error) {
+	return s.db.SeekStudents(query)
+}
+
+func (s studentsRepo) SearchStudents(terms []string, limit int) ([]model.Student, error) {
+	return s.db.SearchStudents(terms, limit)
+}
+
+func (s studentsRepo) EachStudent(query model.StudentQuery, fn func(student model.Student) error) error {
+	return s.db.EachStudent(query, fn)
+}
+
+func (s studentsRepo) FindStudentsByEmail(emails []string) ([]model.Student, error) {
+	return s.db.FindStudentsByEmail(emails)
+}
+
+func (s studentsRepo) SaveStudents(students []model.Student, changedBy *int) ([]model.Student, error) {
+	return s.db.SaveStudents(students, changedBy)
 }
 
-func (s studentsRepo) CreateStudent(student model.Student) (model.Student, error) {
-	return s.db.CreateStudent(student)
+func (s studentsRepo) CreateStudent(student model.Student, changedBy *int) (model.Student, error) {
+	return s.db.CreateStudent(student, changedBy)
 }
 
-func (s studentsRepo) UpdateStudent(id string, student model.Student) (model.Student, error) {
-	return s.db.UpdateStudent(id, student)
+func (s studentsRepo) UpdateStudent(id string, student model.Student, version int, changedBy *int) (model.Student, error) {
+	return s.db.UpdateStudent(id, student, version, changedBy)
 }
 
-func (s studentsRepo) DeleteStudent(id string) error {
-	return s.db.DeleteStudent(id)
+func (s studentsRepo) DeleteStudent(id int, version int, deletedBy *int) error {
+	if err := s.db.DeleteStudent(id, version, deletedBy); err != nil {
+		if errors.Is(err, database.ErrStudentNotFound) {
+			return ErrStudentNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (s studentsRepo) ListDeletedStudents(query model.DeletedStudentQuery) ([]model.Student, int64, error) {
+	return s.db.ListDeletedStudents(query)
+}
+
+func (s studentsRepo) RestoreStudent(id int, restoredBy *int) (model.Student, error) {
+	student, err := s.db.RestoreStudent(id, restoredBy)
+	if err != nil {
+		if errors.Is(err, database.ErrStudentNotFound) {
+			return model.Student{}, ErrStudentNotFound
+		}
+		return model.Student{}, err
+	}
+	return student, nil
+}
+
+func (s studentsRepo) PurgeDeletedStudents(before time.Time) error {
+	return s.db.PurgeDeletedStudents(before)
+}
+
+func (s studentsRepo) ListStudentVersions(id int) ([]model.StudentVersion, error) {
+	return s.db.ListStudentVersions(id)
+}
+
+func (s studentsRepo) FindStudentVersion(id int, version int) (model.StudentVersion, error) {
+	v, err := s.db.FindStudentVersion(id, version)
+	if err != nil {
+		if errors.Is(err, database.ErrStudentVersionNotFound) {
+			return model.StudentVersion{}, ErrStudentVersionNotFound
+		}
+		return model.StudentVersion{}, err
+	}
+	return v, nil
+}
+
+func (s studentsRepo) RevertStudent(id int, version int, current int, changedBy *int) (model.Student, error) {
+	student, err := s.db.RevertStudent(id, version, current, changedBy)
+	if err != nil {
+		if errors.Is(err, database.ErrStudentNotFound) {
+			return model.Student{}, ErrStudentNotFound
+		}
+		if errors.Is(err, database.ErrStudentVersionNotFound) {
+			return model.Student{}, ErrStudentVersionNotFound
+		}
+		return model.Student{}, err
+	}
+	return student, nil
 }
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/darolpz/students/internal/database"
+	"github.com/darolpz/students/internal/model"
+)
+
+var ErrUserIdentityNotFound = database.ErrUserIdentityNotFound
+
+type IUserIdentitiesRepository interface {
+	FindUserIdentity(issuer, subject string) (model.UserIdentity, error)
+	CreateUserIdentity(identity model.UserIdentity) (model.UserIdentity, error)
+	ProvisionUser(user model.User, identity model.UserIdentity) (model.User, error)
+}
+
+type userIdentitiesRepository struct {
+	db database.IDatabaseService
+}
+
+func NewUserIdentitiesRepository(db database.IDatabaseService) IUserIdentitiesRepository {
+	return userIdentitiesRepository{db: db}
+}
+
+func (r userIdentitiesRepository) FindUserIdentity(issuer, subject string) (model.UserIdentity, error) {
+	identity, err := r.db.FindUserIdentity(issuer, subject)
+	if err != nil {
+		if errors.Is(err, database.ErrUserIdentityNotFound) {
+			return model.UserIdentity{}, ErrUserIdentityNotFound
+		}
+		return model.UserIdentity{}, err
+	}
+	return identity, nil
+}
+
+func (r userIdentitiesRepository) CreateUserIdentity(identity model.UserIdentity) (model.UserIdentity, error) {
+	return r.db.CreateUserIdentity(identity)
+}
+
+func (r userIdentitiesRepository) ProvisionUser(user model.User, identity model.UserIdentity) (model.User, error) {
+	return r.db.ProvisionUser(user, identity)
+}
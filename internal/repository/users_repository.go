@@ -11,7 +11,15 @@ var ErrUserNotFound = database.ErrUserNotFound
 
 type IUsersRepository interface {
 	FindUserByEmail(email string) (model.User, error)
+	FindUserByID(id int) (model.User, error)
 	CreateUser(user model.User) (model.User, error)
+	UpdateUserPassword(id int, password string) error
+	VerifyUserEmail(id int) error
+	UpdateUserRole(id int, role string) error
+	ListUsers(query model.UserQuery) ([]model.User, int64, error)
+	UpdateUser(id int, update model.UserUpdate) (model.User, error)
+	SetUserDisabled(id int, disabled bool) error
+	DeleteUser(id int) error
 }
 
 type usersRepository struct {
@@ -33,6 +41,58 @@ func (u usersRepository) FindUserByEmail(email string) (model.User, error) {
 	return user, nil
 }
 
+func (u usersRepository) FindUserByID(id int) (model.User, error) {
+	user, err := u.db.FindUserByID(id)
+	if err != nil {
+		if errors.Is(err, database.ErrUserNotFound) {
+			return model.User{}, ErrUserNotFound
+		}
+		return model.User{}, err
+	}
+	return user, nil
+}
+
 func (u usersRepository) CreateUser(user model.User) (model.User, error) {
 	return u.db.CreateUser(user)
 }
+
+func (u usersRepository) UpdateUserPassword(id int, password string) error {
+	return u.db.UpdateUserPassword(id, password)
+}
+
+func (u usersRepository) VerifyUserEmail(id int) error {
+	return u.db.VerifyUserEmail(id)
+}
+
+func (u usersRepository) UpdateUserRole(id int, role string) error {
+	return u.db.UpdateUserRole(id, role)
+}
+
+func (u usersRepository) ListUsers(query model.UserQuery) ([]model.User, int64, error) {
+	return u.db.ListUsers(query)
+}
+
+func (u usersRepository) UpdateUser(id int, update model.UserUpdate) (model.User, error) {
+	user, err := u.db.UpdateUser(id, update)
+	if err != nil {
+		if errors.Is(err, database.ErrUserNotFound) {
+			return model.User{}, ErrUserNotFound
+		}
+		return model.User{}, err
+	}
+	return user, nil
+}
+
+func (u usersRepository) SetUserDisabled(id int, disabled bool) error {
+	if err := u.db.SetUserDisabled(id, disabled); err != nil {
+		if errors.Is(err, database.ErrUserNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (u usersRepository) DeleteUser(id int) error {
+	return u.db.DeleteUser(id)
+}
@@ -0,0 +1,53 @@
+package search
+
+import (
+	"sync"
+
+	"github.com/darolpz/students/internal/model"
+)
+
+// MemoryIndex is an in-process searcher over the students it was given. It
+// ranks every indexed student on each search, so it is meant for tests and
+// small data sets.
+type MemoryIndex struct {
+	mu       sync.RWMutex
+	students map[int]model.Student
+}
+
+// NewMemoryIndex returns an index of students.
+func NewMemoryIndex(students ...model.Student) *MemoryIndex {
+	index := &MemoryIndex{students: map[int]model.Student{}}
+	for _, student := range students {
+		index.Index(student)
+	}
+	return index
+}
+
+// Index adds student to the index, replacing the student with the same ID.
+func (i *MemoryIndex) Index(student model.Student) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.students[student.ID] = student
+}
+
+// Remove drops the student with id from the index.
+func (i *MemoryIndex) Remove(id int) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	delete(i.students, id)
+}
+
+func (i *MemoryIndex) SearchStudents(query string, limit int) ([]Hit, error) {
+	terms := Terms(query)
+	if len(terms) == 0 {
+		return nil, ErrEmptyQuery
+	}
+
+	i.mu.RLock()
+	students := make([]model.Student, 0, len(i.students))
+	for _, student := range i.students {
+		students = append(students, student)
+	}
+	i.mu.RUnlock()
+	return Rank(students, terms, limit), nil
+}
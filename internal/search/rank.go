@@ -0,0 +1,226 @@
+package search
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/darolpz/students/internal/model"
+)
+
+// field is a searchable student field and how much a match in it weighs.
+type field struct {
+	name   string
+	weight float64
+	value  func(student model.Student) string
+}
+
+var fields = []field{
+	{name: "first_name", weight: 2, value: func(student model.Student) string { return student.FirstName }},
+	{name: "last_name", weight: 2, value: func(student model.Student) string { return student.LastName }},
+	{name: "email", weight: 1, value: func(student model.Student) string { return student.Email }},
+}
+
+// word is a lower cased word of a field and where it is in the field.
+type word struct {
+	text       []rune
+	start, end int
+}
+
+// span is a part of a field, in bytes.
+type span struct {
+	start, end int
+}
+
+// Rank scores students against terms and returns the best limit of those
+// matching every term, best first. A term matches a word of a field when it
+// is the word, starts it, or is a misspelling of it or of its start. Exact
+// matches score highest, and names weigh more than the email.
+func Rank(students []model.Student, terms []string, limit int) []Hit {
+	var hits []Hit
+	for _, student := range students {
+		if hit, ok := score(student, terms); ok {
+			hits = append(hits, hit)
+		}
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Student.ID < hits[j].Student.ID
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// score matches every term against the fields of student. The score of a
+// term is its best weighted match; every match is highlighted.
+func score(student model.Student, terms []string) (Hit, bool) {
+	values := make([]string, len(fields))
+	fieldWords := make([][]word, len(fields))
+	for i, f := range fields {
+		values[i] = f.value(student)
+		fieldWords[i] = words(values[i])
+	}
+
+	spans := make([][]span, len(fields))
+	var total float64
+	for _, term := range terms {
+		runes := []rune(term)
+		var best float64
+		for i, f := range fields {
+			for _, w := range fieldWords[i] {
+				quality, length := match(runes, w.text)
+				if quality == 0 {
+					continue
+				}
+				end := w.start + len(string([]rune(values[i][w.start:w.end])[:length]))
+				spans[i] = append(spans[i], span{start: w.start, end: end})
+				if weighted := f.weight * quality; weighted > best {
+					best = weighted
+				}
+			}
+		}
+		if best == 0 {
+			return Hit{}, false
+		}
+		total += best
+	}
+
+	highlights := map[string]string{}
+	for i, f := range fields {
+		if len(spans[i]) > 0 {
+			highlights[f.name] = highlight(values[i], spans[i])
+		}
+	}
+	return Hit{Student: student, Score: total, Highlights: highlights}, true
+}
+
+// words splits value into runs of letters and digits.
+func words(value string) []word {
+	var result []word
+	start := -1
+	for i, r := range value {
+		letter := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if letter && start < 0 {
+			start = i
+		} else if !letter && start >= 0 {
+			result = append(result, newWord(value, start, i))
+			start = -1
+		}
+	}
+	if start >= 0 {
+		result = append(result, newWord(value, start, len(value)))
+	}
+	return result
+}
+
+func newWord(value string, start, end int) word {
+	return word{text: []rune(strings.ToLower(value[start:end])), start: start, end: end}
+}
+
+// match rates how well term matches w between 0, no match, and 1, the same
+// word, and returns how many runes of w it matched.
+func match(term, w []rune) (float64, int) {
+	if string(term) == string(w) {
+		return 1, len(w)
+	}
+	if len(term) >= 2 && len(term) < len(w) && string(term) == string(w[:len(term)]) {
+		return 0.5 + 0.4*float64(len(term))/float64(len(w)), len(term)
+	}
+
+	edits := allowedEdits(len(term))
+	if edits == 0 {
+		return 0, 0
+	}
+	if d := distance(term, w); d <= edits {
+		return 0.6 / float64(d), len(w)
+	}
+	if len(term) < len(w) {
+		if d := distance(term, w[:len(term)]); d <= edits {
+			return 0.4 / float64(d), len(term)
+		}
+	}
+	return 0, 0
+}
+
+// allowedEdits is how many typos a term of length runes may contain. Short
+// terms must be spelt right, or they would match almost anything.
+func allowedEdits(length int) int {
+	switch {
+	case length < 4:
+		return 0
+	case length < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// distance is the optimal string alignment distance between a and b: the
+// number of insertions, deletions, substitutions and transpositions of
+// adjacent runes that turn one into the other.
+func distance(a, b []rune) int {
+	rows := make([][]int, len(a)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(b)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d := min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d = min(d, rows[i-2][j-2]+1)
+			}
+			rows[i][j] = d
+		}
+	}
+	return rows[len(a)][len(b)]
+}
+
+func min(values ...int) int {
+	result := values[0]
+	for _, v := range values[1:] {
+		if v < result {
+			result = v
+		}
+	}
+	return result
+}
+
+// highlight escapes value for HTML and wraps the parts covered by spans in
+// <em> tags, merging the ones that overlap.
+func highlight(value string, spans []span) string {
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	var b strings.Builder
+	last := 0
+	for i := 0; i < len(spans); i++ {
+		s := spans[i]
+		for i+1 < len(spans) && spans[i+1].start <= s.end {
+			i++
+			if spans[i].end > s.end {
+				s.end = spans[i].end
+			}
+		}
+		b.WriteString(html.EscapeString(value[last:s.start]))
+		b.WriteString("<em>")
+		b.WriteString(html.EscapeString(value[s.start:s.end]))
+		b.WriteString("</em>")
+		last = s.end
+	}
+	b.WriteString(html.EscapeString(value[last:]))
+	return b.String()
+}
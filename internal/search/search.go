@@ -0,0 +1,62 @@
+package search
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+
+	"github.com/darolpz/students/internal/model"
+	"github.com/darolpz/students/internal/repository"
+)
+
+var ErrEmptyQuery = errors.New("search query must contain a letter or digit")
+
+// Hit is a student matching a search. Highlights holds, for every field a
+// term matched, the field as HTML-escaped text with the matched parts
+// wrapped in <em> tags.
+type Hit struct {
+	Student    model.Student     `json:"student"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+type ISearcher interface {
+	// SearchStudents returns up to limit students matching every word of
+	// query, best matches first.
+	SearchStudents(query string, limit int) ([]Hit, error)
+}
+
+// Terms splits query into lower case words, which are runs of letters and
+// digits.
+func Terms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// candidatesPerHit is how many candidates the full-text searcher reads from
+// the database for every hit it returns, leaving room for the ranking to
+// drop or reorder them.
+const candidatesPerHit = 5
+
+type fullTextSearcher struct {
+	students repository.IStudentsRepository
+}
+
+// NewFullTextSearcher searches students through the full-text index of the
+// students table, ranking its candidates with Rank.
+func NewFullTextSearcher(students repository.IStudentsRepository) ISearcher {
+	return fullTextSearcher{students: students}
+}
+
+func (s fullTextSearcher) SearchStudents(query string, limit int) ([]Hit, error) {
+	terms := Terms(query)
+	if len(terms) == 0 {
+		return nil, ErrEmptyQuery
+	}
+	candidates, err := s.students.SearchStudents(terms, limit*candidatesPerHit)
+	if err != nil {
+		return nil, err
+	}
+	return Rank(candidates, terms, limit), nil
+}
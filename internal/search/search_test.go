@@ -0,0 +1,248 @@
+package search
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/darolpz/students/internal/database"
+	"github.com/darolpz/students/internal/model"
+	"github.com/darolpz/students/internal/repository"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func TestMemoryIndex_SearchStudents(t *testing.T) {
+	index := NewMemoryIndex(
+		model.Student{ID: 1, FirstName: "John", LastName: "Smith", Email: "john.doe@gmail.com", Age: 33},
+		model.Student{ID: 2, FirstName: "Dario", LastName: "Lopez", Email: "daropl12@gmail.com", Age: 26},
+		model.Student{ID: 3, FirstName: "Johnny", LastName: "Smithers", Email: "jsmithers@school.edu", Age: 20},
+		model.Student{ID: 4, FirstName: "Ana", LastName: "O'Brien <Jr>", Email: "ana@school.edu", Age: 21},
+	)
+
+	tests := []struct {
+		name          string
+		query         string
+		limit         int
+		wantIDs       []int
+		wantHighlight map[int]map[string]string
+		expectedError error
+	}{
+		{
+			name:    "should_rank_exact_matches_before_prefixes",
+			query:   "john",
+			limit:   10,
+			wantIDs: []int{1, 3},
+			wantHighlight: map[int]map[string]string{
+				1: {"first_name": "<em>John</em>", "email": "<em>john</em>.doe@gmail.com"},
+				3: {"first_name": "<em>John</em>ny"},
+			},
+		},
+		{
+			name:    "should_tolerate_typos",
+			query:   "jhon smiht",
+			limit:   10,
+			wantIDs: []int{1, 3},
+			wantHighlight: map[int]map[string]string{
+				1: {"first_name": "<em>John</em>", "last_name": "<em>Smith</em>", "email": "<em>john</em>.doe@gmail.com"},
+				3: {"first_name": "<em>John</em>ny", "last_name": "<em>Smith</em>ers"},
+			},
+		},
+		{
+			name:    "should_require_every_term",
+			query:   "john lopez",
+			limit:   10,
+			wantIDs: []int{},
+		},
+		{
+			name:    "should_match_email",
+			query:   "school",
+			limit:   10,
+			wantIDs: []int{3, 4},
+		},
+		{
+			name:    "should_not_tolerate_typos_in_short_terms",
+			query:   "jon",
+			limit:   10,
+			wantIDs: []int{},
+		},
+		{
+			name:    "should_escape_highlights",
+			query:   "brien jr",
+			limit:   10,
+			wantIDs: []int{4},
+			wantHighlight: map[int]map[string]string{
+				4: {"last_name": "O&#39;<em>Brien</em> &lt;<em>Jr</em>&gt;"},
+			},
+		},
+		{
+			name:    "should_honor_limit",
+			query:   "smith",
+			limit:   1,
+			wantIDs: []int{1},
+		},
+		{
+			name:          "should_reject_empty_query",
+			query:         " @. ",
+			limit:         10,
+			expectedError: ErrEmptyQuery,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits, err := index.SearchStudents(tt.query, tt.limit)
+			if tt.expectedError != nil {
+				require.ErrorIs(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+
+			ids := []int{}
+			for _, hit := range hits {
+				ids = append(ids, hit.Student.ID)
+				if want, ok := tt.wantHighlight[hit.Student.ID]; ok {
+					require.Equal(t, want, hit.Highlights)
+				}
+			}
+			require.Equal(t, tt.wantIDs, ids)
+		})
+	}
+}
+
+func TestFullTextSearcher_SearchStudents(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	dialector := mysql.New(mysql.Config{
+		Conn:                      mockDB,
+		DriverName:                "mysql",
+		DSN:                       "sqlmock_db",
+		SkipInitializeWithVersion: true,
+	})
+
+	db, err := gorm.Open(dialector)
+	require.NoError(t, err)
+	searcher := NewFullTextSearcher(repository.NewStudentsRepo(database.NewDatabaseServiceFromDB(db)))
+
+	fullTextQuery := regexp.QuoteMeta("SELECT * FROM `students` WHERE deleted_at IS NULL AND MATCH(first_name, last_name, email) AGAINST (? IN BOOLEAN MODE)")
+	soundsLikeQuery := regexp.QuoteMeta("SELECT * FROM `students` WHERE deleted_at IS NULL AND (first_name SOUNDS LIKE ? OR last_name SOUNDS LIKE ?")
+	columns := []string{"id", "first_name", "last_name", "email", "age"}
+
+	tests := []struct {
+		name          string
+		query         string
+		setMock       func(mock sqlmock.Sqlmock)
+		wantIDs       []int
+		expectedError error
+	}{
+		{
+			name:  "should_rank_full_text_candidates",
+			query: "Smith, John",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(fullTextQuery).
+					WithArgs("smith* john*", "smith* john*").
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow("3", "Johnny", "Smithers", "jsmithers@school.edu", "20").
+						AddRow("2", "Jane", "Smith", "jane@gmail.com", "30").
+						AddRow("1", "John", "Smith", "john.doe@gmail.com", "33"))
+				mock.ExpectQuery(soundsLikeQuery).
+					WithArgs("smith", "smith", "john", "john").
+					WillReturnRows(sqlmock.NewRows(columns))
+			},
+			wantIDs: []int{1, 3},
+		},
+		{
+			name:  "should_rank_candidates_of_both_queries",
+			query: "jhon smith",
+			setMock: func(mock sqlmock.Sqlmock) {
+				// Jane only matches the term spelt right, while John Smith is
+				// only found by the misspelt one sounding like his name
+				mock.ExpectQuery(fullTextQuery).
+					WithArgs("jhon* smith*", "jhon* smith*").
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow("2", "Jane", "Smith", "jane@gmail.com", "30"))
+				mock.ExpectQuery(soundsLikeQuery).
+					WithArgs("jhon", "jhon", "smith", "smith").
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow("2", "Jane", "Smith", "jane@gmail.com", "30").
+						AddRow("1", "John", "Smith", "john.doe@gmail.com", "33"))
+			},
+			wantIDs: []int{1},
+		},
+		{
+			name:  "should_rank_candidates_sounding_alike",
+			query: "jhon smiht",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(fullTextQuery).WillReturnRows(sqlmock.NewRows(columns))
+				mock.ExpectQuery(soundsLikeQuery).
+					WithArgs("jhon", "jhon", "smiht", "smiht").
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow("1", "John", "Smith", "john.doe@gmail.com", "33"))
+			},
+			wantIDs: []int{1},
+		},
+		{
+			name:          "should_return_error_empty_query",
+			query:         " - ",
+			setMock:       func(mock sqlmock.Sqlmock) {},
+			expectedError: ErrEmptyQuery,
+		},
+		{
+			name:  "should_return_error_search",
+			query: "john",
+			setMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(fullTextQuery).WillReturnError(errors.New("error"))
+			},
+			expectedError: database.ErrSearchStudents,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setMock(mock)
+			hits, err := searcher.SearchStudents(tt.query, 10)
+			if tt.expectedError != nil {
+				require.ErrorIs(t, err, tt.expectedError)
+			} else {
+				require.NoError(t, err)
+				ids := []int{}
+				for _, hit := range hits {
+					ids = append(ids, hit.Student.ID)
+				}
+				require.Equal(t, tt.wantIDs, ids)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestMemoryIndex_Remove(t *testing.T) {
+	index := NewMemoryIndex(model.Student{ID: 1, FirstName: "John", LastName: "Smith", Email: "john.doe@gmail.com"})
+	index.Remove(1)
+
+	hits, err := index.SearchStudents("john", 10)
+	require.NoError(t, err)
+	require.Empty(t, hits)
+}
+
+func Test_distance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "john", b: "john", want: 0},
+		{a: "jhon", b: "john", want: 1},
+		{a: "jon", b: "john", want: 1},
+		{a: "smiht", b: "smith", want: 1},
+		{a: "lopez", b: "lopes", want: 1},
+		{a: "", b: "ana", want: 3},
+		{a: "kitten", b: "sitting", want: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"_"+tt.b, func(t *testing.T) {
+			require.Equal(t, tt.want, distance([]rune(tt.a), []rune(tt.b)))
+		})
+	}
+}
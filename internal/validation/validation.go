@@ -0,0 +1,152 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+var ErrInvalid = errors.New("validation failed")
+
+// FieldError is a field that breaks a rule. Field is its JSON name, Rule the
+// validate tag it breaks, such as max, and Param the argument of the rule,
+// such as 20.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// Errors lists every field of a value that breaks a rule. It wraps
+// ErrInvalid.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	problems := make([]string, len(e))
+	for i, f := range e {
+		problems[i] = f.Field + ": " + f.Message
+	}
+	return fmt.Sprintf("%s: %s", ErrInvalid, strings.Join(problems, "; "))
+}
+
+func (e Errors) Unwrap() error {
+	return ErrInvalid
+}
+
+// messages describe what each rule requires, given its param.
+var messages = map[string]func(param string) string{
+	"required": func(string) string { return "is required" },
+	"notblank": func(string) string { return "is required" },
+	"email":    func(string) string { return "must be an email address" },
+	"max":      func(p string) string { return "must be at most " + p },
+	"min":      func(p string) string { return "must be at least " + p },
+	"oneof":    func(p string) string { return "must be one of " + strings.ReplaceAll(p, " ", ", ") },
+}
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.SetTagName("validate")
+	// Report fields by the name clients send them with
+	v.RegisterTagNameFunc(jsonName)
+	// The built-in rules are overridden: required accepts blank strings and
+	// email does not match what net/mail, and the importer, accept
+	_ = v.RegisterValidation("notblank", func(fl validator.FieldLevel) bool {
+		return strings.TrimSpace(fl.Field().String()) != ""
+	})
+	_ = v.RegisterValidation("email", func(fl validator.FieldLevel) bool {
+		address, err := mail.ParseAddress(fl.Field().String())
+		return err == nil && address.Address == fl.Field().String()
+	})
+	return v
+}
+
+// Struct checks the fields of s, a struct or a pointer to one, against the
+// rules in their validate tags. It returns Errors listing every field that
+// breaks one, in the order of the fields.
+func Struct(s interface{}) error {
+	err := validate.Struct(s)
+	var fields validator.ValidationErrors
+	if !errors.As(err, &fields) {
+		return err
+	}
+
+	errs := make(Errors, len(fields))
+	for i, f := range fields {
+		errs[i] = FieldError{Field: f.Field(), Rule: f.Tag(), Param: f.Param(), Message: message(f)}
+	}
+	return errs
+}
+
+// JSON checks s, decoded from the JSON object doc, as Struct does. Fields
+// with rules must also have a value in doc other than null, or they are
+// reported as required: decoding would leave them at their zero value, which
+// may pass their rules, such as an age of 0.
+func JSON(doc []byte, s interface{}) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(doc, &members); err != nil {
+		return err
+	}
+
+	err := Struct(s)
+	var broken Errors
+	if err != nil && !errors.As(err, &broken) {
+		return err
+	}
+	byField := map[string][]FieldError{}
+	for _, f := range broken {
+		byField[f.Field] = append(byField[f.Field], f)
+	}
+
+	var errs Errors
+	t := reflect.Indirect(reflect.ValueOf(s)).Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := jsonName(field)
+		if field.Tag.Get("validate") == "" || name == "" {
+			continue
+		}
+		if value, ok := members[name]; !ok || string(value) == "null" {
+			errs = append(errs, FieldError{Field: name, Rule: "required", Message: messages["required"]("")})
+			continue
+		}
+		errs = append(errs, byField[name]...)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// jsonName returns the name field is sent with in JSON, or an empty string
+// when it is not.
+func jsonName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+func message(f validator.FieldError) string {
+	describe, ok := messages[f.Tag()]
+	if !ok {
+		return "is invalid"
+	}
+	msg := describe(f.Param())
+	// Lengths of strings are counted in characters
+	if (f.Tag() == "max" || f.Tag() == "min") && f.Kind() == reflect.String {
+		msg += " characters"
+	}
+	return msg
+}
@@ -0,0 +1,104 @@
+package validation
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/darolpz/students/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStruct(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  Errors
+	}{
+		{
+			name:  "should_accept_valid_student",
+			value: model.Student{FirstName: "José", LastName: "Núñez", Age: 0, Email: "jose.nunez@gmail.com"},
+		},
+		{
+			name:  "should_list_every_invalid_student_field",
+			value: model.Student{FirstName: "  ", LastName: "Smithsonian Institution", Age: -1, Email: "John <john@gmail.com>"},
+			want: Errors{
+				{Field: "first_name", Rule: "notblank", Message: "is required"},
+				{Field: "last_name", Rule: "max", Param: "20", Message: "must be at most 20 characters"},
+				{Field: "age", Rule: "min", Param: "0", Message: "must be at least 0"},
+				{Field: "email", Rule: "email", Message: "must be an email address"},
+			},
+		},
+		{
+			name:  "should_check_length_before_format",
+			value: &model.Student{FirstName: "John", LastName: "Doe", Age: 151, Email: strings.Repeat("a", 41) + "@gmail.com"},
+			want: Errors{
+				{Field: "age", Rule: "max", Param: "150", Message: "must be at most 150"},
+				{Field: "email", Rule: "max", Param: "50", Message: "must be at most 50 characters"},
+			},
+		},
+		{
+			name:  "should_list_invalid_user_fields",
+			value: model.User{Name: "", Email: "john", Role: "root"},
+			want: Errors{
+				{Field: "name", Rule: "notblank", Message: "is required"},
+				{Field: "email", Rule: "email", Message: "must be an email address"},
+				{Field: "role", Rule: "oneof", Param: "admin user", Message: "must be one of admin, user"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Struct(tt.value)
+			if tt.want == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, ErrInvalid)
+			require.Equal(t, tt.want, err)
+		})
+	}
+}
+
+func TestErrors_Error(t *testing.T) {
+	err := Errors{
+		{Field: "first_name", Rule: "notblank", Message: "is required"},
+		{Field: "age", Rule: "min", Param: "0", Message: "must be at least 0"},
+	}
+	require.Equal(t, "validation failed: first_name: is required; age: must be at least 0", err.Error())
+}
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want Errors
+	}{
+		{
+			name: "should_accept_complete_student",
+			doc:  `{"first_name":"John","last_name":"Doe","age":0,"email":"john.doe@gmail.com"}`,
+		},
+		{
+			name: "should_report_missing_and_null_fields",
+			doc:  `{"first_name":"John","last_name":null,"email":"john"}`,
+			want: Errors{
+				{Field: "last_name", Rule: "required", Message: "is required"},
+				{Field: "age", Rule: "required", Message: "is required"},
+				{Field: "email", Rule: "email", Message: "must be an email address"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var student model.Student
+			require.NoError(t, json.Unmarshal([]byte(tt.doc), &student))
+			err := JSON([]byte(tt.doc), &student)
+			if tt.want == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, ErrInvalid)
+			require.Equal(t, tt.want, err)
+		})
+	}
+}
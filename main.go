@@ -1,24 +1,45 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/darolpz/students/cmd/handlers"
 	_ "github.com/darolpz/students/docs"
 	"github.com/darolpz/students/internal/auth"
 	"github.com/darolpz/students/internal/database"
+	"github.com/darolpz/students/internal/jobs"
+	"github.com/darolpz/students/internal/mail"
+	"github.com/darolpz/students/internal/oidc"
 	"github.com/darolpz/students/internal/repository"
+	"github.com/darolpz/students/internal/search"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
 	swaggerFiles "github.com/swaggo/files"     // swagger embed files
 	ginSwagger "github.com/swaggo/gin-swagger" // gin-swagger middleware
 )
 
 type services struct {
-	studentRepository repository.IStudentsRepository
-	userRepository    repository.IUsersRepository
-	authService       auth.IAuthService
+	studentRepository     repository.IStudentsRepository
+	userRepository        repository.IUsersRepository
+	tokensRepository      repository.IRefreshTokensRepository
+	sessionsRepository    repository.ISessionsRepository
+	revokedRepository     repository.IRevokedTokensRepository
+	resetsRepository      repository.IPasswordResetsRepository
+	throttlesRepository   repository.ILoginThrottlesRepository
+	mfaRepository         repository.IMFARepository
+	apiKeysRepository     repository.IAPIKeysRepository
+	identitiesRepository  repository.IUserIdentitiesRepository
+	invitationsRepository repository.IInvitationsRepository
+	studentSearcher       search.ISearcher
+	oidcProvider          oidc.IProvider
+	authService           auth.IAuthService
+	mailer                mail.IMailer
 }
 
 // @title           darolpz students
@@ -40,12 +61,89 @@ type services struct {
 // @name Authorization
 
 func main() {
+	// Tokens carry their times in microseconds rather than whole seconds, so
+	// that revoking every token of a user does not spare the ones issued
+	// earlier in the same second. The setting is global to the jwt package and
+	// applies to signing and parsing alike.
+	jwt.TimePrecision = time.Microsecond
+
 	services := initServices()
+	go jobs.Every(context.Background(), "purge revoked tokens", time.Hour, services.revokedRepository.DeleteExpiredRevokedTokens)
+	go jobs.Every(context.Background(), "purge login throttles", time.Hour, func() error {
+		return services.throttlesRepository.DeleteStaleLoginThrottles(time.Now().Add(-auth.IPLockout.LockoutDuration))
+	})
+	go jobs.Every(context.Background(), "purge sessions", time.Hour, func() error {
+		return services.sessionsRepository.DeleteStaleSessions(time.Now().Add(-auth.RefreshTokenDuration))
+	})
+	studentRetention := time.Duration(envInt("STUDENT_RETENTION_DAYS", 30)) * 24 * time.Hour
+	go jobs.Every(context.Background(), "purge deleted students", time.Hour, func() error {
+		return services.studentRepository.PurgeDeletedStudents(time.Now().Add(-studentRetention))
+	})
+
+	authConfig := handlers.AuthConfig{
+		PublicURL:        os.Getenv("PUBLIC_URL"),
+		PasswordResetURL: os.Getenv("PASSWORD_RESET_URL"),
+		MFAIssuer:        envOrDefault("MFA_ISSUER", "students"),
+		MFARequiredRoles: splitList(os.Getenv("MFA_REQUIRED_ROLES")),
+		PasswordPolicy:   loadPasswordPolicy(),
+		InvitationURL:    envOrDefault("INVITATION_URL", os.Getenv("PUBLIC_URL")+"/register"),
+		OpenSignup:       envBool("OPEN_SIGNUP", true),
+	}
 
 	app := gin.Default()
+	// Client IPs are throttled on login and shown in sessions, so the headers
+	// forwarding them are only believed from the proxies in TRUSTED_PROXIES
+	if err := app.SetTrustedProxies(splitList(os.Getenv("TRUSTED_PROXIES"))); err != nil {
+		log.Fatal(err)
+	}
 	handlers.CreateHealthEndpoints(app)
-	handlers.CreateStudentsEndpoints(app, services.studentRepository, services.authService)
-	handlers.CreateAuthEndpoints(app, services.userRepository, services.authService)
+	handlers.CreateWellKnownEndpoints(app, services.authService)
+	handlers.CreateStudentsEndpoints(
+		app,
+		services.studentRepository,
+		services.revokedRepository,
+		services.apiKeysRepository,
+		services.studentSearcher,
+		services.authService,
+		handlers.StudentsConfig{RequireIfMatch: envBool("STUDENTS_REQUIRE_IF_MATCH", false)})
+	handlers.CreateAuthEndpoints(
+		app,
+		services.userRepository,
+		services.tokensRepository,
+		services.sessionsRepository,
+		services.revokedRepository,
+		services.resetsRepository,
+		services.throttlesRepository,
+		services.mfaRepository,
+		services.invitationsRepository,
+		services.authService,
+		services.mailer,
+		authConfig)
+	if services.oidcProvider != nil {
+		handlers.CreateOIDCEndpoints(
+			app,
+			services.oidcProvider,
+			services.userRepository,
+			services.identitiesRepository,
+			services.tokensRepository,
+			services.sessionsRepository,
+			services.mfaRepository,
+			services.authService,
+			authConfig,
+			handlers.OIDCConfig{RoleMapping: splitMapping(os.Getenv("OIDC_ROLE_MAPPING"))})
+	}
+	handlers.CreateAdminEndpoints(
+		app,
+		services.userRepository,
+		services.tokensRepository,
+		services.throttlesRepository,
+		services.revokedRepository,
+		services.mfaRepository,
+		services.apiKeysRepository,
+		services.invitationsRepository,
+		services.authService,
+		services.mailer,
+		authConfig)
 	app.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	// listen and serve on
@@ -66,7 +164,157 @@ func initServices() *services {
 
 	services.studentRepository = repository.NewStudentsRepo(databaseService)
 	services.userRepository = repository.NewUsersRepository(databaseService)
+	services.tokensRepository = repository.NewRefreshTokensRepository(databaseService)
+	services.sessionsRepository = repository.NewSessionsRepository(databaseService)
+	services.revokedRepository = repository.NewRevokedTokensRepository(databaseService)
+	services.resetsRepository = repository.NewPasswordResetsRepository(databaseService)
+	services.throttlesRepository = repository.NewLoginThrottlesRepository(databaseService)
+	services.mfaRepository = repository.NewMFARepository(databaseService)
+	services.apiKeysRepository = repository.NewAPIKeysRepository(databaseService)
+	services.identitiesRepository = repository.NewUserIdentitiesRepository(databaseService)
+	services.invitationsRepository = repository.NewInvitationsRepository(databaseService)
+	services.studentSearcher = search.NewFullTextSearcher(services.studentRepository)
 
-	services.authService = auth.NewAuthService(os.Getenv("JWT_SECRET"))
+	keys, err := loadKeySet(os.Getenv("JWT_KEYS_DIR"), os.Getenv("JWT_ACTIVE_KEY"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	hasher, err := loadPasswordHasher()
+	if err != nil {
+		log.Fatal(err)
+	}
+	services.authService = auth.NewAuthService(keys, hasher)
+	services.mailer = initMailer()
+	services.oidcProvider = initOIDCProvider()
 	return services
 }
+
+// initOIDCProvider configures login through an external identity provider
+// when OIDC_ISSUER is set.
+func initOIDCProvider() oidc.IProvider {
+	issuer := os.Getenv("OIDC_ISSUER")
+	if issuer == "" {
+		return nil
+	}
+	return oidc.NewProvider(oidc.Config{
+		Issuer:       issuer,
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  envOrDefault("OIDC_REDIRECT_URL", os.Getenv("PUBLIC_URL")+"/auth/oidc/callback"),
+		Scopes:       splitList(os.Getenv("OIDC_SCOPES")),
+		GroupsClaim:  os.Getenv("OIDC_GROUPS_CLAIM"),
+	}, nil)
+}
+
+// initMailer delivers mail through SMTP_HOST when it is set, and otherwise
+// writes it to MAIL_OUTBOX_DIR.
+func initMailer() mail.IMailer {
+	from := os.Getenv("MAIL_FROM")
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		return mail.NewSMTPMailer(host, os.Getenv("SMTP_PORT"), os.Getenv("SMTP_USER"), os.Getenv("SMTP_PASS"), from)
+	}
+	outbox := os.Getenv("MAIL_OUTBOX_DIR")
+	if outbox == "" {
+		outbox = "outbox"
+	}
+	log.Printf("SMTP_HOST is not set, writing mail to %s", outbox)
+	return mail.NewOutboxMailer(outbox, from)
+}
+
+// loadKeySet loads the token signing keys from dir, falling back to an
+// ephemeral key when no directory is configured.
+func loadKeySet(dir, active string) (*auth.KeySet, error) {
+	if dir == "" {
+		log.Print("JWT_KEYS_DIR is not set, signing tokens with an ephemeral key")
+		return auth.GenerateKeySet()
+	}
+	return auth.LoadKeySet(dir, active)
+}
+
+// envOrDefault returns the environment variable key, or fallback when it is
+// not set.
+func envOrDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// loadPasswordHasher builds the hasher named by PASSWORD_HASHER, argon2id
+// by default, tuned by BCRYPT_COST or ARGON2_MEMORY (KiB), ARGON2_TIME and
+// ARGON2_THREADS.
+func loadPasswordHasher() (auth.IPasswordHasher, error) {
+	switch algorithm := envOrDefault("PASSWORD_HASHER", "argon2id"); algorithm {
+	case "bcrypt":
+		return auth.NewBcryptHasher(envInt("BCRYPT_COST", auth.DefaultBcryptCost))
+	case "argon2id":
+		params := auth.DefaultArgon2idParams
+		params.Memory = uint32(envInt("ARGON2_MEMORY", int(params.Memory)))
+		params.Time = uint32(envInt("ARGON2_TIME", int(params.Time)))
+		params.Threads = uint8(envInt("ARGON2_THREADS", int(params.Threads)))
+		return auth.NewArgon2idHasher(params)
+	default:
+		return nil, fmt.Errorf("%w: unknown PASSWORD_HASHER %s", auth.ErrInvalidHasherConfig, algorithm)
+	}
+}
+
+// loadPasswordPolicy reads the PASSWORD_* settings of the password policy.
+func loadPasswordPolicy() auth.PasswordPolicy {
+	policy := auth.DefaultPasswordPolicy
+	policy.MinLength = envInt("PASSWORD_MIN_LENGTH", policy.MinLength)
+	policy.RequireMixedCase = envBool("PASSWORD_REQUIRE_MIXED_CASE", false)
+	policy.RequireDigit = envBool("PASSWORD_REQUIRE_DIGIT", false)
+	policy.RequireSymbol = envBool("PASSWORD_REQUIRE_SYMBOL", false)
+	return policy
+}
+
+// envInt returns the environment variable key as an integer, or fallback
+// when it is not set.
+func envInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("%s must be an integer: %s", key, err)
+	}
+	return n
+}
+
+// envBool reports whether the environment variable key is set to true, or
+// returns fallback when it is not set.
+func envBool(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Fatalf("%s must be a boolean: %s", key, err)
+	}
+	return b
+}
+
+// splitList splits a comma separated environment variable, dropping empty
+// entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// splitMapping parses a comma separated list of key=value pairs.
+func splitMapping(value string) map[string]string {
+	mapping := map[string]string{}
+	for _, item := range splitList(value) {
+		if pair := strings.SplitN(item, "=", 2); len(pair) == 2 {
+			mapping[strings.TrimSpace(pair[0])] = strings.TrimSpace(pair[1])
+		}
+	}
+	return mapping
+}
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS invitations(
+    id INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    email VARCHAR(50) NOT NULL,
+    role enum ('admin', 'user') NOT NULL DEFAULT 'user',
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    created_by INT(6) UNSIGNED NOT NULL,
+    expires_at DATETIME NOT NULL,
+    used_at DATETIME NULL,
+    created_at DATETIME NOT NULL
+);
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS login_throttles(
+    id VARCHAR(100) PRIMARY KEY,
+    failures INT UNSIGNED NOT NULL,
+    last_failure_at DATETIME NOT NULL,
+    INDEX (last_failure_at)
+);
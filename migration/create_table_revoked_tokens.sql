@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS revoked_tokens(
+    id INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    jti VARCHAR(32) NOT NULL DEFAULT '',
+    session_id VARCHAR(36) NOT NULL DEFAULT '',
+    user_id INT(6) UNSIGNED NOT NULL,
+    issued_before DATETIME(6) NULL,
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    INDEX (jti),
+    INDEX (session_id),
+    INDEX (user_id),
+    INDEX (expires_at)
+);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS sessions(
+    id VARCHAR(36) PRIMARY KEY,
+    user_id INT(6) UNSIGNED NOT NULL,
+    user_agent VARCHAR(255) NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    created_at DATETIME NOT NULL,
+    last_seen_at DATETIME NOT NULL,
+    INDEX (user_id),
+    INDEX (last_seen_at)
+);
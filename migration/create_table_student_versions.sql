@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS student_versions(
+    id INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    student_id INT(6) UNSIGNED NOT NULL,
+    version INT UNSIGNED NOT NULL,
+    action enum ('create', 'update', 'delete', 'restore', 'revert') NOT NULL,
+    changed_by INT(6) UNSIGNED NULL,
+    changed_at DATETIME NOT NULL,
+    first_name VARCHAR(20) NOT NULL,
+    last_name VARCHAR(20) NOT NULL,
+    age INT(3) NOT NULL,
+    email VARCHAR(50) NOT NULL,
+    changes JSON NOT NULL,
+    UNIQUE (student_id, version)
+);
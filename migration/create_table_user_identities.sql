@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS user_identities(
+    id INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id INT(6) UNSIGNED NOT NULL,
+    issuer VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    created_at DATETIME NOT NULL,
+    UNIQUE (issuer, subject),
+    INDEX (user_id)
+);
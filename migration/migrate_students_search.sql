@@ -0,0 +1,3 @@
+-- Student search ranks matches with a full-text index over the names and
+-- the email.
+ALTER TABLE students ADD FULLTEXT INDEX students_search (first_name, last_name, email);
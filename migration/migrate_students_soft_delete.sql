@@ -0,0 +1,13 @@
+-- Deleted students are kept in the trash until they are restored or purged.
+ALTER TABLE students
+    ADD COLUMN deleted_at DATETIME NULL,
+    ADD COLUMN deleted_by INT(6) UNSIGNED NULL,
+    ADD INDEX students_deleted_at (deleted_at);
+
+-- Emails are unique among active students only, so that trashed ones do not
+-- keep their email from being used again.
+ALTER TABLE students
+    ADD COLUMN active_email VARCHAR(50) AS (IF(deleted_at IS NULL, email, NULL)) STORED,
+    DROP INDEX email,
+    ADD UNIQUE INDEX students_active_email (active_email),
+    ADD INDEX students_email (email);
@@ -0,0 +1,2 @@
+-- Student writes are conditional on the version, which is sent as the ETag.
+ALTER TABLE students ADD COLUMN version INT UNSIGNED NOT NULL DEFAULT 1;
@@ -0,0 +1,2 @@
+-- Admins can disable users, keeping them from logging in.
+ALTER TABLE users ADD COLUMN disabled_at DATETIME NULL;
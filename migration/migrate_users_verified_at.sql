@@ -0,0 +1,4 @@
+-- Users must verify their email before they can log in. Accounts created
+-- before verification existed are considered verified.
+ALTER TABLE users ADD COLUMN verified_at DATETIME NULL;
+UPDATE users SET verified_at = NOW() WHERE verified_at IS NULL;